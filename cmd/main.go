@@ -3,10 +3,11 @@ package main
 import (
 	"github.com/nordew/UploadApp/internal/app"
 	"log"
+	"os"
 )
 
 func main() {
-	if err := app.Run(); err != nil {
+	if err := app.Main(os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
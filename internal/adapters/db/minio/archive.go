@@ -0,0 +1,63 @@
+package miniodb
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// ArchiveStorage keeps records which are removed from the database, e.g. pruned audit logs, in their own bucket.
+type ArchiveStorage interface {
+	// Put stores data under the given key, replacing an object stored under it before.
+	// The bucket is created if it doesn't exist yet.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+type archiveStorage struct {
+	db         *minio.Client
+	bucketName string
+	logger     *logrus.Logger
+}
+
+func NewArchiveStorage(db *minio.Client, bucketName string, logger *logrus.Logger) *archiveStorage {
+	return &archiveStorage{
+		db:         db,
+		bucketName: bucketName,
+		logger:     logger,
+	}
+}
+
+func (s *archiveStorage) Put(ctx context.Context, key string, data []byte, contentType string) (err error) {
+	ctx, span := s.startSpan(ctx, "Put")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "Put", "key": key})
+
+	exists, err := s.db.BucketExists(ctx, s.bucketName)
+	if err != nil {
+		logger.WithError(err).Error("failed to check bucket")
+		return err
+	}
+
+	if !exists {
+		if err := s.db.MakeBucket(ctx, s.bucketName, minio.MakeBucketOptions{}); err != nil {
+			logger.WithError(err).Error("failed to create bucket")
+			return err
+		}
+	}
+
+	_, err = s.db.PutObject(ctx, s.bucketName, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to archive object")
+		return err
+	}
+
+	logger.Info("Put: object archived successfully")
+	return nil
+}
@@ -3,18 +3,21 @@ package miniodb
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
 )
 
 var (
-	ErrObjectNotFound = errors.New("object wasn't found")
+	ErrObjectNotFound = errs.New(errs.NotFound, "object wasn't found")
 )
 
 // ImageStorage is the interface that defines methods for storing and retrieving images in an object storage service.
@@ -27,15 +30,32 @@ type ImageStorage interface {
 	// It returns a slice of Image entities and an error if the retrieval fails.
 	GetAll(ctx context.Context, id string) ([]entity.Image, error)
 
-	// GetBySize retrieves an image of the specified size associated with the given identifier from the storage service.
-	// It returns the requested Image entity and an error if the retrieval fails.
-	GetBySize(ctx context.Context, id string, size int) (*entity.Image, error)
+	// Get retrieves the object stored under the given name.
+	// It returns ErrObjectNotFound if there is no such object.
+	Get(ctx context.Context, name string) (*entity.Image, error)
 
 	// DeleteAllImages deletes all images associated with the specified identifier.
 	// The identifier is used to uniquely identify the set of images to delete.
 	// It returns an error if the deletion operation fails.
 	// The error may indicate issues with object removal or connectivity with the storage service.
 	DeleteAllImages(ctx context.Context, id string) error
+
+	// PresignedURL returns a temporary URL which allows downloading the object with the given name without credentials.
+	// The URL stops working once expiry has passed.
+	PresignedURL(ctx context.Context, name string, expiry time.Duration) (string, error)
+
+	// ListObjects returns the description of every object in the bucket.
+	ListObjects(ctx context.Context) ([]ObjectInfo, error)
+
+	// Ping checks that the storage service is reachable and the bucket exists.
+	Ping(ctx context.Context) error
+}
+
+// ObjectInfo describes a stored object without its content.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
 }
 
 type imageStorage struct {
@@ -52,10 +72,13 @@ func NewImageStorage(db *minio.Client, bucketName string, logger *logrus.Logger)
 	}
 }
 
-func (s *imageStorage) Upload(ctx context.Context, image entity.Image) error {
-	logger := s.logger.WithField("function", "Upload")
+func (s *imageStorage) Upload(ctx context.Context, image entity.Image) (err error) {
+	ctx, span := s.startSpan(ctx, "Upload")
+	defer tracing.End(span, &err)
 
-	_, err := s.db.PutObject(ctx, s.bucketName, image.Name, image.Reader, image.Size, minio.PutObjectOptions{
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Upload")
+
+	_, err = s.db.PutObject(ctx, s.bucketName, image.Name, image.Reader, image.Size, minio.PutObjectOptions{
 		ContentType: "image/jpeg",
 	})
 	if err != nil {
@@ -67,8 +90,11 @@ func (s *imageStorage) Upload(ctx context.Context, image entity.Image) error {
 	return nil
 }
 
-func (s *imageStorage) GetAll(ctx context.Context, id string) ([]entity.Image, error) {
-	logger := s.logger.WithField("function", "GetAll")
+func (s *imageStorage) GetAll(ctx context.Context, id string) (_ []entity.Image, err error) {
+	ctx, span := s.startSpan(ctx, "GetAll")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "GetAll")
 
 	imageCh := s.db.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{
 		Prefix: id,
@@ -134,36 +160,42 @@ func (s *imageStorage) GetAll(ctx context.Context, id string) ([]entity.Image, e
 	return images, nil
 }
 
-func (s *imageStorage) GetBySize(ctx context.Context, id string, size int) (*entity.Image, error) {
-	logger := s.logger.WithField("function", "GetBySize")
+func (s *imageStorage) Get(ctx context.Context, name string) (_ *entity.Image, err error) {
+	ctx, span := s.startSpan(ctx, "Get")
+	defer tracing.End(span, &err)
 
-	prompt := fmt.Sprintf("%s_%d", id, size)
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Get")
 
-	image, err := s.db.GetObject(ctx, s.bucketName, prompt, minio.GetObjectOptions{})
+	object, err := s.db.GetObject(ctx, s.bucketName, name, minio.GetObjectOptions{})
 	if err != nil {
-		logger.WithError(err).Errorf("failed to get image by size for ID: %s, Size: %d", id, size)
-		return nil, fmt.Errorf("%w", ErrObjectNotFound)
+		logger.WithError(err).Errorf("failed to get object: %s", name)
+		return nil, err
 	}
+	defer object.Close()
 
 	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, image); err != nil {
+	if _, err := io.Copy(buf, object); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+		}
+
 		logger.WithError(err).Error("failed to copy image data")
 		return nil, err
 	}
 
-	preparedImage := entity.Image{
-		ID:     id,
-		Name:   prompt,
+	return &entity.Image{
+		ID:     name,
+		Name:   name,
 		Size:   int64(buf.Len()),
 		Reader: bytes.NewReader(buf.Bytes()),
-	}
-
-	logger.Infof("GetBySize: image retrieved successfully for ID: %s, Size: %d", id, size)
-	return &preparedImage, nil
+	}, nil
 }
 
-func (s *imageStorage) DeleteAllImages(ctx context.Context, id string) error {
-	logger := s.logger.WithField("function", "DeleteAllImages")
+func (s *imageStorage) DeleteAllImages(ctx context.Context, id string) (err error) {
+	ctx, span := s.startSpan(ctx, "DeleteAllImages")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "DeleteAllImages")
 
 	if err := s.db.RemoveObject(ctx, s.bucketName, id, minio.RemoveObjectOptions{}); err != nil {
 		logger.WithError(err).Errorf("failed to delete images for ID: %s", id)
@@ -173,3 +205,58 @@ func (s *imageStorage) DeleteAllImages(ctx context.Context, id string) error {
 	logger.Infof("DeleteAllImages: images deleted successfully for ID: %s", id)
 	return nil
 }
+
+func (s *imageStorage) PresignedURL(ctx context.Context, name string, expiry time.Duration) (_ string, err error) {
+	ctx, span := s.startSpan(ctx, "PresignedURL")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "PresignedURL")
+
+	url, err := s.db.PresignedGetObject(ctx, s.bucketName, name, expiry, nil)
+	if err != nil {
+		logger.WithError(err).Errorf("failed to presign URL for: %s", name)
+		return "", err
+	}
+
+	return url.String(), nil
+}
+
+func (s *imageStorage) ListObjects(ctx context.Context) (_ []ObjectInfo, err error) {
+	ctx, span := s.startSpan(ctx, "ListObjects")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "ListObjects")
+
+	var objects []ObjectInfo
+
+	for object := range s.db.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			logger.WithError(object.Err).Error("failed to list objects")
+			return nil, object.Err
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+func (s *imageStorage) Ping(ctx context.Context) (err error) {
+	ctx, span := s.startSpan(ctx, "Ping")
+	defer tracing.End(span, &err)
+
+	exists, err := s.db.BucketExists(ctx, s.bucketName)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("bucket %s doesn't exist", s.bucketName)
+	}
+
+	return nil
+}
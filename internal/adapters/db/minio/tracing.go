@@ -0,0 +1,25 @@
+package miniodb
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/nordew/UploadApp/internal/adapters/db/minio")
+
+// startSpan starts a client span for the storage method with the given name.
+func (s *imageStorage) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "miniodb."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.bucket", s.bucketName)))
+}
+
+// startSpan starts a client span for the storage method with the given name.
+func (s *archiveStorage) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "miniodb."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.bucket", s.bucketName)))
+}
@@ -3,10 +3,19 @@ package psqldb
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
 )
 
+var ErrLogNotFound = errs.New(errs.NotFound, "audit log not found")
+
 // DashboardStorage is an interface that defines methods for interacting with the
 // database to manage audit logs in a dashboard.
 type DashboardStorage interface {
@@ -14,33 +23,96 @@ type DashboardStorage interface {
 	// It takes an AuditLog entity as input and returns an error if the operation fails.
 	CreateLog(ctx context.Context, log *entity.AuditLog) error
 
-	// GetLogs retrieves all audit logs from the database.
+	// ListLogs retrieves the audit logs matching the filter.
 	// It returns a slice of AuditLog entities and an error if the retrieval fails.
-	GetLogs(ctx context.Context) ([]entity.AuditLog, error)
+	ListLogs(ctx context.Context, filter entity.AuditLogFilter) ([]entity.AuditLog, error)
+
+	// StreamLogs calls fn with each audit log matching the filter without loading them all at once.
+	// It stops at and returns the first error fn returns.
+	StreamLogs(ctx context.Context, filter entity.AuditLogFilter, fn func(log *entity.AuditLog) error) error
 
-	// DeleteLog deletes an audit log entry from the database based on the provided ID.
-	// It returns an error if the deletion operation fails.
+	// DeleteLog deletes an audit log entry from the database based on the provided ID and records the gap
+	// it leaves in the hash chain. It returns ErrLogNotFound if there is no such entry.
 	DeleteLog(ctx context.Context, id int64) error
+
+	// DeleteLogs deletes the audit log entries matching the query and records the gaps they leave in the hash chain.
+	// It returns the number of deleted entries.
+	DeleteLogs(ctx context.Context, query entity.AuditLogQuery) (int64, error)
+
+	// DeleteLogsByID works like DeleteLogs for the entries with the given IDs.
+	DeleteLogsByID(ctx context.Context, ids []int64) (int64, error)
+
+	// ActionTypes returns the distinct action types of the stored audit log entries.
+	ActionTypes(ctx context.Context) ([]string, error)
+
+	// ExpiredLogs returns up to limit of the oldest entries of the action type written before before.
+	ExpiredLogs(ctx context.Context, actionType string, before time.Time, limit int) ([]entity.AuditLog, error)
+
+	// ChainAnchors returns the start and the head of the hash chain and the gaps recorded in it.
+	ChainAnchors(ctx context.Context) (*entity.ChainAnchors, error)
+
+	// StreamChain calls fn with every audit log entry ordered by ID, the order of the hash chain.
+	// It stops at and returns the first error fn returns.
+	StreamChain(ctx context.Context, fn func(log *entity.AuditLog) error) error
 }
 
+const auditLogColumns = "id, COALESCE(user_id::text, ''), action_type, target_id, ip, user_agent, old_data, new_data, timestamp, prev_hash, hash"
+
+// lockChain locks the head of the hash chain until the transaction ends, serializing the writes to the chain.
+const lockChain = "SELECT head_hash FROM audit_log_chain FOR UPDATE"
+
 type dashboardStorage struct {
-	db     *sql.DB
-	logger *logrus.Logger
+	db        *sql.DB
+	txManager TxManager
+	logger    *logrus.Logger
 }
 
 func NewDashboardStorage(db *sql.DB, logger *logrus.Logger) *dashboardStorage {
 	return &dashboardStorage{
-		db:     db,
-		logger: logger,
+		db:        db,
+		txManager: NewTxManager(db, logger),
+		logger:    logger,
 	}
 }
 
-func (d *dashboardStorage) CreateLog(ctx context.Context, log *entity.AuditLog) error {
-	logger := d.logger.WithField("function", "CreateLog")
+// CreateLog appends the log to the hash chain and makes it the head. The hash is computed from the row as stored,
+// so the insert is followed by an update setting it.
+func (d *dashboardStorage) CreateLog(ctx context.Context, log *entity.AuditLog) (err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.CreateLog")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "CreateLog")
+
+	err = d.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var head string
+		if err := conn(ctx, d.db).QueryRowContext(ctx, lockChain).Scan(&head); err != nil {
+			return err
+		}
+
+		var stored entity.AuditLog
+
+		row := conn(ctx, d.db).QueryRowContext(ctx,
+			`INSERT INTO audit_logs (user_id, action_type, target_id, ip, user_agent, old_data, new_data, timestamp, prev_hash)
+			VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING `+auditLogColumns,
+			log.UserID, log.ActionType, log.TargetID, log.IP, log.UserAgent, nullJSON(log.OldData), nullJSON(log.NewData), log.Timestamp, head)
+		if err := scanAuditLog(row, &stored); err != nil {
+			return err
+		}
+
+		stored.Hash = stored.ChainHash()
+
+		if _, err := conn(ctx, d.db).ExecContext(ctx, "UPDATE audit_logs SET hash = $1 WHERE id = $2", stored.Hash, stored.LogID); err != nil {
+			return err
+		}
+
+		if _, err := conn(ctx, d.db).ExecContext(ctx, "UPDATE audit_log_chain SET head_id = $1, head_hash = $2", stored.LogID, stored.Hash); err != nil {
+			return err
+		}
 
-	_, err := d.db.ExecContext(ctx,
-		"INSERT INTO audit_logs (user_id, action_type, timestamp) VALUES ($1, $2, $3)",
-		log.UserID, log.ActionType, log.Timestamp)
+		*log = stored
+		return nil
+	})
 	if err != nil {
 		logger.WithError(err).Error("failed to create log")
 		return err
@@ -50,45 +122,507 @@ func (d *dashboardStorage) CreateLog(ctx context.Context, log *entity.AuditLog)
 	return nil
 }
 
-func (d *dashboardStorage) GetLogs(ctx context.Context) ([]entity.AuditLog, error) {
-	logger := d.logger.WithField("function", "GetLogs")
+func (d *dashboardStorage) ListLogs(ctx context.Context, filter entity.AuditLogFilter) (_ []entity.AuditLog, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.ListLogs")
+	defer tracing.End(span, &err)
 
 	var logs []entity.AuditLog
 
-	rows, err := d.db.QueryContext(ctx, "SELECT * FROM audit_logs")
+	err = d.StreamLogs(ctx, filter, func(log *entity.AuditLog) error {
+		logs = append(logs, *log)
+		return nil
+	})
 	if err != nil {
-		logger.WithError(err).Error("failed to retrieve logs")
 		return nil, err
 	}
+
+	return logs, nil
+}
+
+func (d *dashboardStorage) StreamLogs(ctx context.Context, filter entity.AuditLogFilter, fn func(log *entity.AuditLog) error) (err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.StreamLogs")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "StreamLogs")
+
+	conditions, args := auditLogConditions(filter.AuditLogQuery)
+
+	order, comparison := "DESC", "<"
+	if filter.Order == entity.OrderAsc {
+		order, comparison = "ASC", ">"
+	}
+
+	if filter.AfterID != 0 {
+		args = append(args, filter.AfterTimestamp, filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("(timestamp, id) %s ($%d, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM audit_logs
+		WHERE %s
+		ORDER BY timestamp %s, id %s`,
+		auditLogColumns, strings.Join(conditions, " AND "), order, order)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := conn(ctx, d.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		// A malformed user ID matches no log.
+		if IsInvalidTextRepresentationError(err) {
+			return nil
+		}
+
+		logger.WithError(err).Error("failed to retrieve logs")
+		return err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var log entity.AuditLog
-		if err := rows.Scan(&log.LogID, &log.UserID, &log.ActionType, &log.Timestamp); err != nil {
+		if err := scanAuditLog(rows, &log); err != nil {
 			logger.WithError(err).Error("failed to scan log")
-			return nil, err
+			return err
+		}
+
+		if err := fn(&log); err != nil {
+			return err
 		}
-		logs = append(logs, log)
 	}
 
 	if err := rows.Err(); err != nil {
 		logger.WithError(err).Error("error while iterating over logs")
-		return nil, err
+		return err
 	}
 
-	logger.Info("GetLogs: logs retrieved successfully")
-	return logs, nil
+	return nil
 }
 
-func (d *dashboardStorage) DeleteLog(ctx context.Context, id int64) error {
-	logger := d.logger.WithField("function", "DeleteLog")
+func (d *dashboardStorage) DeleteLog(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.DeleteLog")
+	defer tracing.End(span, &err)
 
-	_, err := d.db.ExecContext(ctx, "DELETE FROM audit_logs WHERE id = $1", id)
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "DeleteLog")
+
+	deleted, err := d.deleteLogs(ctx, "id = $1", id)
 	if err != nil {
 		logger.WithError(err).Error("failed to delete log")
 		return err
 	}
 
+	if deleted == 0 {
+		return fmt.Errorf("%w: %d", ErrLogNotFound, id)
+	}
+
 	logger.Info("DeleteLog: log deleted successfully")
 	return nil
 }
+
+func (d *dashboardStorage) DeleteLogs(ctx context.Context, query entity.AuditLogQuery) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.DeleteLogs")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "DeleteLogs")
+
+	conditions, args := auditLogConditions(query)
+
+	deleted, err := d.deleteLogs(ctx, strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return 0, nil
+		}
+
+		logger.WithError(err).Error("failed to delete logs")
+		return 0, err
+	}
+
+	logger.Infof("DeleteLogs: %d logs deleted", deleted)
+	return deleted, nil
+}
+
+func (d *dashboardStorage) DeleteLogsByID(ctx context.Context, ids []int64) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.DeleteLogsByID")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "DeleteLogsByID")
+
+	deleted, err := d.deleteLogs(ctx, "id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		logger.WithError(err).Error("failed to delete logs")
+		return 0, err
+	}
+
+	logger.Infof("DeleteLogsByID: %d logs deleted", deleted)
+	return deleted, nil
+}
+
+// chainLink is the ID and hash of a log, what the next log in the chain links to.
+type chainLink struct {
+	id   int64
+	hash string
+}
+
+// deleteLogs deletes the logs matching the condition and records the gaps they leave in the hash chain
+// in the same transaction, holding the head so no log is appended meanwhile.
+func (d *dashboardStorage) deleteLogs(ctx context.Context, condition string, args ...interface{}) (int64, error) {
+	var deleted []chainLink
+
+	err := d.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := conn(ctx, d.db).ExecContext(ctx, lockChain); err != nil {
+			return err
+		}
+
+		rows, err := conn(ctx, d.db).QueryContext(ctx, "DELETE FROM audit_logs WHERE "+condition+" RETURNING id, hash", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var link chainLink
+			if err := rows.Scan(&link.id, &link.hash); err != nil {
+				return err
+			}
+
+			deleted = append(deleted, link)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(deleted) == 0 {
+			return nil
+		}
+
+		return d.recordGaps(ctx, deleted)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(deleted)), nil
+}
+
+// deletedLog is a log deleted from the hash chain together with the remaining log it followed.
+type deletedLog struct {
+	id        int
+	hash      string
+	afterID   int
+	afterHash string
+}
+
+// recordGaps records the deleted logs as gaps in the hash chain, see mergeGaps.
+func (d *dashboardStorage) recordGaps(ctx context.Context, deleted []chainLink) error {
+	ids := make([]int64, len(deleted))
+	hashes := make(map[int]string, len(deleted))
+
+	for i, link := range deleted {
+		ids[i] = link.id
+		hashes[int(link.id)] = link.hash
+	}
+
+	// The remaining log each deleted one followed, 0 at the start of the chain.
+	rows, err := conn(ctx, d.db).QueryContext(ctx, `
+		SELECT deleted.id, COALESCE(prev.id, 0), COALESCE(prev.hash, '')
+		FROM unnest($1::bigint[]) AS deleted (id)
+		LEFT JOIN LATERAL (
+			SELECT id, hash FROM audit_logs WHERE id < deleted.id ORDER BY id DESC LIMIT 1
+		) prev ON TRUE`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	logs := make([]deletedLog, 0, len(deleted))
+	keys := append([]int64(nil), ids...)
+
+	for rows.Next() {
+		var log deletedLog
+		if err := rows.Scan(&log.id, &log.afterID, &log.afterHash); err != nil {
+			return err
+		}
+
+		log.hash = hashes[log.id]
+		logs = append(logs, log)
+		keys = append(keys, int64(log.afterID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	recorded, err := d.loadGaps(ctx, "after_id = ANY($1)", pq.Array(keys))
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn(ctx, d.db).ExecContext(ctx, "DELETE FROM audit_log_gaps WHERE after_id = ANY($1)", pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, gap := range mergeGaps(logs, recorded) {
+		_, err := conn(ctx, d.db).ExecContext(ctx, `
+			INSERT INTO audit_log_gaps (after_id, after_hash, through_id, through_hash, deleted)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (after_id) DO UPDATE
+			SET after_hash = EXCLUDED.after_hash, through_id = EXCLUDED.through_id, through_hash = EXCLUDED.through_hash,
+				deleted = EXCLUDED.deleted, updated_at = now()`,
+			gap.AfterID, gap.AfterHash, gap.ThroughID, gap.ThroughHash, gap.Deleted)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeGaps returns the gaps the deleted logs leave in the hash chain, given the gaps recorded so far.
+// The deleted logs following the same remaining log form one gap after it. Gaps recorded after that log or after
+// one of the deleted logs are merged into it, so every remaining log is followed by at most one gap and the
+// recorded gaps after deleted logs can be dropped.
+func mergeGaps(deleted []deletedLog, recorded map[int]entity.ChainGap) []entity.ChainGap {
+	gaps := make(map[int]*entity.ChainGap)
+	var order []int
+
+	for _, log := range deleted {
+		gap, ok := gaps[log.afterID]
+		if !ok {
+			gap = &entity.ChainGap{AfterID: log.afterID, AfterHash: log.afterHash}
+			if before, ok := recorded[log.afterID]; ok {
+				gap.Deleted = before.Deleted
+			}
+
+			gaps[log.afterID] = gap
+			order = append(order, log.afterID)
+		}
+
+		if log.id > gap.ThroughID {
+			gap.ThroughID, gap.ThroughHash = log.id, log.hash
+		}
+
+		gap.Deleted++
+	}
+
+	for _, log := range deleted {
+		inner, ok := recorded[log.id]
+		if !ok {
+			continue
+		}
+
+		gap := gaps[log.afterID]
+		gap.Deleted += inner.Deleted
+
+		// The gap after the last deleted log continues the new one.
+		if log.id == gap.ThroughID {
+			gap.ThroughID, gap.ThroughHash = inner.ThroughID, inner.ThroughHash
+		}
+	}
+
+	merged := make([]entity.ChainGap, 0, len(order))
+	for _, afterID := range order {
+		merged = append(merged, *gaps[afterID])
+	}
+
+	return merged
+}
+
+// loadGaps returns the gaps recorded in the hash chain matching the condition, keyed by the log they follow.
+func (d *dashboardStorage) loadGaps(ctx context.Context, condition string, args ...interface{}) (map[int]entity.ChainGap, error) {
+	rows, err := conn(ctx, d.db).QueryContext(ctx,
+		"SELECT after_id, after_hash, through_id, through_hash, deleted FROM audit_log_gaps WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := make(map[int]entity.ChainGap)
+
+	for rows.Next() {
+		var gap entity.ChainGap
+		if err := rows.Scan(&gap.AfterID, &gap.AfterHash, &gap.ThroughID, &gap.ThroughHash, &gap.Deleted); err != nil {
+			return nil, err
+		}
+
+		gaps[gap.AfterID] = gap
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gaps, nil
+}
+
+func (d *dashboardStorage) ActionTypes(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.ActionTypes")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "ActionTypes")
+
+	rows, err := conn(ctx, d.db).QueryContext(ctx, "SELECT DISTINCT action_type FROM audit_logs ORDER BY action_type")
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve action types")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actionTypes []string
+
+	for rows.Next() {
+		var actionType string
+		if err := rows.Scan(&actionType); err != nil {
+			logger.WithError(err).Error("failed to scan action type")
+			return nil, err
+		}
+
+		actionTypes = append(actionTypes, actionType)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over action types")
+		return nil, err
+	}
+
+	return actionTypes, nil
+}
+
+func (d *dashboardStorage) ExpiredLogs(ctx context.Context, actionType string, before time.Time, limit int) (_ []entity.AuditLog, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.ExpiredLogs")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "ExpiredLogs")
+
+	var logs []entity.AuditLog
+
+	err = d.streamQuery(ctx, func(log *entity.AuditLog) error {
+		logs = append(logs, *log)
+		return nil
+	}, "SELECT "+auditLogColumns+" FROM audit_logs WHERE action_type = $1 AND timestamp < $2 ORDER BY id LIMIT $3",
+		actionType, before, limit)
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve expired logs")
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+func (d *dashboardStorage) ChainAnchors(ctx context.Context) (_ *entity.ChainAnchors, err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.ChainAnchors")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "ChainAnchors")
+
+	var anchors entity.ChainAnchors
+
+	err = conn(ctx, d.db).QueryRowContext(ctx, "SELECT start_id, head_id, head_hash FROM audit_log_chain").
+		Scan(&anchors.StartID, &anchors.HeadID, &anchors.HeadHash)
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve chain head")
+		return nil, err
+	}
+
+	anchors.Gaps, err = d.loadGaps(ctx, "TRUE")
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve chain gaps")
+		return nil, err
+	}
+
+	return &anchors, nil
+}
+
+func (d *dashboardStorage) StreamChain(ctx context.Context, fn func(log *entity.AuditLog) error) (err error) {
+	ctx, span := startSpan(ctx, "dashboardStorage.StreamChain")
+	defer tracing.End(span, &err)
+
+	return d.streamQuery(ctx, fn, "SELECT "+auditLogColumns+" FROM audit_logs ORDER BY id")
+}
+
+// streamQuery calls fn with each audit log the query returns, stopping at the first error fn returns.
+func (d *dashboardStorage) streamQuery(ctx context.Context, fn func(log *entity.AuditLog) error, query string, args ...interface{}) error {
+	logger := logging.FromContext(ctx, d.logger).WithField("function", "streamQuery")
+
+	rows, err := conn(ctx, d.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve logs")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log entity.AuditLog
+		if err := scanAuditLog(rows, &log); err != nil {
+			logger.WithError(err).Error("failed to scan log")
+			return err
+		}
+
+		if err := fn(&log); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over logs")
+		return err
+	}
+
+	return nil
+}
+
+// auditLogConditions returns the WHERE conditions selecting the logs matching query and their arguments.
+func auditLogConditions(query entity.AuditLogQuery) ([]string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	if query.UserID != "" {
+		args = append(args, query.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if query.TargetID != "" {
+		args = append(args, query.TargetID)
+		conditions = append(conditions, fmt.Sprintf("target_id = $%d", len(args)))
+	}
+
+	if len(query.ActionTypes) > 0 {
+		args = append(args, pq.Array(query.ActionTypes))
+		conditions = append(conditions, fmt.Sprintf("action_type = ANY($%d)", len(args)))
+	}
+
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", len(args)))
+	}
+
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		conditions = append(conditions, fmt.Sprintf("timestamp < $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+func scanAuditLog(row rowScanner, log *entity.AuditLog) error {
+	var oldData, newData []byte
+
+	if err := row.Scan(&log.LogID, &log.UserID, &log.ActionType, &log.TargetID, &log.IP, &log.UserAgent,
+		&oldData, &newData, &log.Timestamp, &log.PrevHash, &log.Hash); err != nil {
+		return err
+	}
+
+	log.OldData, log.NewData = oldData, newData
+	return nil
+}
+
+// nullJSON passes empty JSON documents as NULL.
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return data
+}
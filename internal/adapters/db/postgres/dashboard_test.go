@@ -0,0 +1,137 @@
+package psqldb
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChain is a hash chain of logs 1 to n whose hashes are their IDs, with the gaps recorded in it.
+type testChain struct {
+	n         int
+	remaining map[int]bool
+	gaps      map[int]entity.ChainGap
+}
+
+func newTestChain(n int) *testChain {
+	c := &testChain{n: n, remaining: make(map[int]bool), gaps: make(map[int]entity.ChainGap)}
+	for id := 1; id <= n; id++ {
+		c.remaining[id] = true
+	}
+
+	return c
+}
+
+func chainHash(id int) string {
+	if id == 0 {
+		return ""
+	}
+
+	return "h" + strconv.Itoa(id)
+}
+
+// delete deletes the logs like recordGaps: it finds the remaining log each deleted one followed, merges the gaps
+// and drops the gaps recorded after the deleted logs.
+func (c *testChain) delete(ids ...int) {
+	for _, id := range ids {
+		delete(c.remaining, id)
+	}
+
+	var deleted []deletedLog
+
+	for _, id := range ids {
+		after := 0
+		for prev := id - 1; prev > 0; prev-- {
+			if c.remaining[prev] {
+				after = prev
+				break
+			}
+		}
+
+		deleted = append(deleted, deletedLog{id: id, hash: chainHash(id), afterID: after, afterHash: chainHash(after)})
+	}
+
+	merged := mergeGaps(deleted, c.gaps)
+
+	for _, id := range ids {
+		delete(c.gaps, id)
+	}
+
+	for _, gap := range merged {
+		c.gaps[gap.AfterID] = gap
+	}
+}
+
+// verify walks the remaining logs like the chain verification: every log links to the one written before it,
+// following the gap after the previous remaining log, and the chain ends at log n.
+func (c *testChain) verify(t *testing.T) {
+	t.Helper()
+
+	var ids []int
+	for id := range c.remaining {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	next := func(prevID int) int {
+		gap, ok := c.gaps[prevID]
+		if !ok {
+			return prevID
+		}
+
+		require.Equal(t, chainHash(prevID), gap.AfterHash, "gap after %d", prevID)
+		require.Equal(t, chainHash(gap.ThroughID), gap.ThroughHash, "gap after %d", prevID)
+		return gap.ThroughID
+	}
+
+	prevID := 0
+	for _, id := range ids {
+		require.Equal(t, id-1, next(prevID), "link of log %d", id)
+		prevID = id
+	}
+
+	require.Equal(t, c.n, next(prevID), "end of the chain")
+
+	var deleted int64
+	for _, gap := range c.gaps {
+		deleted += gap.Deleted
+	}
+
+	assert.Equal(t, int64(c.n-len(ids)), deleted, "deleted logs")
+}
+
+func TestMergeGaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		deletions [][]int
+	}{
+		{name: "single log", deletions: [][]int{{4}}},
+		{name: "first log", deletions: [][]int{{1}}},
+		{name: "tail", deletions: [][]int{{9, 10}}},
+		{name: "every log", deletions: [][]int{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}},
+		{name: "scattered logs of a type", deletions: [][]int{{2, 5, 6, 9}}},
+		{name: "log before a gap", deletions: [][]int{{5}, {4}}},
+		{name: "log after a gap", deletions: [][]int{{4}, {5}}},
+		{name: "logs around a gap", deletions: [][]int{{5}, {4, 6}}},
+		{name: "joining two gaps", deletions: [][]int{{3}, {5}, {4}}},
+		{name: "tail after a gap", deletions: [][]int{{8}, {9, 10}}},
+		{name: "everything left after gaps", deletions: [][]int{{2, 7}, {1, 3, 4, 5, 6, 8, 9, 10}}},
+		{name: "many rounds", deletions: [][]int{{3, 7}, {1}, {8, 10}, {2, 4}, {9}, {5, 6}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChain(10)
+
+			for _, ids := range tt.deletions {
+				c.delete(ids...)
+				c.verify(t)
+			}
+		})
+	}
+}
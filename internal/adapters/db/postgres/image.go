@@ -0,0 +1,308 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrImageNotFound = errs.New(errs.NotFound, "image not found")
+)
+
+// ImageStorage is an interface for image metadata storage operations.
+// Image bytes live in the object storage, this storage only keeps what is needed to list and describe them.
+type ImageStorage interface {
+	// Create stores the metadata of a freshly uploaded image together with its variants.
+	Create(ctx context.Context, image *entity.ImageMeta) error
+
+	// Get retrieves the metadata of the image with the given ID.
+	// It returns ErrImageNotFound if there is no such image.
+	Get(ctx context.Context, id string) (*entity.ImageMeta, error)
+
+	// List returns metadata of the images matching the filter ordered by creation time and ID.
+	// It returns at most filter.Limit images.
+	List(ctx context.Context, filter entity.ImageFilter) ([]entity.ImageMeta, error)
+
+	// Update changes the editable metadata of the image with the given ID.
+	// It returns ErrImageNotFound if there is no such image.
+	Update(ctx context.Context, id string, update entity.ImageUpdate) error
+
+	// SetVariants replaces the stored variants of the image with the given ID.
+	// It returns ErrImageNotFound if there is no such image.
+	SetVariants(ctx context.Context, id string, variants []entity.ImageVariant) error
+
+	// Delete removes the metadata of the image with the given ID.
+	// It returns ErrImageNotFound if there is no such image.
+	Delete(ctx context.Context, id string) error
+}
+
+const imageColumns = "id, user_id, title, description, format, width, height, variants, created_at"
+
+type imageVariantRow struct {
+	Size   int    `json:"size"`
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int64  `json:"bytes"`
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type imageStorage struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewImageStorage(db *sql.DB, logger *logrus.Logger) *imageStorage {
+	return &imageStorage{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *imageStorage) Create(ctx context.Context, image *entity.ImageMeta) (err error) {
+	ctx, span := startSpan(ctx, "imageStorage.Create")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Create")
+
+	marshalledVariants, err := marshalVariants(image.Variants)
+	if err != nil {
+		logger.WithError(err).Error("failed to marshal image variants")
+		return err
+	}
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO images (id, user_id, title, description, format, width, height, variants, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		image.ID, image.UserID, image.Title, image.Description, image.Format, image.Width, image.Height, marshalledVariants, image.CreatedAt)
+	if err != nil {
+		logger.WithError(err).Error("failed to insert image")
+		return err
+	}
+
+	return nil
+}
+
+func (s *imageStorage) Get(ctx context.Context, id string) (_ *entity.ImageMeta, err error) {
+	ctx, span := startSpan(ctx, "imageStorage.Get")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Get")
+
+	row := conn(ctx, s.db).QueryRowContext(ctx, "SELECT "+imageColumns+" FROM images WHERE id = $1", id)
+
+	image, err := scanImage(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return nil, fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to get image")
+		return nil, err
+	}
+
+	return image, nil
+}
+
+func (s *imageStorage) List(ctx context.Context, filter entity.ImageFilter) (_ []entity.ImageMeta, err error) {
+	ctx, span := startSpan(ctx, "imageStorage.List")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "List")
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if filter.Format != "" {
+		args = append(args, filter.Format)
+		conditions = append(conditions, fmt.Sprintf("format = $%d", len(args)))
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		args = append(args, filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at > $%d", len(args)))
+	}
+
+	order, comparison := "DESC", "<"
+	if filter.Order == entity.OrderAsc {
+		order, comparison = "ASC", ">"
+	}
+
+	if filter.AfterID != "" {
+		args = append(args, filter.AfterCreatedAt, filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) %s ($%d, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM images
+		WHERE %s
+		ORDER BY created_at %s, id %s
+		LIMIT $%d`,
+		imageColumns, strings.Join(conditions, " AND "), order, order, len(args))
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.WithError(err).Error("failed to list images")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []entity.ImageMeta
+
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			logger.WithError(err).Error("failed to scan image")
+			return nil, err
+		}
+
+		images = append(images, *image)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over images")
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (s *imageStorage) Update(ctx context.Context, id string, update entity.ImageUpdate) (err error) {
+	ctx, span := startSpan(ctx, "imageStorage.Update")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Update")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE images
+		SET title = COALESCE($1, title), description = COALESCE($2, description)
+		WHERE id = $3`,
+		update.Title, update.Description, id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to update image")
+		return err
+	}
+
+	return checkImageAffected(result, id)
+}
+
+func (s *imageStorage) SetVariants(ctx context.Context, id string, variants []entity.ImageVariant) (err error) {
+	ctx, span := startSpan(ctx, "imageStorage.SetVariants")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "SetVariants")
+
+	marshalledVariants, err := marshalVariants(variants)
+	if err != nil {
+		logger.WithError(err).Error("failed to marshal image variants")
+		return err
+	}
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "UPDATE images SET variants = $1 WHERE id = $2", marshalledVariants, id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to set image variants")
+		return err
+	}
+
+	return checkImageAffected(result, id)
+}
+
+func (s *imageStorage) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "imageStorage.Delete")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Delete")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "DELETE FROM images WHERE id = $1", id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to delete image")
+		return err
+	}
+
+	return checkImageAffected(result, id)
+}
+
+func marshalVariants(variants []entity.ImageVariant) ([]byte, error) {
+	rows := make([]imageVariantRow, 0, len(variants))
+	for _, v := range variants {
+		rows = append(rows, imageVariantRow{
+			Size:   v.Size,
+			Key:    v.Key,
+			Width:  v.Width,
+			Height: v.Height,
+			Bytes:  v.Bytes,
+		})
+	}
+
+	return json.Marshal(rows)
+}
+
+func checkImageAffected(result sql.Result, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrImageNotFound, id)
+	}
+
+	return nil
+}
+
+func scanImage(row rowScanner) (*entity.ImageMeta, error) {
+	var (
+		image    entity.ImageMeta
+		variants []byte
+	)
+
+	if err := row.Scan(&image.ID, &image.UserID, &image.Title, &image.Description, &image.Format,
+		&image.Width, &image.Height, &variants, &image.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	var variantRows []imageVariantRow
+	if err := json.Unmarshal(variants, &variantRows); err != nil {
+		return nil, err
+	}
+
+	for _, v := range variantRows {
+		image.Variants = append(image.Variants, entity.ImageVariant{
+			Size:   v.Size,
+			Key:    v.Key,
+			Width:  v.Width,
+			Height: v.Height,
+			Bytes:  v.Bytes,
+		})
+	}
+
+	return &image, nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE users (
+    id              UUID PRIMARY KEY,
+    name            TEXT NOT NULL,
+    email           TEXT NOT NULL UNIQUE,
+    password        TEXT NOT NULL,
+    photos_uploaded INTEGER NOT NULL DEFAULT 0,
+    role            TEXT NOT NULL DEFAULT 'user',
+    refresh_token   TEXT,
+    registered_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX users_refresh_token_idx ON users (refresh_token);
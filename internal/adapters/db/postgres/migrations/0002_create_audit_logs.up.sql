@@ -0,0 +1,9 @@
+CREATE TABLE audit_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     UUID NOT NULL,
+    action_type TEXT NOT NULL,
+    timestamp   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_logs_user_id_idx ON audit_logs (user_id);
+CREATE INDEX audit_logs_timestamp_idx ON audit_logs (timestamp);
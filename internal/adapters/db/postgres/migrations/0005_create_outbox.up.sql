@@ -0,0 +1,20 @@
+CREATE TABLE outbox (
+    id         UUID PRIMARY KEY,
+    queue      TEXT NOT NULL,
+    headers    JSONB NOT NULL DEFAULT '{}',
+    body       BYTEA NOT NULL,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at    TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (created_at) WHERE sent_at IS NULL;
+CREATE INDEX outbox_sent_at_idx ON outbox (sent_at) WHERE sent_at IS NOT NULL;
+
+CREATE TABLE processed_messages (
+    idempotency_key TEXT PRIMARY KEY,
+    processed_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX processed_messages_processed_at_idx ON processed_messages (processed_at);
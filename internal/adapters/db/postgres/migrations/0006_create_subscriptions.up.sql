@@ -0,0 +1,30 @@
+CREATE TABLE plans (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    tier        INTEGER NOT NULL UNIQUE,
+    price_cents BIGINT NOT NULL DEFAULT 0,
+    currency    TEXT NOT NULL DEFAULT 'usd',
+    interval    TEXT NOT NULL DEFAULT 'month'
+);
+
+INSERT INTO plans (id, name, tier, price_cents) VALUES
+    ('free', 'Free', 0, 0),
+    ('pro', 'Pro', 1, 900),
+    ('business', 'Business', 2, 2900);
+
+CREATE TABLE subscriptions (
+    id                   UUID PRIMARY KEY,
+    user_id              UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    plan_id              TEXT NOT NULL REFERENCES plans (id),
+    status               TEXT NOT NULL DEFAULT 'active',
+    current_period_start TIMESTAMPTZ NOT NULL,
+    current_period_end   TIMESTAMPTZ NOT NULL,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (current_period_end > current_period_start)
+);
+
+-- A user has at most one subscription in the active state.
+CREATE UNIQUE INDEX subscriptions_user_id_active_idx ON subscriptions (user_id) WHERE status = 'active';
+CREATE INDEX subscriptions_user_id_created_at_idx ON subscriptions (user_id, created_at);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS upload_reservations;
+
+DROP TABLE IF EXISTS monthly_uploads;
+
+ALTER TABLE users DROP COLUMN IF EXISTS bytes_stored;
+
+ALTER TABLE plans
+    DROP COLUMN IF EXISTS max_images_per_month,
+    DROP COLUMN IF EXISTS max_storage_bytes,
+    DROP COLUMN IF EXISTS max_file_bytes,
+    DROP COLUMN IF EXISTS max_dimension,
+    DROP COLUMN IF EXISTS allowed_formats;
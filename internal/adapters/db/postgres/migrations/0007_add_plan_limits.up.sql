@@ -0,0 +1,51 @@
+-- A limit of 0 means unlimited.
+ALTER TABLE plans
+    ADD COLUMN max_images_per_month INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN max_storage_bytes    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN max_file_bytes       BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN max_dimension        INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN allowed_formats      TEXT[] NOT NULL DEFAULT '{jpeg}';
+
+UPDATE plans SET max_images_per_month = 50, max_storage_bytes = 100 * 1024 * 1024,
+    max_file_bytes = 5 * 1024 * 1024, max_dimension = 4096, allowed_formats = '{jpeg}'
+WHERE id = 'free';
+
+UPDATE plans SET max_images_per_month = 1000, max_storage_bytes = 10::bigint * 1024 * 1024 * 1024,
+    max_file_bytes = 20 * 1024 * 1024, max_dimension = 8192, allowed_formats = '{jpeg,png}'
+WHERE id = 'pro';
+
+UPDATE plans SET max_images_per_month = 10000, max_storage_bytes = 100::bigint * 1024 * 1024 * 1024,
+    max_file_bytes = 50 * 1024 * 1024, max_dimension = 16384, allowed_formats = '{jpeg,png,gif}'
+WHERE id = 'business';
+
+ALTER TABLE users ADD COLUMN bytes_stored BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users u SET bytes_stored = (
+    SELECT COALESCE(SUM((v ->> 'bytes')::bigint), 0)
+    FROM images i, jsonb_array_elements(i.variants) v
+    WHERE i.user_id = u.id
+);
+
+CREATE TABLE monthly_uploads (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    month   DATE NOT NULL,
+    uploads INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, month)
+);
+
+INSERT INTO monthly_uploads (user_id, month, uploads)
+SELECT user_id, date_trunc('month', created_at AT TIME ZONE 'UTC')::date, count(*)
+FROM images
+GROUP BY 1, 2;
+
+-- Quota held by upload jobs which are queued but not processed yet. The ID is the one of the job's outbox message.
+-- A reservation stops counting once the job's deadline passed, the consumer abandons the job by then.
+CREATE TABLE upload_reservations (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    bytes      BIGINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX upload_reservations_user_id_idx ON upload_reservations (user_id, expires_at);
@@ -0,0 +1,16 @@
+CREATE TABLE payments (
+    id           UUID PRIMARY KEY,
+    -- Payments are kept when their user is deleted.
+    user_id      UUID REFERENCES users (id) ON DELETE SET NULL,
+    plan_id      TEXT NOT NULL REFERENCES plans (id),
+    kind         TEXT NOT NULL,
+    provider     TEXT NOT NULL,
+    provider_id  TEXT NOT NULL UNIQUE,
+    amount_cents BIGINT NOT NULL,
+    currency     TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX payments_user_id_created_at_idx ON payments (user_id, created_at);
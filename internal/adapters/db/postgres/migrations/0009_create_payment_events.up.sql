@@ -0,0 +1,6 @@
+-- Webhook events already processed, so redeliveries are ignored.
+CREATE TABLE payment_events (
+    id          TEXT PRIMARY KEY,
+    type        TEXT NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+-- The customer every user is registered as with each payment provider.
+CREATE TABLE billing_customers (
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider    TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, provider)
+);
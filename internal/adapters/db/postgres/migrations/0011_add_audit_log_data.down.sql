@@ -0,0 +1,3 @@
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS new_data,
+    DROP COLUMN IF EXISTS old_data;
@@ -0,0 +1,4 @@
+-- The state of the changed record before and after the logged action.
+ALTER TABLE audit_logs
+    ADD COLUMN old_data JSONB,
+    ADD COLUMN new_data JSONB;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS payments_created_at_idx;
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_refunded_cents_check,
+    DROP COLUMN IF EXISTS refunded_cents;
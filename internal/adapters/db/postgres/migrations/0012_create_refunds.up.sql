@@ -0,0 +1,17 @@
+ALTER TABLE payments
+    ADD COLUMN refunded_cents BIGINT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT payments_refunded_cents_check CHECK (refunded_cents BETWEEN 0 AND amount_cents);
+
+-- Refunds issued by admins, a payment may be refunded in several parts.
+CREATE TABLE refunds (
+    id           UUID PRIMARY KEY,
+    payment_id   UUID NOT NULL REFERENCES payments (id),
+    provider_id  TEXT NOT NULL UNIQUE,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    reason       TEXT NOT NULL DEFAULT '',
+    created_by   UUID NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refunds_payment_id_idx ON refunds (payment_id);
+CREATE INDEX payments_created_at_idx ON payments (created_at, id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS audit_logs_target_id_idx;
+
+DELETE FROM audit_logs WHERE user_id IS NULL;
+
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS target_id,
+    ALTER COLUMN user_id SET NOT NULL;
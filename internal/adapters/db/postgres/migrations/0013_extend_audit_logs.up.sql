@@ -0,0 +1,8 @@
+-- user_id is the actor, NULL for actions without one like failed sign-ins and provider events.
+ALTER TABLE audit_logs
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN target_id  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX audit_logs_target_id_idx ON audit_logs (target_id) WHERE target_id <> '';
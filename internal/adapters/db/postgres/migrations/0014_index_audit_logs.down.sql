@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS audit_logs_action_type_timestamp_idx;
+DROP INDEX IF EXISTS audit_logs_timestamp_id_idx;
+
+CREATE INDEX audit_logs_timestamp_idx ON audit_logs (timestamp);
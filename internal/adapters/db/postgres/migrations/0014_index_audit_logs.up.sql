@@ -0,0 +1,4 @@
+DROP INDEX audit_logs_timestamp_idx;
+
+CREATE INDEX audit_logs_timestamp_id_idx ON audit_logs (timestamp, id);
+CREATE INDEX audit_logs_action_type_timestamp_idx ON audit_logs (action_type, timestamp);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_log_gaps;
+DROP TABLE IF EXISTS audit_log_chain;
+
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS hash,
+    DROP COLUMN IF EXISTS prev_hash;
@@ -0,0 +1,29 @@
+-- The audit logs form a single hash chain in ID order.
+ALTER TABLE audit_logs
+    ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN hash      TEXT NOT NULL DEFAULT '';
+
+-- The head of the chain: the last log written, so deleting logs at the end shows. start_id is the last log
+-- written before the chain existed, logs up to it have no hash and can't be verified.
+CREATE TABLE audit_log_chain (
+    id        BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    start_id  BIGINT NOT NULL,
+    head_id   BIGINT NOT NULL,
+    head_hash TEXT NOT NULL
+);
+
+INSERT INTO audit_log_chain (start_id, head_id, head_hash)
+SELECT COALESCE(max(id), 0), COALESCE(max(id), 0), ''
+FROM audit_logs;
+
+-- Logs deleted from the chain, by retention or by admins: every log after after_id up to and including through_id
+-- is gone. after_id is the log the gap follows, 0 at the start of the chain, and after_hash its hash;
+-- the log after the gap links to through_hash.
+CREATE TABLE audit_log_gaps (
+    after_id     BIGINT PRIMARY KEY,
+    after_hash   TEXT NOT NULL,
+    through_id   BIGINT NOT NULL,
+    through_hash TEXT NOT NULL,
+    deleted      BIGINT NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
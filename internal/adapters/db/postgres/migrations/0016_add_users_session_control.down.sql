@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_registered_at_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_reset_required,
+    DROP COLUMN IF EXISTS sessions_revoked_at;
@@ -0,0 +1,6 @@
+-- Access and refresh tokens issued before sessions_revoked_at are rejected.
+ALTER TABLE users
+    ADD COLUMN sessions_revoked_at     TIMESTAMPTZ,
+    ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX users_registered_at_idx ON users (registered_at, id);
@@ -0,0 +1,9 @@
+// Package migrations holds the versioned Postgres schema of the application.
+package migrations
+
+import "embed"
+
+// FS contains the migration scripts, see pkg/migrate for the naming scheme.
+//
+//go:embed *.sql
+var FS embed.FS
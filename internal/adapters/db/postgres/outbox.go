@@ -0,0 +1,206 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxStorage is an interface for the transactional outbox of queue messages and
+// the record of messages already processed by consumers.
+type OutboxStorage interface {
+	// Add stores a message to be published. Called within a transaction the message is only
+	// published if the transaction commits.
+	Add(ctx context.Context, msg *entity.OutboxMessage) error
+
+	// Pending returns at most limit unsent messages, oldest first. Called within a transaction the
+	// returned rows stay locked until it ends and are skipped by concurrent callers.
+	Pending(ctx context.Context, limit int) ([]entity.OutboxMessage, error)
+
+	// MarkSent records that the message with the given ID was confirmed by the broker.
+	MarkSent(ctx context.Context, id string) error
+
+	// MarkFailed records a failed attempt to publish the message with the given ID.
+	MarkFailed(ctx context.Context, id string, reason string) error
+
+	// DeleteSentBefore deletes the messages sent before the given time.
+	// It returns the number of deleted messages.
+	DeleteSentBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// MarkProcessed records that the message with the given idempotency key was processed.
+	// It returns false if the key was already recorded.
+	MarkProcessed(ctx context.Context, key string) (bool, error)
+
+	// DeleteProcessedBefore forgets the idempotency keys recorded before the given time.
+	// It returns the number of deleted keys.
+	DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxStorage struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewOutboxStorage(db *sql.DB, logger *logrus.Logger) *outboxStorage {
+	return &outboxStorage{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *outboxStorage) Add(ctx context.Context, msg *entity.OutboxMessage) (err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.Add")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Add")
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		logger.WithError(err).Error("failed to marshal headers")
+		return fmt.Errorf("failed to marshal headers: %w", err)
+	}
+
+	err = conn(ctx, s.db).QueryRowContext(ctx,
+		"INSERT INTO outbox (id, queue, headers, body) VALUES ($1, $2, $3, $4) RETURNING created_at",
+		msg.ID, msg.Queue, headers, msg.Body).Scan(&msg.CreatedAt)
+	if err != nil {
+		logger.WithError(err).Error("failed to add message")
+		return err
+	}
+
+	return nil
+}
+
+func (s *outboxStorage) Pending(ctx context.Context, limit int) (_ []entity.OutboxMessage, err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.Pending")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Pending")
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx,
+		`SELECT id, queue, headers, body, attempts, created_at FROM outbox
+		WHERE sent_at IS NULL ORDER BY created_at LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		logger.WithError(err).Error("failed to retrieve pending messages")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []entity.OutboxMessage
+
+	for rows.Next() {
+		var (
+			msg     entity.OutboxMessage
+			headers []byte
+		)
+
+		if err := rows.Scan(&msg.ID, &msg.Queue, &headers, &msg.Body, &msg.Attempts, &msg.CreatedAt); err != nil {
+			logger.WithError(err).Error("failed to scan message")
+			return nil, err
+		}
+
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			logger.WithError(err).Error("failed to unmarshal headers")
+			return nil, fmt.Errorf("failed to unmarshal headers of message %s: %w", msg.ID, err)
+		}
+
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over messages")
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s *outboxStorage) MarkSent(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.MarkSent")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "MarkSent")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE outbox SET sent_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1", id)
+	if err != nil {
+		logger.WithError(err).Error("failed to mark message sent")
+		return err
+	}
+
+	return nil
+}
+
+func (s *outboxStorage) MarkFailed(ctx context.Context, id string, reason string) (err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.MarkFailed")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "MarkFailed")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1", id, reason)
+	if err != nil {
+		logger.WithError(err).Error("failed to mark message failed")
+		return err
+	}
+
+	return nil
+}
+
+func (s *outboxStorage) DeleteSentBefore(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.DeleteSentBefore")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "DeleteSentBefore")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "DELETE FROM outbox WHERE sent_at < $1", before)
+	if err != nil {
+		logger.WithError(err).Error("failed to delete sent messages")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *outboxStorage) MarkProcessed(ctx context.Context, key string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.MarkProcessed")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "MarkProcessed")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx,
+		"INSERT INTO processed_messages (idempotency_key) VALUES ($1) ON CONFLICT DO NOTHING", key)
+	if err != nil {
+		logger.WithError(err).Error("failed to mark message processed")
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted == 1, nil
+}
+
+func (s *outboxStorage) DeleteProcessedBefore(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "outboxStorage.DeleteProcessedBefore")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "DeleteProcessedBefore")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "DELETE FROM processed_messages WHERE processed_at < $1", before)
+	if err != nil {
+		logger.WithError(err).Error("failed to delete processed messages")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
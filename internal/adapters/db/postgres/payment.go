@@ -0,0 +1,445 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPaymentNotFound  = errs.New(errs.NotFound, "payment not found")
+	ErrCustomerNotFound = errs.New(errs.NotFound, "billing customer not found")
+	ErrRefundTooLarge   = errs.New(errs.Validation, "refund exceeds what's left of the payment")
+)
+
+// PaymentStorage is an interface for the record of payments started with payment providers.
+type PaymentStorage interface {
+	// Create stores a new payment.
+	Create(ctx context.Context, payment *entity.Payment) error
+
+	// Get retrieves the payment with the given ID.
+	// It returns ErrPaymentNotFound if there is no such payment.
+	Get(ctx context.Context, id string) (*entity.Payment, error)
+
+	// GetForUpdate works like Get and keeps concurrent transactions from changing the payment or locking it
+	// until the transaction of ctx ends.
+	GetForUpdate(ctx context.Context, id string) (*entity.Payment, error)
+
+	// ListByUser returns the payments of the user, newest first.
+	ListByUser(ctx context.Context, userID string) ([]entity.Payment, error)
+
+	// List returns at most filter.Limit payments matching the filter, newest first.
+	List(ctx context.Context, filter entity.PaymentFilter) ([]entity.Payment, error)
+
+	// SetStatus changes the status of the payment with the given ID.
+	// It returns ErrPaymentNotFound if there is no such payment.
+	SetStatus(ctx context.Context, id, status string) error
+
+	// RecordEvent records that the provider event with the given ID was processed.
+	// It returns false if the event was already recorded.
+	RecordEvent(ctx context.Context, id, eventType string) (bool, error)
+
+	// GetCustomer returns the ID the provider knows the user as.
+	// It returns ErrCustomerNotFound if the user isn't registered with the provider.
+	GetCustomer(ctx context.Context, userID, provider string) (string, error)
+
+	// SaveCustomer stores the ID the provider knows the user as, unless one is stored already.
+	// It returns the stored ID, which differs from customerID if another request saved one first.
+	SaveCustomer(ctx context.Context, userID, provider, customerID string) (string, error)
+
+	// RecordRefund stores the refund and adds its amount to the refunded amount of the payment,
+	// marking it partially or fully refunded. It returns the updated payment.
+	// It returns ErrPaymentNotFound if there is no such payment and ErrRefundTooLarge if the refunds
+	// would add up to more than the payment.
+	RecordRefund(ctx context.Context, refund *entity.Refund) (*entity.Payment, error)
+
+	// ListRefundsByUser returns the refunds of the user's payments, newest first.
+	ListRefundsByUser(ctx context.Context, userID string) ([]entity.Refund, error)
+}
+
+const (
+	paymentColumns = "id, user_id, plan_id, kind, provider, provider_id, amount_cents, refunded_cents, currency, status, created_at, updated_at"
+	refundColumns  = "id, payment_id, provider_id, amount_cents, reason, created_by, created_at"
+)
+
+type paymentStorage struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewPaymentStorage(db *sql.DB, logger *logrus.Logger) *paymentStorage {
+	return &paymentStorage{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *paymentStorage) Create(ctx context.Context, payment *entity.Payment) (err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.Create")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Create")
+
+	err = conn(ctx, s.db).QueryRowContext(ctx, `
+		INSERT INTO payments (id, user_id, plan_id, kind, provider, provider_id, amount_cents, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at`,
+		payment.ID, payment.UserID, payment.PlanID, payment.Kind, payment.Provider, payment.ProviderID,
+		payment.AmountCents, payment.Currency, payment.Status).Scan(&payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		logger.WithError(err).Error("failed to create payment")
+		return err
+	}
+
+	return nil
+}
+
+func (s *paymentStorage) Get(ctx context.Context, id string) (_ *entity.Payment, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.Get")
+	defer tracing.End(span, &err)
+
+	return s.get(ctx, id, "")
+}
+
+func (s *paymentStorage) GetForUpdate(ctx context.Context, id string) (_ *entity.Payment, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.GetForUpdate")
+	defer tracing.End(span, &err)
+
+	return s.get(ctx, id, " FOR UPDATE")
+}
+
+// get retrieves the payment with the given ID, lock is appended to the query.
+func (s *paymentStorage) get(ctx context.Context, id, lock string) (*entity.Payment, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "get")
+
+	var payment entity.Payment
+
+	err := scanPayment(conn(ctx, s.db).QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payments WHERE id = $1"+lock, id), &payment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return nil, fmt.Errorf("%w: %s", ErrPaymentNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to get payment")
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+func (s *paymentStorage) ListByUser(ctx context.Context, userID string) (_ []entity.Payment, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.ListByUser")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "ListByUser")
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return nil, nil
+		}
+
+		logger.WithError(err).Error("failed to list payments")
+		return nil, err
+	}
+
+	return s.scanPayments(logger, rows)
+}
+
+func (s *paymentStorage) List(ctx context.Context, filter entity.PaymentFilter) (_ []entity.Payment, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.List")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "List")
+
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if filter.PlanID != "" {
+		args = append(args, filter.PlanID)
+		conditions = append(conditions, fmt.Sprintf("plan_id = $%d", len(args)))
+	}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		args = append(args, filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at > $%d", len(args)))
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if filter.AfterID != "" {
+		args = append(args, filter.AfterCreatedAt, filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM payments
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d`,
+		paymentColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		// A malformed user ID matches no payment.
+		if IsInvalidTextRepresentationError(err) {
+			return nil, nil
+		}
+
+		logger.WithError(err).Error("failed to list payments")
+		return nil, err
+	}
+
+	return s.scanPayments(logger, rows)
+}
+
+func (s *paymentStorage) scanPayments(logger *logrus.Entry, rows *sql.Rows) ([]entity.Payment, error) {
+	defer rows.Close()
+
+	var payments []entity.Payment
+
+	for rows.Next() {
+		var payment entity.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			logger.WithError(err).Error("failed to scan payment")
+			return nil, err
+		}
+
+		payments = append(payments, payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over payments")
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+func (s *paymentStorage) SetStatus(ctx context.Context, id, status string) (err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.SetStatus")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "SetStatus")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE payments SET status = $2, updated_at = now() WHERE id = $1", id, status)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrPaymentNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to set payment status")
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrPaymentNotFound, id)
+	}
+
+	return nil
+}
+
+func (s *paymentStorage) RecordEvent(ctx context.Context, id, eventType string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.RecordEvent")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RecordEvent")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx,
+		"INSERT INTO payment_events (id, type) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, eventType)
+	if err != nil {
+		logger.WithError(err).Error("failed to record payment event")
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted == 1, nil
+}
+
+func (s *paymentStorage) GetCustomer(ctx context.Context, userID, provider string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.GetCustomer")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "GetCustomer")
+
+	var customerID string
+
+	err = conn(ctx, s.db).QueryRowContext(ctx,
+		"SELECT customer_id FROM billing_customers WHERE user_id = $1 AND provider = $2", userID, provider).Scan(&customerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return "", fmt.Errorf("%w: %s", ErrCustomerNotFound, userID)
+		}
+
+		logger.WithError(err).Error("failed to get billing customer")
+		return "", err
+	}
+
+	return customerID, nil
+}
+
+func (s *paymentStorage) SaveCustomer(ctx context.Context, userID, provider, customerID string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.SaveCustomer")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "SaveCustomer")
+
+	// The no-op update makes RETURNING yield the stored row on conflict as well.
+	err = conn(ctx, s.db).QueryRowContext(ctx, `
+		INSERT INTO billing_customers (user_id, provider, customer_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, provider) DO UPDATE SET customer_id = billing_customers.customer_id
+		RETURNING customer_id`,
+		userID, provider, customerID).Scan(&customerID)
+	if err != nil {
+		logger.WithError(err).Error("failed to save billing customer")
+		return "", err
+	}
+
+	return customerID, nil
+}
+
+func (s *paymentStorage) RecordRefund(ctx context.Context, refund *entity.Refund) (_ *entity.Payment, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.RecordRefund")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RecordRefund")
+
+	var payment entity.Payment
+
+	// Both writes happen in one statement, so the refunded amount can't drift from the refunds. The refund is
+	// only inserted if the guarded update matched the payment.
+	err = scanPayment(conn(ctx, s.db).QueryRowContext(ctx, `
+		WITH payment AS (
+			UPDATE payments
+			SET refunded_cents = refunded_cents + $4,
+				status = CASE WHEN refunded_cents + $4 >= amount_cents THEN $7 ELSE $8 END,
+				updated_at = now()
+			WHERE id = $2 AND refunded_cents + $4 <= amount_cents
+			RETURNING `+paymentColumns+`
+		), refund AS (
+			INSERT INTO refunds (id, payment_id, provider_id, amount_cents, reason, created_by)
+			SELECT $1::uuid, payment.id, $3::text, $4::bigint, $5::text, $6::uuid FROM payment
+		)
+		SELECT `+paymentColumns+` FROM payment`,
+		refund.ID, refund.PaymentID, refund.ProviderID, refund.AmountCents, refund.Reason, refund.CreatedBy,
+		entity.PaymentRefunded, entity.PaymentPartiallyRefunded), &payment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The payment is either missing or refunded too far already.
+			if _, getErr := s.get(ctx, refund.PaymentID, ""); getErr != nil {
+				return nil, getErr
+			}
+
+			return nil, fmt.Errorf("%w: %s", ErrRefundTooLarge, refund.PaymentID)
+		}
+
+		if IsInvalidTextRepresentationError(err) {
+			return nil, fmt.Errorf("%w: %s", ErrPaymentNotFound, refund.PaymentID)
+		}
+
+		logger.WithError(err).Error("failed to record refund")
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+func (s *paymentStorage) ListRefundsByUser(ctx context.Context, userID string) (_ []entity.Refund, err error) {
+	ctx, span := startSpan(ctx, "paymentStorage.ListRefundsByUser")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "ListRefundsByUser")
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+qualify("refunds", refundColumns)+`
+		FROM refunds
+		JOIN payments ON payments.id = refunds.payment_id
+		WHERE payments.user_id = $1
+		ORDER BY refunds.created_at DESC, refunds.id DESC`, userID)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return nil, nil
+		}
+
+		logger.WithError(err).Error("failed to list refunds")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []entity.Refund
+
+	for rows.Next() {
+		var refund entity.Refund
+		if err := rows.Scan(&refund.ID, &refund.PaymentID, &refund.ProviderID, &refund.AmountCents, &refund.Reason,
+			&refund.CreatedBy, &refund.CreatedAt); err != nil {
+			logger.WithError(err).Error("failed to scan refund")
+			return nil, err
+		}
+
+		refunds = append(refunds, refund)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over refunds")
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func scanPayment(row rowScanner, payment *entity.Payment) error {
+	// Payments of deleted users have no user.
+	var userID sql.NullString
+
+	err := row.Scan(&payment.ID, &userID, &payment.PlanID, &payment.Kind, &payment.Provider, &payment.ProviderID,
+		&payment.AmountCents, &payment.RefundedCents, &payment.Currency, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt)
+	payment.UserID = userID.String
+
+	return err
+}
+
+// qualify prefixes every column of a comma separated column list with table.
+func qualify(table, columns string) string {
+	qualified := strings.Split(columns, ", ")
+	for i, column := range qualified {
+		qualified[i] = table + "." + column
+	}
+
+	return strings.Join(qualified, ", ")
+}
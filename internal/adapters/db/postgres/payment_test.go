@@ -0,0 +1,58 @@
+package psqldb
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRow is a row of column values, a nil value being NULL.
+type testRow []interface{}
+
+func (r testRow) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		if scanner, ok := d.(sql.Scanner); ok {
+			if err := scanner.Scan(r[i]); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r[i]))
+	}
+
+	return nil
+}
+
+func TestScanPayment(t *testing.T) {
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		userID interface{}
+		want   string
+	}{
+		{name: "of a user", userID: "u1", want: "u1"},
+		{name: "of a deleted user", userID: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := testRow{"p1", tt.userID, "pro", entity.PaymentKindIntent, "stripe", "pi_1", int64(999), int64(0), "usd",
+				entity.PaymentSucceeded, createdAt, createdAt}
+
+			var payment entity.Payment
+			require.NoError(t, scanPayment(row, &payment))
+
+			assert.Equal(t, tt.want, payment.UserID)
+			assert.Equal(t, "p1", payment.ID)
+			assert.Equal(t, int64(999), payment.AmountCents)
+		})
+	}
+}
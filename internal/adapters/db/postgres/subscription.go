@@ -0,0 +1,210 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPlanNotFound         = errs.New(errs.NotFound, "plan not found")
+	ErrSubscriptionNotFound = errs.New(errs.NotFound, "subscription not found")
+)
+
+// SubscriptionStorage is an interface for plan and subscription storage operations.
+type SubscriptionStorage interface {
+	// GetPlan retrieves the plan with the given ID.
+	// It returns ErrPlanNotFound if there is no such plan.
+	GetPlan(ctx context.Context, id string) (*entity.Plan, error)
+
+	// ListPlans returns every plan ordered by tier.
+	ListPlans(ctx context.Context) ([]entity.Plan, error)
+
+	// Create stores a new subscription. The user must not have another subscription in the active state.
+	Create(ctx context.Context, sub *entity.Subscription) error
+
+	// GetActive retrieves the subscription of the user in the active state, whether or not its period ended.
+	// It returns ErrSubscriptionNotFound if there is none.
+	GetActive(ctx context.Context, userID string) (*entity.Subscription, error)
+
+	// GetLatest retrieves the most recently created subscription of the user.
+	// It returns ErrSubscriptionNotFound if the user never subscribed.
+	GetLatest(ctx context.Context, userID string) (*entity.Subscription, error)
+
+	// Update saves the status, period and cancellation flag of the subscription.
+	// It returns ErrSubscriptionNotFound if there is no such subscription.
+	Update(ctx context.Context, sub *entity.Subscription) error
+}
+
+const subscriptionColumns = `s.id, s.user_id, s.status, s.current_period_start, s.current_period_end,
+	s.cancel_at_period_end, s.created_at, s.updated_at, ` + planColumns
+
+const planColumns = `p.id, p.name, p.tier, p.price_cents, p.currency, p.interval,
+	p.max_images_per_month, p.max_storage_bytes, p.max_file_bytes, p.max_dimension, p.allowed_formats`
+
+type subscriptionStorage struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewSubscriptionStorage(db *sql.DB, logger *logrus.Logger) *subscriptionStorage {
+	return &subscriptionStorage{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *subscriptionStorage) GetPlan(ctx context.Context, id string) (_ *entity.Plan, err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.GetPlan")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "GetPlan")
+
+	var plan entity.Plan
+
+	err = scanPlan(conn(ctx, s.db).QueryRowContext(ctx, "SELECT "+planColumns+" FROM plans p WHERE p.id = $1", id), &plan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to get plan")
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+func (s *subscriptionStorage) ListPlans(ctx context.Context) (_ []entity.Plan, err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.ListPlans")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "ListPlans")
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, "SELECT "+planColumns+" FROM plans p ORDER BY p.tier")
+	if err != nil {
+		logger.WithError(err).Error("failed to list plans")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []entity.Plan
+
+	for rows.Next() {
+		var plan entity.Plan
+		if err := scanPlan(rows, &plan); err != nil {
+			logger.WithError(err).Error("failed to scan plan")
+			return nil, err
+		}
+
+		plans = append(plans, plan)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over plans")
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (s *subscriptionStorage) Create(ctx context.Context, sub *entity.Subscription) (err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.Create")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Create")
+
+	err = conn(ctx, s.db).QueryRowContext(ctx, `
+		INSERT INTO subscriptions (id, user_id, plan_id, status, current_period_start, current_period_end, cancel_at_period_end)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		sub.ID, sub.UserID, sub.Plan.ID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CancelAtPeriodEnd).
+		Scan(&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		logger.WithError(err).Error("failed to create subscription")
+		return err
+	}
+
+	return nil
+}
+
+func (s *subscriptionStorage) GetActive(ctx context.Context, userID string) (_ *entity.Subscription, err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.GetActive")
+	defer tracing.End(span, &err)
+
+	return s.getOne(ctx, "GetActive", "s.user_id = $1 AND s.status = 'active'", userID)
+}
+
+func (s *subscriptionStorage) GetLatest(ctx context.Context, userID string) (_ *entity.Subscription, err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.GetLatest")
+	defer tracing.End(span, &err)
+
+	return s.getOne(ctx, "GetLatest", "s.user_id = $1", userID)
+}
+
+func (s *subscriptionStorage) Update(ctx context.Context, sub *entity.Subscription) (err error) {
+	ctx, span := startSpan(ctx, "subscriptionStorage.Update")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Update")
+
+	err = conn(ctx, s.db).QueryRowContext(ctx, `
+		UPDATE subscriptions
+		SET status = $2, current_period_end = $3, cancel_at_period_end = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING updated_at`,
+		sub.ID, sub.Status, sub.CurrentPeriodEnd, sub.CancelAtPeriodEnd).Scan(&sub.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrSubscriptionNotFound, sub.ID)
+		}
+
+		logger.WithError(err).Error("failed to update subscription")
+		return err
+	}
+
+	return nil
+}
+
+// getOne returns the newest subscription matching condition.
+func (s *subscriptionStorage) getOne(ctx context.Context, function, condition string, args ...interface{}) (*entity.Subscription, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", function)
+
+	row := conn(ctx, s.db).QueryRowContext(ctx, "SELECT "+subscriptionColumns+`
+		FROM subscriptions s JOIN plans p ON p.id = s.plan_id
+		WHERE `+condition+`
+		ORDER BY s.created_at DESC
+		LIMIT 1`, args...)
+
+	var sub entity.Subscription
+
+	err := row.Scan(&sub.ID, &sub.UserID, &sub.Status, &sub.CurrentPeriodStart, &sub.CurrentPeriodEnd,
+		&sub.CancelAtPeriodEnd, &sub.CreatedAt, &sub.UpdatedAt,
+		&sub.Plan.ID, &sub.Plan.Name, &sub.Plan.Tier, &sub.Plan.PriceCents, &sub.Plan.Currency, &sub.Plan.Interval,
+		&sub.Plan.Limits.MaxImagesPerMonth, &sub.Plan.Limits.MaxStorageBytes, &sub.Plan.Limits.MaxFileBytes,
+		&sub.Plan.Limits.MaxDimension, pq.Array(&sub.Plan.Limits.AllowedFormats))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return nil, ErrSubscriptionNotFound
+		}
+
+		logger.WithError(err).Error("failed to get subscription")
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func scanPlan(row rowScanner, plan *entity.Plan) error {
+	return row.Scan(&plan.ID, &plan.Name, &plan.Tier, &plan.PriceCents, &plan.Currency, &plan.Interval,
+		&plan.Limits.MaxImagesPerMonth, &plan.Limits.MaxStorageBytes, &plan.Limits.MaxFileBytes,
+		&plan.Limits.MaxDimension, pq.Array(&plan.Limits.AllowedFormats))
+}
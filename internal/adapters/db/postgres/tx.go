@@ -0,0 +1,113 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// TxManager is an interface for running several storage calls as one unit of work.
+type TxManager interface {
+	// WithinTx runs fn in a database transaction. Storage calls made with the context passed to fn
+	// take part in the transaction, which is committed if fn returns nil and rolled back otherwise.
+	// Calls nested in another WithinTx join the outer transaction.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// WithinSnapshot runs fn in a read-only transaction: storage calls made with the context passed to fn
+	// all see the database as it was when the first of them ran. Calls nested in WithinTx join the outer
+	// transaction instead.
+	WithinSnapshot(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+type txManager struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewTxManager(db *sql.DB, logger *logrus.Logger) *txManager {
+	return &txManager{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := startSpan(ctx, "txManager.WithinTx")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, m.logger).WithField("function", "WithinTx")
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.WithError(err).Error("failed to begin transaction")
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			logger.WithError(rollbackErr).Error("failed to roll back transaction")
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.WithError(err).Error("failed to commit transaction")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (m *txManager) WithinSnapshot(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := startSpan(ctx, "txManager.WithinSnapshot")
+	defer tracing.End(span, &err)
+
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		logging.FromContext(ctx, m.logger).WithError(err).WithField("function", "WithinSnapshot").Error("failed to begin transaction")
+		return err
+	}
+
+	// Nothing was written, the transaction is only ended.
+	defer tx.Rollback()
+
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+// conn returns the transaction started by WithinTx for ctx, or db outside of a transaction.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
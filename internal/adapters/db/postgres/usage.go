@@ -0,0 +1,176 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// UsageStorage is an interface for the accounting of what users upload and store.
+type UsageStorage interface {
+	// Get returns the bytes stored by the user and the number of images uploaded in the month starting at month.
+	// It returns ErrUserNotFound if there is no such user.
+	Get(ctx context.Context, userID string, month time.Time) (*entity.Usage, error)
+
+	// RecordUpload counts an image of the given size uploaded by the user in the month starting at month.
+	RecordUpload(ctx context.Context, userID string, bytes int64, month time.Time) error
+
+	// AddBytes changes the bytes stored by the user by delta, which is negative when images are deleted.
+	AddBytes(ctx context.Context, userID string, delta int64) error
+
+	// Lock makes concurrent transactions locking the usage of the same user wait until the transaction of ctx ends.
+	// It returns ErrUserNotFound if there is no such user.
+	Lock(ctx context.Context, userID string) error
+
+	// Reserve stores the reservation, counted as pending by Get until it's released or expires.
+	// The expired reservations of the user are deleted on the way.
+	Reserve(ctx context.Context, reservation entity.UploadReservation) error
+
+	// Release deletes the reservation with the given ID, releasing a missing one is no error.
+	Release(ctx context.Context, id string) error
+}
+
+type usageStorage struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func NewUsageStorage(db *sql.DB, logger *logrus.Logger) *usageStorage {
+	return &usageStorage{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *usageStorage) Get(ctx context.Context, userID string, month time.Time) (_ *entity.Usage, err error) {
+	ctx, span := startSpan(ctx, "usageStorage.Get")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Get")
+
+	usage := &entity.Usage{
+		UserID: userID,
+		Month:  month,
+	}
+
+	err = conn(ctx, s.db).QueryRowContext(ctx, `
+		SELECT u.bytes_stored, COALESCE(m.uploads, 0), r.images, COALESCE(r.bytes, 0)
+		FROM users u
+		LEFT JOIN monthly_uploads m ON m.user_id = u.id AND m.month = $2
+		CROSS JOIN LATERAL (
+			SELECT count(*) AS images, sum(bytes) AS bytes
+			FROM upload_reservations
+			WHERE user_id = u.id AND expires_at > now()
+		) r
+		WHERE u.id = $1`, userID, month).Scan(&usage.BytesStored, &usage.ImagesThisMonth, &usage.PendingImages, &usage.PendingBytes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+
+		logger.WithError(err).Error("failed to get usage")
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func (s *usageStorage) RecordUpload(ctx context.Context, userID string, bytes int64, month time.Time) (err error) {
+	ctx, span := startSpan(ctx, "usageStorage.RecordUpload")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RecordUpload")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO monthly_uploads (user_id, month, uploads) VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, month) DO UPDATE SET uploads = monthly_uploads.uploads + 1`, userID, month)
+	if err != nil {
+		logger.WithError(err).Error("failed to count upload")
+		return err
+	}
+
+	return s.AddBytes(ctx, userID, bytes)
+}
+
+func (s *usageStorage) AddBytes(ctx context.Context, userID string, delta int64) (err error) {
+	ctx, span := startSpan(ctx, "usageStorage.AddBytes")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "AddBytes")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE users SET bytes_stored = GREATEST(bytes_stored + $2, 0) WHERE id = $1", userID, delta)
+	if err != nil {
+		logger.WithError(err).Error("failed to update stored bytes")
+		return err
+	}
+
+	return nil
+}
+
+func (s *usageStorage) Lock(ctx context.Context, userID string) (err error) {
+	ctx, span := startSpan(ctx, "usageStorage.Lock")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Lock")
+
+	// NO KEY UPDATE doesn't block the inserts of rows referencing the user.
+	var id string
+	err = conn(ctx, s.db).QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE", userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+
+		logger.WithError(err).Error("failed to lock usage")
+		return err
+	}
+
+	return nil
+}
+
+func (s *usageStorage) Reserve(ctx context.Context, reservation entity.UploadReservation) (err error) {
+	ctx, span := startSpan(ctx, "usageStorage.Reserve")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Reserve")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM upload_reservations WHERE user_id = $1 AND expires_at <= now()", reservation.UserID)
+	if err != nil {
+		logger.WithError(err).Error("failed to delete expired reservations")
+		return err
+	}
+
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"INSERT INTO upload_reservations (id, user_id, bytes, expires_at) VALUES ($1, $2, $3, $4)",
+		reservation.ID, reservation.UserID, reservation.Bytes, reservation.ExpiresAt)
+	if err != nil {
+		logger.WithError(err).Error("failed to reserve upload")
+		return err
+	}
+
+	return nil
+}
+
+func (s *usageStorage) Release(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "usageStorage.Release")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Release")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, "DELETE FROM upload_reservations WHERE id = $1", id)
+	if err != nil {
+		logger.WithError(err).Error("failed to release reservation")
+		return err
+	}
+
+	return nil
+}
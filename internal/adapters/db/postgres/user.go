@@ -8,15 +8,21 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"strings"
 )
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrFailedToMarshal = errors.New("failed to marshal user")
-	ErrFailedToInsert  = errors.New("failed to insert user")
-	ErrFailedToDecode  = errors.New("failed to decode user")
-	ErrDuplicateKey    = errors.New("user with this email already exists")
+	ErrUserNotFound       = errs.New(errs.NotFound, "user not found")
+	ErrFailedToMarshal    = errors.New("failed to marshal user")
+	ErrFailedToInsert     = errors.New("failed to insert user")
+	ErrFailedToDecode     = errors.New("failed to decode user")
+	ErrDuplicateKey       = errs.New(errs.Conflict, "user with this email already exists")
+	ErrInvalidPassword    = errs.New(errs.Unauthorized, "invalid old password")
+	ErrNoSuchRefreshToken = errs.New(errs.Unauthorized, "no such refresh token")
 )
 
 // UserStorage is an interface for user data storage operations.
@@ -33,6 +39,7 @@ type UserStorage interface {
 	CreateRefreshToken(ctx context.Context, token, id string) error
 
 	// RefreshSession updates the refresh token for a user with the specified oldToken to a newToken.
+	// It returns ErrNoSuchRefreshToken if no user has oldToken.
 	// It returns an error if the operation fails or the oldToken is not found.
 	RefreshSession(ctx context.Context, oldToken, newToken string) error
 
@@ -43,16 +50,45 @@ type UserStorage interface {
 	// IncrementPhotosUploaded increments photos_uploaded field in database
 	// It returns an error if the operation fails
 	IncrementPhotosUploaded(ctx context.Context, userId string) error
+
+	// SetRole changes the role of the user with the given ID.
+	// It returns ErrUserNotFound if there is no such user.
+	SetRole(ctx context.Context, id, role string) error
+
+	// SetDisabled disables or re-enables the account of the user with the given ID.
+	// It returns ErrUserNotFound if there is no such user.
+	SetDisabled(ctx context.Context, id string, disabled bool) error
+
+	// Search returns the users matching the filter, newest first.
+	// It returns at most filter.Limit users.
+	Search(ctx context.Context, filter entity.UserFilter) ([]entity.User, error)
+
+	// RevokeSessions invalidates every token issued to the user with the given ID so far.
+	// It returns ErrUserNotFound if there is no such user.
+	RevokeSessions(ctx context.Context, id string) error
+
+	// RequirePasswordReset revokes the sessions of the user with the given ID and keeps them from signing in
+	// until they change their password. It returns ErrUserNotFound if there is no such user.
+	RequirePasswordReset(ctx context.Context, id string) error
+
+	// Delete deletes the user with the given ID together with their data. Their payments and refunds are kept
+	// without the user.
+	// It returns ErrUserNotFound if there is no such user.
+	Delete(ctx context.Context, id string) error
 }
 
+const userColumns = "id, name, email, password, photos_uploaded, role, disabled_at IS NOT NULL, registered_at, " +
+	"password_reset_required, sessions_revoked_at"
+
 type userStorage struct {
 	db     *sql.DB
 	logger *logrus.Logger
 }
 
-func NewUserStorage(db *sql.DB) *userStorage {
+func NewUserStorage(db *sql.DB, logger *logrus.Logger) *userStorage {
 	return &userStorage{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
 
@@ -61,16 +97,36 @@ func IsDuplicateKeyError(err error) bool {
 	return ok && pqErr.Code == "23505"
 }
 
-func (s *userStorage) Create(ctx context.Context, user entity.User) error {
-	logger := s.logger.WithField("function", "Create")
+// IsInvalidTextRepresentationError reports whether the database rejected a malformed value, e.g. an invalid UUID.
+func IsInvalidTextRepresentationError(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "22P02"
+}
+
+// IsForeignKeyViolationError reports whether the database rejected a reference to a missing row.
+func IsForeignKeyViolationError(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+func (s *userStorage) Create(ctx context.Context, user entity.User) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.Create")
+	defer tracing.End(span, &err)
 
-	userId, err := uuid.NewUUID()
-	if err != nil {
-		logger.WithError(err).Error("failed to generate UUID")
-		return err
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Create")
+
+	userId := user.ID
+	if userId == "" {
+		generated, err := uuid.NewUUID()
+		if err != nil {
+			logger.WithError(err).Error("failed to generate UUID")
+			return err
+		}
+
+		userId = generated.String()
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	_, err = conn(ctx, s.db).ExecContext(ctx, `
 		INSERT INTO users (id, name, email, password, registered_at)
 		VALUES ($1, $2, $3, $4, $5)`,
 		userId, user.Name, user.Email, user.Password, user.RegisteredAt)
@@ -88,8 +144,11 @@ func (s *userStorage) Create(ctx context.Context, user entity.User) error {
 	return nil
 }
 
-func (s *userStorage) GetByCredentials(ctx context.Context, identifier string, byEmail bool) (*entity.User, error) {
-	logger := s.logger.WithField("function", "GetByCredentials")
+func (s *userStorage) GetByCredentials(ctx context.Context, identifier string, byEmail bool) (_ *entity.User, err error) {
+	ctx, span := startSpan(ctx, "userStorage.GetByCredentials")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "GetByCredentials")
 
 	var user entity.User
 
@@ -97,26 +156,18 @@ func (s *userStorage) GetByCredentials(ctx context.Context, identifier string, b
 	var args []interface{}
 
 	if byEmail {
-		query = `
-			SELECT id, name, email, password, photos_uploaded, role
-			FROM users
-			WHERE email = $1
-		`
+		query = `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 		args = append(args, identifier)
 	} else {
-		query = `
-			SELECT id, name, email, password, photos_uploaded, role
-			FROM users
-			WHERE id = $1
-		`
+		query = `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 		args = append(args, identifier)
 	}
 
-	row := s.db.QueryRowContext(ctx, query, args...)
+	row := conn(ctx, s.db).QueryRowContext(ctx, query, args...)
 
-	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.PhotosUploaded, &user.Role); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	if err := scanUser(row, &user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || IsInvalidTextRepresentationError(err) {
 			logger.WithError(err).Errorf("user not found for identifier %s", identifier)
 			return nil, fmt.Errorf("%w: user not found for identifier %s", ErrUserNotFound, identifier)
 		}
@@ -127,10 +178,13 @@ func (s *userStorage) GetByCredentials(ctx context.Context, identifier string, b
 	return &user, nil
 }
 
-func (s *userStorage) CreateRefreshToken(ctx context.Context, token string, id string) error {
-	logger := s.logger.WithField("function", "CreateRefreshToken")
+func (s *userStorage) CreateRefreshToken(ctx context.Context, token string, id string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.CreateRefreshToken")
+	defer tracing.End(span, &err)
 
-	_, err := s.db.ExecContext(ctx, "UPDATE users SET refresh_token = $1 WHERE id = $2;", token, id)
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "CreateRefreshToken")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, "UPDATE users SET refresh_token = $1 WHERE id = $2;", token, id)
 	if err != nil {
 		logger.WithError(err).Error("failed to create refresh token")
 		return err
@@ -139,36 +193,57 @@ func (s *userStorage) CreateRefreshToken(ctx context.Context, token string, id s
 	return nil
 }
 
-func (s *userStorage) RefreshSession(ctx context.Context, oldToken string, newToken string) error {
-	logger := s.logger.WithField("function", "RefreshSession")
+func (s *userStorage) RefreshSession(ctx context.Context, oldToken string, newToken string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.RefreshSession")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RefreshSession")
 
-	_, err := s.db.ExecContext(ctx, "UPDATE users SET refresh_token = $1 WHERE refresh_token = $2;", oldToken, newToken)
+	result, err := conn(ctx, s.db).ExecContext(ctx, "UPDATE users SET refresh_token = $1 WHERE refresh_token = $2;", newToken, oldToken)
 	if err != nil {
-		logger.WithError(err).Error("no such refresh token")
-		return fmt.Errorf("no such refresh token")
+		logger.WithError(err).Error("failed to refresh session")
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		logger.WithError(err).Error("failed to get affected rows")
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoSuchRefreshToken
 	}
 
 	return nil
 }
 
-func (s *userStorage) ChangePassword(ctx context.Context, email, old, new string) error {
-	logger := s.logger.WithField("function", "ChangePassword")
+func (s *userStorage) ChangePassword(ctx context.Context, email, old, new string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.ChangePassword")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "ChangePassword")
 
 	var dbPassword string
 
-	row := s.db.QueryRowContext(ctx, "SELECT password FROM users WHERE email = $1", email)
+	row := conn(ctx, s.db).QueryRowContext(ctx, "SELECT password FROM users WHERE email = $1", email)
 
 	if err := row.Scan(&dbPassword); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+
 		logger.WithError(err).Error("failed to get password from database")
 		return err
 	}
 
 	if dbPassword != old {
 		logger.Error("invalid old password")
-		return fmt.Errorf("invalid old password")
+		return ErrInvalidPassword
 	}
 
-	_, err := s.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE email = $2", new, email)
+	_, err = conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE users SET password = $1, password_reset_required = FALSE WHERE email = $2", new, email)
 	if err != nil {
 		logger.WithError(err).Error("failed to change password")
 		return err
@@ -177,10 +252,13 @@ func (s *userStorage) ChangePassword(ctx context.Context, email, old, new string
 	return nil
 }
 
-func (s *userStorage) IncrementPhotosUploaded(ctx context.Context, userId string) error {
-	logger := s.logger.WithField("function", "IncrementPhotosUploaded")
+func (s *userStorage) IncrementPhotosUploaded(ctx context.Context, userId string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.IncrementPhotosUploaded")
+	defer tracing.End(span, &err)
 
-	_, err := s.db.ExecContext(ctx, "UPDATE users SET photos_uploaded = photos_uploaded + 1 WHERE id = $1;", userId)
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "IncrementPhotosUploaded")
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, "UPDATE users SET photos_uploaded = photos_uploaded + 1 WHERE id = $1;", userId)
 	if err != nil {
 		logger.WithError(err).Error("failed to increment photos uploaded count")
 		return err
@@ -188,3 +266,197 @@ func (s *userStorage) IncrementPhotosUploaded(ctx context.Context, userId string
 
 	return nil
 }
+
+func (s *userStorage) SetRole(ctx context.Context, id, role string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.SetRole")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "SetRole")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		logger.WithError(err).Error("failed to set role")
+		return err
+	}
+
+	return checkUserAffected(result, id)
+}
+
+func (s *userStorage) SetDisabled(ctx context.Context, id string, disabled bool) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.SetDisabled")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "SetDisabled")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE users
+		SET disabled_at = CASE WHEN $1::boolean THEN COALESCE(disabled_at, now()) END
+		WHERE id = $2`,
+		disabled, id)
+	if err != nil {
+		logger.WithError(err).Error("failed to set disabled")
+		return err
+	}
+
+	return checkUserAffected(result, id)
+}
+
+func (s *userStorage) Search(ctx context.Context, filter entity.UserFilter) (_ []entity.User, err error) {
+	ctx, span := startSpan(ctx, "userStorage.Search")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Search")
+
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(name ILIKE $%d OR email ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+
+	switch filter.Status {
+	case entity.UserActive:
+		conditions = append(conditions, "disabled_at IS NULL")
+	case entity.UserSuspended:
+		conditions = append(conditions, "disabled_at IS NOT NULL")
+	}
+
+	if filter.AfterID != "" {
+		args = append(args, filter.AfterRegisteredAt, filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("(registered_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM users
+		WHERE %s
+		ORDER BY registered_at DESC, id DESC
+		LIMIT $%d`,
+		userColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.WithError(err).Error("failed to search users")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+
+	for rows.Next() {
+		var user entity.User
+		if err := scanUser(rows, &user); err != nil {
+			logger.WithError(err).Error("failed to scan user")
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithError(err).Error("error while iterating over users")
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (s *userStorage) RevokeSessions(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.RevokeSessions")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RevokeSessions")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx,
+		"UPDATE users SET sessions_revoked_at = now(), refresh_token = NULL WHERE id = $1", id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to revoke sessions")
+		return err
+	}
+
+	return checkUserAffected(result, id)
+}
+
+func (s *userStorage) RequirePasswordReset(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.RequirePasswordReset")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "RequirePasswordReset")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE users
+		SET password_reset_required = TRUE, sessions_revoked_at = now(), refresh_token = NULL
+		WHERE id = $1`,
+		id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to require password reset")
+		return err
+	}
+
+	return checkUserAffected(result, id)
+}
+
+func (s *userStorage) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "userStorage.Delete")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Delete")
+
+	result, err := conn(ctx, s.db).ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		if IsInvalidTextRepresentationError(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+		}
+
+		logger.WithError(err).Error("failed to delete user")
+		return err
+	}
+
+	return checkUserAffected(result, id)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so the value matches literally.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func checkUserAffected(result sql.Result, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+
+	return nil
+}
+
+func scanUser(row rowScanner, user *entity.User) error {
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.PhotosUploaded, &user.Role,
+		&user.Disabled, &user.RegisteredAt, &user.PasswordResetRequired, &revokedAt); err != nil {
+		return err
+	}
+
+	user.SessionsRevokedAt = revokedAt.Time
+	return nil
+}
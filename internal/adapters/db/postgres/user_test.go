@@ -0,0 +1,65 @@
+package psqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// execDB is a database accepting only statements without results. It records their arguments and reports
+// rowsAffected rows for each.
+type execDB struct {
+	rowsAffected int64
+	args         [][]driver.Value
+}
+
+func (d *execDB) Connect(context.Context) (driver.Conn, error) { return execConn{d}, nil }
+func (d *execDB) Driver() driver.Driver                        { return nil }
+
+type execConn struct{ db *execDB }
+
+func (c execConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c execConn) Close() error                        { return nil }
+func (c execConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c execConn) ExecContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	c.db.args = append(c.db.args, values)
+	return driver.RowsAffected(c.db.rowsAffected), nil
+}
+
+func newTestUserStorage(t *testing.T, rowsAffected int64) (*userStorage, *execDB) {
+	d := &execDB{rowsAffected: rowsAffected}
+
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return NewUserStorage(db, logger), d
+}
+
+func TestUserStorageRefreshSession(t *testing.T) {
+	s, d := newTestUserStorage(t, 1)
+
+	require.NoError(t, s.RefreshSession(context.Background(), "old", "new"))
+	// The stored old token is replaced with the new one.
+	assert.Equal(t, [][]driver.Value{{"new", "old"}}, d.args)
+}
+
+func TestUserStorageRefreshSessionUnknownToken(t *testing.T) {
+	s, _ := newTestUserStorage(t, 0)
+
+	assert.ErrorIs(t, s.RefreshSession(context.Background(), "old", "new"), ErrNoSuchRefreshToken)
+}
@@ -2,84 +2,157 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
 	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
 	"github.com/nordew/UploadApp/internal/config"
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
+	"github.com/nordew/UploadApp/internal/controller/http/probe"
 	v1 "github.com/nordew/UploadApp/internal/controller/http/v1"
+	v2 "github.com/nordew/UploadApp/internal/controller/http/v2"
 	controller "github.com/nordew/UploadApp/internal/controller/rabbit"
 	"github.com/nordew/UploadApp/internal/controller/server"
 	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/metrics"
 	"github.com/nordew/UploadApp/pkg/auth"
-	"github.com/nordew/UploadApp/pkg/client/minio"
-	"github.com/nordew/UploadApp/pkg/client/psql"
 	"github.com/nordew/UploadApp/pkg/client/rabbit"
 	"github.com/nordew/UploadApp/pkg/hasher"
+	"github.com/nordew/UploadApp/pkg/health"
 	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 )
 
-func Run() error {
+// Run serves the HTTP API and consumes image jobs until SIGINT or SIGTERM.
+func Run(cfg *config.Config) (err error) {
 	logger := logging.NewLogger()
 
-	cfg, err := config.NewConfig("main", "yml", "./configs")
+	lc := newLifecycle(logger)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+
+		if shutdownErr := lc.shutdown(ctx); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shut down: %w", shutdownErr))
+		}
+	}()
+
+	shutdownTracing, err := tracing.New(context.Background(), tracing.Config{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+		Insecure: cfg.Tracing.Insecure,
+	})
 	if err != nil {
-		logger.Error("failed to create config: ", err)
-		return fmt.Errorf("failed to create config: %w", err)
+		logger.Error("failed to set up tracing: ", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
 
-	postgresClient, err := psql.NewPostgres(&psql.ConnectionInfo{
-		Host:     cfg.PGHost,
-		Port:     cfg.PGPort,
-		User:     cfg.PGUser,
-		DBName:   cfg.PGDBName,
-		SSLMode:  cfg.PGSSLMode,
-		Password: cfg.PGPassword,
-	})
+	lc.onShutdown("tracing", shutdownTracing)
 
+	postgresClient, err := connectPostgres(cfg)
 	if err != nil {
 		logger.Error("failed to connect to postgres: ", err)
 		return fmt.Errorf("failed to connect to postgres: %w", err)
 	}
 
-	minioClient, err := minio.NewMinioClient(cfg.MinioHost, cfg.MinioUser, cfg.MinioPassword, false, cfg.MinioPort)
+	lc.onShutdown("postgres", func(ctx context.Context) error {
+		return postgresClient.Close()
+	})
+
+	if cfg.Postgres.AutoMigrate {
+		if err := migrateUp(context.Background(), postgresClient, logger); err != nil {
+			logger.Error("failed to migrate database: ", err)
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(postgresClient, "postgres")
+
+	minioClient, err := connectStorage(cfg)
 	if err != nil {
 		logger.Error("failed to connect to minio: ", err)
 		return fmt.Errorf("failed to connect to minio: %w", err)
 	}
 
-	userStorage := psqldb.NewUserStorage(postgresClient)
-	imageStorage := miniodb.NewImageStorage(minioClient, "images", logger)
+	userStorage := psqldb.NewUserStorage(postgresClient, logger)
+	imageStorage := miniodb.NewImageStorage(minioClient, cfg.Storage.Bucket, logger)
 	dashboardStorage := psqldb.NewDashboardStorage(postgresClient, logger)
+	imageMetadataStorage := psqldb.NewImageStorage(postgresClient, logger)
+	outboxStorage := psqldb.NewOutboxStorage(postgresClient, logger)
+	subscriptionStorage := psqldb.NewSubscriptionStorage(postgresClient, logger)
+	usageStorage := psqldb.NewUsageStorage(postgresClient, logger)
+	paymentStorage := psqldb.NewPaymentStorage(postgresClient, logger)
+	txManager := psqldb.NewTxManager(postgresClient, logger)
+
+	hasher := hasher.NewPasswordHasher(cfg.Auth.Salt)
+	authenticator := auth.NewAuth(cfg.Auth.Secret, logger)
+
+	auditWriter := service.NewAuditWriter(dashboardStorage, logger, appMetrics, service.AuditWriterOptions{
+		BufferSize:   cfg.Audit.BufferSize,
+		WriteTimeout: cfg.Audit.WriteTimeout,
+	})
+	go auditWriter.Run()
 
-	hasher := hasher.NewPasswordHasher(cfg.Salt)
-	authenticator := auth.NewAuth(logger)
+	// Registered early, so it's shut down after everything that records audit logs.
+	lc.onShutdown("audit writer", auditWriter.Close)
+
+	imageService := service.NewImageService(imageStorage, imageMetadataStorage, usageStorage, txManager, auditWriter, logger, appMetrics)
+	userService := service.NewUserService(userStorage, hasher, authenticator, auditWriter, logger)
+	dashboardService := service.NewDashboardService(dashboardStorage, txManager, logger)
+	outboxService := service.NewOutboxService(outboxStorage)
+	subscriptionService := service.NewSubscriptionService(subscriptionStorage, txManager, logger)
+	quotaService := service.NewQuotaService(usageStorage, subscriptionService, txManager)
+
+	paymentProvider, err := newPaymentProvider(cfg, logger)
+	if err != nil {
+		logger.Error("failed to create payment provider: ", err)
+		return fmt.Errorf("failed to create payment provider: %w", err)
+	}
 
-	imageService := service.NewImageService(imageStorage, logger)
-	userService := service.NewUserService(userStorage, hasher, authenticator, logger, cfg.Secret)
-	dashboardService := service.NewDashboardService(dashboardStorage)
+	paymentService := service.NewPaymentService(paymentStorage, subscriptionService, userService, paymentProvider,
+		auditWriter, txManager, service.PaymentOptions{
+			SuccessURL: cfg.Payment.SuccessURL,
+			CancelURL:  cfg.Payment.CancelURL,
+		}, logger)
+	billingService := service.NewBillingService(paymentStorage, subscriptionService, userService, auditWriter,
+		paymentProvider, txManager, logger)
+	accountService := service.NewAccountService(userStorage, userService, imageService, quotaService, subscriptionService, auditWriter, logger)
 
-	conn, err := rabbit.NewRabbitClient(cfg.Rabbit)
+	conn, err := rabbit.NewRabbitClient(cfg.AMQP.URL)
 	if err != nil {
 		logger.Error("failed to connect to rabbit: ", err)
 		return fmt.Errorf("failed to connect to rabbit: %w", err)
 	}
 
+	lc.onShutdown("rabbit connection", func(ctx context.Context) error {
+		return conn.Close()
+	})
+
 	channel, err := conn.Channel()
 	if err != nil {
 		logger.Error("failed to open channel: ", err)
 		return fmt.Errorf("failed to open channel: %w", err)
 	}
 
+	lc.onShutdown("rabbit channel", func(ctx context.Context) error {
+		return channel.Close()
+	})
+
 	q, err := channel.QueueDeclare(
-		"image", // name
-		false,   // durable
-		false,   // delete when unused
-		false,   // exclusive
-		false,   // no-wait
-		nil,     // arguments
+		cfg.AMQP.Queue, // name
+		true,           // durable
+		false,          // delete when unused
+		false,          // exclusive
+		false,          // no-wait
+		nil,            // arguments
 	)
 
 	if err != nil {
@@ -87,33 +160,166 @@ func Run() error {
 		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
 
-	consumer := controller.NewConsumer(channel, q, logger, imageService, dashboardService, userService)
+	consumer := controller.NewConsumer(channel, q, logger, imageService, dashboardService, userService, outboxService, quotaService, txManager, appMetrics)
 
 	go func() {
-		if err := consumer.Consume(ctx); err != nil {
-			logger.Error("failed to consume")
+		defer close(consumerDone)
+
+		if err := consumer.Consume(consumerCtx); err != nil {
+			logger.Error("failed to consume: ", err)
 		}
 	}()
 
-	handler := v1.NewHandler(userService, imageService, dashboardService, logger, channel, authenticator)
-	router := handler.Init()
+	lc.onShutdown("rabbit consumer", func(ctx context.Context) error {
+		stopConsumer()
+
+		select {
+		case <-consumerDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	relayChannel, err := conn.Channel()
+	if err != nil {
+		logger.Error("failed to open relay channel: ", err)
+		return fmt.Errorf("failed to open relay channel: %w", err)
+	}
+
+	lc.onShutdown("rabbit relay channel", func(ctx context.Context) error {
+		return relayChannel.Close()
+	})
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+
+	relay := controller.NewRelay(relayChannel, outboxService, txManager, logger, appMetrics, controller.RelayOptions{
+		Interval:       cfg.Outbox.RelayInterval,
+		BatchSize:      cfg.Outbox.BatchSize,
+		ConfirmTimeout: cfg.Outbox.ConfirmTimeout,
+		Retention:      cfg.Outbox.Retention,
+	})
 
 	go func() {
-		if err := server.Run(router, cfg.ServerPort); err != nil {
-			logger.Error("failed to run router: ", err)
-			cancel()
+		defer close(relayDone)
+
+		if err := relay.Run(relayCtx); err != nil {
+			logger.Error("failed to relay outbox: ", err)
 		}
 	}()
 
+	lc.onShutdown("outbox relay", func(ctx context.Context) error {
+		stopRelay()
+
+		select {
+		case <-relayDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	retentionDone := make(chan struct{})
+
+	retention := newAuditRetention(cfg, dashboardStorage, txManager, minioClient, logger, appMetrics)
+
+	go func() {
+		defer close(retentionDone)
+
+		if err := retention.Run(retentionCtx); err != nil {
+			logger.Error("failed to prune audit logs: ", err)
+		}
+	}()
+
+	lc.onShutdown("audit retention", func(ctx context.Context) error {
+		stopRetention()
+
+		select {
+		case <-retentionDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	timeouts := middleware.Timeouts{
+		Default: cfg.Server.RequestTimeout,
+		Routes:  cfg.Server.RouteTimeouts,
+	}
+
+	handler := v1.NewHandler(userService, imageService, dashboardService, subscriptionService, quotaService, paymentService, billingService, accountService, logger, outboxService, authenticator, timeouts, v1.UploadOptions{
+		Queue:          cfg.AMQP.Queue,
+		JobTimeout:     cfg.Images.JobTimeout,
+		MaxUploadBytes: cfg.Images.MaxUploadBytes,
+	}, v1.PaymentOptions{
+		Simulate: cfg.Payment.Simulate,
+	}, appMetrics)
+	router := handler.Init()
+
+	v2.NewHandler(imageService, userService, logger, authenticator).Init(router)
+
+	checks := health.New(cfg.Server.HealthCheckTimeout,
+		health.NewChecker("postgres", postgresClient.PingContext),
+		health.NewChecker("storage", imageStorage.Ping),
+		health.NewChecker("rabbit", func(ctx context.Context) error {
+			if conn.IsClosed() {
+				return errors.New("connection is closed")
+			}
+
+			return nil
+		}),
+	)
+
+	probe.NewHandler(checks).Init(router)
+
+	srv := server.NewServer(router, net.JoinHostPort("", strconv.Itoa(cfg.Server.Port)))
+	// Metrics are served on their own port, which isn't meant to be exposed publicly.
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", appMetrics.Handler())
+	metricsSrv := server.NewServer(metricsMux, net.JoinHostPort("", strconv.Itoa(cfg.Server.MetricsPort)))
+	serverErr := make(chan error, 2)
+
+	go func() {
+		serverErr <- srv.Run()
+	}()
+
+	go func() {
+		serverErr <- metricsSrv.Run()
+	}()
+
+	// Shutdown steps run in reverse, metrics stay available until the http server drained.
+	lc.onShutdown("metrics server", metricsSrv.Shutdown)
+	lc.onShutdown("http server", srv.Shutdown)
+
+	lc.onShutdown("readiness", func(ctx context.Context) error {
+		checks.SetReady(false)
+
+		// Load balancers only notice on their next probe, requests keep being served until then.
+		select {
+		case <-time.After(cfg.Server.DrainDelay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
 
 	select {
 	case <-sigCh:
 		logger.Info("Received signal. Shutting down...")
-		cancel()
+	case err := <-serverErr:
+		if err != nil {
+			logger.Error("failed to run router: ", err)
+			return fmt.Errorf("failed to run router: %w", err)
+		}
 	}
 
 	return nil
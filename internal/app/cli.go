@@ -0,0 +1,360 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/config"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/pkg/auth"
+	"github.com/nordew/UploadApp/pkg/hasher"
+	"github.com/nordew/UploadApp/pkg/logging"
+)
+
+const usage = `Usage: uploadapp [--config path] <command> [arguments]
+
+The configuration is read from --config or ` + config.DefaultPath + ` and can be overridden
+with ` + config.EnvPrefix + `_<SECTION>_<KEY> environment variables, e.g. ` + config.EnvPrefix + `_POSTGRES_PASSWORD.
+
+Commands:
+  serve                                         run the HTTP server and the image consumer (default)
+  migrate [up | down [steps] | status]          manage the database schema
+  user create -name N -email E -password P [-admin]
+  user promote [-role admin] <email>            change the role of a user
+  user disable [-enable] <email>                disable or re-enable a user account
+  user list
+  images reprocess <image-id>...                render the variants of images again from their originals
+  images gc [-older-than 24h] [-dry-run]        remove stored objects which belong to no image
+  logs prune [-older-than d]                    archive and delete expired audit logs
+  config check                                  validate the configuration and print it with secrets redacted
+`
+
+var errUsage = errors.New("invalid usage")
+
+// Main runs the command line interface with the arguments following the program name.
+func Main(args []string) error {
+	flags := flag.NewFlagSet("uploadapp", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configPath := flags.String("config", "", "path to the YAML configuration")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
+		return err
+	}
+
+	command, args := "serve", flags.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	case "config":
+		return checkConfig(*configPath, args)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "serve":
+		return Run(cfg)
+	case "migrate":
+		return Migrate(cfg, args)
+	case "user":
+		return withAdmin(cfg, func(a *admin) error { return a.runUser(args) })
+	case "images":
+		return withAdmin(cfg, func(a *admin) error { return a.runImages(args) })
+	case "logs":
+		return withAdmin(cfg, func(a *admin) error { return a.runLogs(args) })
+	default:
+		return usageError("unknown command: %s", command)
+	}
+}
+
+func usageError(format string, args ...interface{}) error {
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+// admin holds the services used by the maintenance commands.
+type admin struct {
+	users     service.Users
+	accounts  service.Accounts
+	images    service.Images
+	retention *service.AuditRetention
+	out       io.Writer
+}
+
+// withAdmin connects to the storages, runs fn and closes the connections.
+func withAdmin(cfg *config.Config, fn func(a *admin) error) error {
+	logger := logging.NewLogger()
+
+	db, err := connectPostgres(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	minioClient, err := connectStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to minio: %w", err)
+	}
+
+	dashboardStorage := psqldb.NewDashboardStorage(db, logger)
+
+	auditWriter := service.NewAuditWriter(dashboardStorage, logger, nil, service.AuditWriterOptions{
+		BufferSize:   cfg.Audit.BufferSize,
+		WriteTimeout: cfg.Audit.WriteTimeout,
+	})
+	go auditWriter.Run()
+
+	// The commands' audit logs are written before the connection is closed.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Audit.WriteTimeout)
+		defer cancel()
+
+		if err := auditWriter.Close(ctx); err != nil {
+			logger.WithError(err).Error("failed to write audit logs")
+		}
+	}()
+
+	userStorage := psqldb.NewUserStorage(db, logger)
+	usageStorage := psqldb.NewUsageStorage(db, logger)
+	txManager := psqldb.NewTxManager(db, logger)
+
+	users := service.NewUserService(userStorage, hasher.NewPasswordHasher(cfg.Auth.Salt), auth.NewAuth(cfg.Auth.Secret, logger), auditWriter, logger)
+	images := service.NewImageService(miniodb.NewImageStorage(minioClient, cfg.Storage.Bucket, logger),
+		psqldb.NewImageStorage(db, logger), usageStorage, txManager, auditWriter, logger, nil)
+	subscriptions := service.NewSubscriptionService(psqldb.NewSubscriptionStorage(db, logger), txManager, logger)
+
+	return fn(&admin{
+		users:     users,
+		accounts:  service.NewAccountService(userStorage, users, images, service.NewQuotaService(usageStorage, subscriptions, txManager), subscriptions, auditWriter, logger),
+		images:    images,
+		retention: newAuditRetention(cfg, dashboardStorage, txManager, minioClient, logger, nil),
+		out:       os.Stdout,
+	})
+}
+
+// cliActor is who the maintenance commands act as: an admin without an account.
+var cliActor = entity.Actor{Role: entity.RoleAdmin}
+
+// cliListPageSize is how many users user list reads at a time.
+const cliListPageSize = 100
+
+// cliContext returns the context of a maintenance command, its audit logs are tagged with the CLI as user agent.
+func cliContext() context.Context {
+	return service.WithRequestInfo(context.Background(), entity.RequestInfo{UserAgent: "uploadapp-cli"})
+}
+
+func (a *admin) runUser(args []string) error {
+	if len(args) == 0 {
+		return usageError("missing user command")
+	}
+
+	ctx := cliContext()
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "create":
+		flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+		name := flags.String("name", "", "user name")
+		email := flags.String("email", "", "user email")
+		password := flags.String("password", "", "user password")
+		isAdmin := flags.Bool("admin", false, "grant the admin role")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		if err := a.users.SignUp(ctx, entity.SignUpInput{Name: *name, Email: *email, Password: *password}); err != nil {
+			return err
+		}
+
+		user, err := a.users.GetCredentials(ctx, *email, true)
+		if err != nil {
+			return err
+		}
+
+		if *isAdmin {
+			if err := a.users.SetRole(ctx, user.ID, entity.RoleAdmin); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintln(a.out, user.ID)
+		return nil
+	case "promote":
+		flags := flag.NewFlagSet("user promote", flag.ContinueOnError)
+		role := flags.String("role", entity.RoleAdmin, "role to grant")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		user, err := a.userByEmail(ctx, flags.Args())
+		if err != nil {
+			return err
+		}
+
+		return a.users.SetRole(ctx, user.ID, *role)
+	case "disable":
+		flags := flag.NewFlagSet("user disable", flag.ContinueOnError)
+		enable := flags.Bool("enable", false, "re-enable the account instead")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		user, err := a.userByEmail(ctx, flags.Args())
+		if err != nil {
+			return err
+		}
+
+		_, err = a.accounts.SetSuspended(ctx, cliActor, user.ID, !*enable)
+		return err
+	case "list":
+		w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tEMAIL\tROLE\tDISABLED\tPHOTOS")
+
+		params := entity.UserListParams{Limit: cliListPageSize}
+
+		for {
+			page, err := a.accounts.List(ctx, params)
+			if err != nil {
+				return err
+			}
+
+			for _, user := range page.Users {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%d\n", user.ID, user.Name, user.Email, user.Role, user.Disabled, user.PhotosUploaded)
+			}
+
+			if page.NextCursor == "" {
+				return w.Flush()
+			}
+
+			params.Cursor = page.NextCursor
+		}
+	default:
+		return usageError("unknown user command: %s", command)
+	}
+}
+
+func (a *admin) userByEmail(ctx context.Context, args []string) (*entity.User, error) {
+	if len(args) != 1 {
+		return nil, usageError("expected exactly one email")
+	}
+
+	return a.users.GetCredentials(ctx, args[0], true)
+}
+
+func (a *admin) runImages(args []string) error {
+	if len(args) == 0 {
+		return usageError("missing images command")
+	}
+
+	ctx := cliContext()
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "reprocess":
+		if len(args) == 0 {
+			return usageError("missing image IDs")
+		}
+
+		var failed int
+
+		for _, id := range args {
+			if err := a.images.Reprocess(ctx, id); err != nil {
+				fmt.Fprintf(a.out, "%s: %v\n", id, err)
+				failed++
+				continue
+			}
+
+			fmt.Fprintf(a.out, "%s: reprocessed\n", id)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to reprocess %d of %d images", failed, len(args))
+		}
+
+		return nil
+	case "gc":
+		flags := flag.NewFlagSet("images gc", flag.ContinueOnError)
+		olderThan := flags.Duration("older-than", 24*time.Hour, "only remove objects older than this")
+		dryRun := flags.Bool("dry-run", false, "list the objects without removing them")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		removed, err := a.images.CollectGarbage(ctx, *olderThan, *dryRun)
+		for _, key := range removed {
+			fmt.Fprintln(a.out, key)
+		}
+
+		return err
+	default:
+		return usageError("unknown images command: %s", command)
+	}
+}
+
+func (a *admin) runLogs(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return usageError("expected logs prune")
+	}
+
+	flags := flag.NewFlagSet("logs prune", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 0, "delete logs of every action type older than this instead of applying the configured retention")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	policy := a.retention.Policy()
+	if *olderThan > 0 {
+		policy = entity.RetentionPolicy{Default: *olderThan}
+	}
+
+	deleted, err := a.retention.Prune(cliContext(), policy)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "%d logs deleted\n", deleted)
+	return nil
+}
+
+// checkConfig validates the configuration, reporting every problem, and prints it with secrets redacted.
+func checkConfig(path string, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return usageError("expected config check")
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, problem := range validationErr.Problems {
+				fmt.Fprintln(os.Stderr, problem)
+			}
+		}
+
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, cfg)
+	fmt.Fprintln(os.Stdout, "config OK")
+	return nil
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/config"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/metrics"
+	minioclient "github.com/nordew/UploadApp/pkg/client/minio"
+	"github.com/nordew/UploadApp/pkg/client/psql"
+	"github.com/nordew/UploadApp/pkg/payment"
+	"github.com/sirupsen/logrus"
+)
+
+func connectPostgres(cfg *config.Config) (*sql.DB, error) {
+	return psql.NewPostgres(&psql.ConnectionInfo{
+		Host:     cfg.Postgres.Host,
+		Port:     strconv.Itoa(cfg.Postgres.Port),
+		User:     cfg.Postgres.User,
+		DBName:   cfg.Postgres.DBName,
+		SSLMode:  cfg.Postgres.SSLMode,
+		Password: cfg.Postgres.Password,
+
+		MaxOpenConns:    cfg.Postgres.MaxOpenConns,
+		MaxIdleConns:    cfg.Postgres.MaxIdleConns,
+		ConnMaxLifetime: cfg.Postgres.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Postgres.ConnMaxIdleTime,
+	})
+}
+
+func connectStorage(cfg *config.Config) (*minio.Client, error) {
+	return minioclient.NewMinioClient(cfg.Storage.Host, cfg.Storage.User, cfg.Storage.Password,
+		cfg.Storage.UseSSL, strconv.Itoa(cfg.Storage.Port))
+}
+
+// newPaymentProvider returns the configured payment provider. There is no fallback, an unknown provider is an error.
+func newPaymentProvider(cfg *config.Config, logger *logrus.Logger) (payment.Provider, error) {
+	switch cfg.Payment.Provider {
+	case "stripe":
+		return payment.NewStripe(cfg.Payment.StripeSecretKey, cfg.Payment.StripeWebhookSecret), nil
+	case "fake":
+		logger.Warn("payments go to the local fake provider, nobody is charged")
+		if cfg.Payment.Simulate {
+			logger.Warn("payment simulation is enabled, anybody can complete their payments without paying")
+		}
+
+		return payment.NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Payment.Provider)
+	}
+}
+
+// newAuditRetention returns the job deleting audit logs under the configured retention policy.
+func newAuditRetention(cfg *config.Config, storage psqldb.DashboardStorage, txManager psqldb.TxManager, minioClient *minio.Client, logger *logrus.Logger, metrics *metrics.Metrics) *service.AuditRetention {
+	var archive miniodb.ArchiveStorage
+	if cfg.Audit.ArchiveBucket != "" {
+		archive = miniodb.NewArchiveStorage(minioClient, cfg.Audit.ArchiveBucket, logger)
+	}
+
+	return service.NewAuditRetention(storage, archive, txManager, logger, metrics, service.AuditRetentionOptions{
+		Policy: entity.RetentionPolicy{
+			Default:  cfg.Audit.DefaultRetention,
+			ByAction: cfg.Audit.Retention,
+		},
+		Interval:  cfg.Audit.PruneInterval,
+		BatchSize: cfg.Audit.PruneBatchSize,
+	})
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+type shutdownStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// lifecycle collects the shutdown steps of the started components.
+// Steps run in reverse registration order, so a component is stopped before the dependencies it was built on.
+type lifecycle struct {
+	logger *logrus.Logger
+	steps  []shutdownStep
+}
+
+func newLifecycle(logger *logrus.Logger) *lifecycle {
+	return &lifecycle{logger: logger}
+}
+
+// onShutdown registers fn to be run on shutdown.
+func (l *lifecycle) onShutdown(name string, fn func(ctx context.Context) error) {
+	l.steps = append(l.steps, shutdownStep{name: name, fn: fn})
+}
+
+// shutdown runs every registered step even if some of them fail and returns all of their errors.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	var errs []error
+
+	for i := len(l.steps) - 1; i >= 0; i-- {
+		step := l.steps[i]
+
+		logger := l.logger.WithField("component", step.name)
+		logger.Info("shutting down")
+
+		if err := step.fn(ctx); err != nil {
+			logger.WithError(err).Error("failed to shut down")
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		}
+	}
+
+	l.steps = nil
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/nordew/UploadApp/internal/adapters/db/postgres/migrations"
+	"github.com/nordew/UploadApp/internal/config"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/migrate"
+	"github.com/sirupsen/logrus"
+)
+
+// Migrate runs the migrate subcommand: "up" (the default), "down [steps]" or "status".
+func Migrate(cfg *config.Config, args []string) error {
+	logger := logging.NewLogger()
+
+	db, err := connectPostgres(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, migrations.FS, logger)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	ctx := context.Background()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		_, err = migrator.Up(ctx)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		_, err = migrator.Down(ctx, steps)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command: %s", command)
+	}
+}
+
+// migrateUp applies all pending migrations.
+func migrateUp(ctx context.Context, db *sql.DB, logger *logrus.Logger) error {
+	migrator, err := migrate.New(db, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+	return err
+}
@@ -1,10 +1,71 @@
 package dto
 
-type GetAllImageDTO struct {
-	ID string `json:"id"`
-}
+import (
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
 
 type GetImageBySizeDTO struct {
 	ID   string `json:"id"`
 	Size int    `json:"size"`
 }
+
+type ListImagesQuery struct {
+	Cursor       string    `form:"cursor"`
+	Limit        int       `form:"limit"`
+	Sort         string    `form:"sort"`
+	Order        string    `form:"order"`
+	Format       string    `form:"format"`
+	CreatedAfter time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type UpdateImageDTO struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+}
+
+type ImageVariantResponse struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int64  `json:"bytes"`
+	URL    string `json:"url"`
+}
+
+type ImageResponse struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Format      string                 `json:"format"`
+	Width       int                    `json:"width"`
+	Height      int                    `json:"height"`
+	Variants    []ImageVariantResponse `json:"variants"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+func NewImageResponse(img entity.ImageMeta) ImageResponse {
+	variants := make([]ImageVariantResponse, 0, len(img.Variants))
+	for _, v := range img.Variants {
+		variants = append(variants, ImageVariantResponse{
+			Name:   entity.VariantName(v.Size),
+			Size:   v.Size,
+			Width:  v.Width,
+			Height: v.Height,
+			Bytes:  v.Bytes,
+			URL:    v.URL,
+		})
+	}
+
+	return ImageResponse{
+		ID:          img.ID,
+		Title:       img.Title,
+		Description: img.Description,
+		Format:      img.Format,
+		Width:       img.Width,
+		Height:      img.Height,
+		Variants:    variants,
+		CreatedAt:   img.CreatedAt,
+	}
+}
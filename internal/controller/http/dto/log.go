@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
+// LogQuery selects audit logs, action_type can be repeated to match any of several actions.
+type LogQuery struct {
+	UserID     string    `form:"user_id"`
+	TargetID   string    `form:"target_id"`
+	ActionType []string  `form:"action_type"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// ListLogsQuery filters and pages the audit log listing.
+type ListLogsQuery struct {
+	LogQuery
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// ExportLogsQuery selects the audit logs to export and the format, csv or ndjson.
+type ExportLogsQuery struct {
+	LogQuery
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+}
+
+type AuditLogResponse struct {
+	ID         int             `json:"id"`
+	UserID     string          `json:"user_id,omitempty"`
+	ActionType string          `json:"action_type"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	OldData    json.RawMessage `json:"old_data,omitempty"`
+	NewData    json.RawMessage `json:"new_data,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditLogCSVHeader names the columns of AuditLogCSVRecord.
+var AuditLogCSVHeader = []string{"id", "user_id", "action_type", "target_id", "ip", "user_agent", "old_data", "new_data", "timestamp", "prev_hash", "hash"}
+
+func NewAuditLogResponse(log entity.AuditLog) AuditLogResponse {
+	return AuditLogResponse{
+		ID:         log.LogID,
+		UserID:     log.UserID,
+		ActionType: log.ActionType,
+		TargetID:   log.TargetID,
+		IP:         log.IP,
+		UserAgent:  log.UserAgent,
+		OldData:    log.OldData,
+		NewData:    log.NewData,
+		Timestamp:  log.Timestamp,
+		PrevHash:   log.PrevHash,
+		Hash:       log.Hash,
+	}
+}
+
+func NewAuditLogResponses(logs []entity.AuditLog) []AuditLogResponse {
+	response := make([]AuditLogResponse, 0, len(logs))
+	for _, log := range logs {
+		response = append(response, NewAuditLogResponse(log))
+	}
+
+	return response
+}
+
+// AuditLogCSVRecord returns the log as a CSV record with the columns of AuditLogCSVHeader.
+func AuditLogCSVRecord(log entity.AuditLog) []string {
+	return []string{
+		strconv.Itoa(log.LogID),
+		log.UserID,
+		log.ActionType,
+		log.TargetID,
+		log.IP,
+		log.UserAgent,
+		string(log.OldData),
+		string(log.NewData),
+		log.Timestamp.UTC().Format(time.RFC3339Nano),
+		log.PrevHash,
+		log.Hash,
+	}
+}
+
+type ChainBreakResponse struct {
+	LogID      int    `json:"log_id"`
+	ActionType string `json:"action_type"`
+	Reason     string `json:"reason"`
+}
+
+type ChainReportResponse struct {
+	Valid     bool                 `json:"valid"`
+	Checked   int64                `json:"checked"`
+	Unchained int64                `json:"unchained"`
+	Gaps      int64                `json:"gaps"`
+	Breaks    []ChainBreakResponse `json:"breaks"`
+	Truncated bool                 `json:"truncated"`
+}
+
+func NewChainReportResponse(report entity.ChainReport) ChainReportResponse {
+	breaks := make([]ChainBreakResponse, 0, len(report.Breaks))
+	for _, b := range report.Breaks {
+		breaks = append(breaks, ChainBreakResponse{LogID: b.LogID, ActionType: b.ActionType, Reason: b.Reason})
+	}
+
+	return ChainReportResponse{
+		Valid:     len(report.Breaks) == 0,
+		Checked:   report.Checked,
+		Unchained: report.Unchained,
+		Gaps:      report.Gaps,
+		Breaks:    breaks,
+		Truncated: report.Truncated,
+	}
+}
@@ -1,5 +1,133 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
 type PaymentDTO struct {
-	Amount int64 `json:"amount"`
+	PlanID string `json:"plan_id" binding:"required"`
+}
+
+// SimulatePaymentDTO completes a payment with the fake provider.
+type SimulatePaymentDTO struct {
+	PaymentID string `json:"payment_id" binding:"required"`
+	// Outcome is succeeded or failed.
+	Outcome string `json:"outcome" binding:"required,oneof=succeeded failed"`
+}
+
+// ListPaymentsQuery filters the admin payment listing.
+type ListPaymentsQuery struct {
+	UserID        string    `form:"user_id"`
+	PlanID        string    `form:"plan_id"`
+	Status        string    `form:"status"`
+	CreatedAfter  time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit"`
+}
+
+// RefundDTO refunds amount_cents of a payment, or all that's left of it if amount_cents is 0 or missing.
+type RefundDTO struct {
+	AmountCents int64  `json:"amount_cents" binding:"gte=0"`
+	Reason      string `json:"reason" binding:"omitempty,oneof=requested_by_customer duplicate fraudulent"`
+}
+
+type CancelSubscriptionDTO struct {
+	AtPeriodEnd bool `json:"at_period_end"`
+}
+
+type PaymentResponse struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	PlanID        string    `json:"plan_id"`
+	Kind          string    `json:"kind"`
+	Provider      string    `json:"provider"`
+	AmountCents   int64     `json:"amount_cents"`
+	RefundedCents int64     `json:"refunded_cents"`
+	Currency      string    `json:"currency"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type RefundResponse struct {
+	ID          string    `json:"id"`
+	PaymentID   string    `json:"payment_id"`
+	ProviderID  string    `json:"provider_id"`
+	AmountCents int64     `json:"amount_cents"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type BillingHistoryResponse struct {
+	Subscription SubscriptionResponse `json:"subscription"`
+	Payments     []PaymentResponse    `json:"payments"`
+	Refunds      []RefundResponse     `json:"refunds"`
+}
+
+type PaymentStartResponse struct {
+	Payment      PaymentResponse `json:"payment"`
+	ClientSecret string          `json:"client_secret,omitempty"`
+	CheckoutURL  string          `json:"checkout_url,omitempty"`
+}
+
+func NewPaymentResponse(p entity.Payment) PaymentResponse {
+	return PaymentResponse{
+		ID:            p.ID,
+		UserID:        p.UserID,
+		PlanID:        p.PlanID,
+		Kind:          p.Kind,
+		Provider:      p.Provider,
+		AmountCents:   p.AmountCents,
+		RefundedCents: p.RefundedCents,
+		Currency:      p.Currency,
+		Status:        p.Status,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+}
+
+func NewPaymentResponses(payments []entity.Payment) []PaymentResponse {
+	response := make([]PaymentResponse, 0, len(payments))
+	for _, p := range payments {
+		response = append(response, NewPaymentResponse(p))
+	}
+
+	return response
+}
+
+func NewRefundResponse(r entity.Refund) RefundResponse {
+	return RefundResponse{
+		ID:          r.ID,
+		PaymentID:   r.PaymentID,
+		ProviderID:  r.ProviderID,
+		AmountCents: r.AmountCents,
+		Reason:      r.Reason,
+		CreatedBy:   r.CreatedBy,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+func NewBillingHistoryResponse(history entity.BillingHistory) BillingHistoryResponse {
+	refunds := make([]RefundResponse, 0, len(history.Refunds))
+	for _, r := range history.Refunds {
+		refunds = append(refunds, NewRefundResponse(r))
+	}
+
+	return BillingHistoryResponse{
+		Subscription: NewSubscriptionResponse(history.Subscription),
+		Payments:     NewPaymentResponses(history.Payments),
+		Refunds:      refunds,
+	}
+}
+
+func NewPaymentStartResponse(start entity.PaymentStart) PaymentStartResponse {
+	return PaymentStartResponse{
+		Payment:      NewPaymentResponse(start.Payment),
+		ClientSecret: start.ClientSecret,
+		CheckoutURL:  start.CheckoutURL,
+	}
 }
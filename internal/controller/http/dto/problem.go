@@ -0,0 +1,29 @@
+package dto
+
+import "github.com/nordew/UploadApp/internal/domain/errs"
+
+// Problem is an RFC 7807 problem details response body.
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      errs.Code      `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []ProblemField `json:"errors,omitempty"`
+	Quota     *ProblemQuota  `json:"quota,omitempty"`
+}
+
+type ProblemField struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ProblemQuota tells which limit of the user's plan was exceeded, how high it is and how much was used.
+type ProblemQuota struct {
+	Plan  string `json:"plan"`
+	Limit string `json:"limit"`
+	Max   int64  `json:"max"`
+	Used  int64  `json:"used"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
+type PlanResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Tier       int    `json:"tier"`
+	PriceCents int64  `json:"price_cents"`
+	Currency   string `json:"currency"`
+	Interval   string `json:"interval"`
+	// Limits of 0 are unlimited.
+	Limits PlanLimitsResponse `json:"limits"`
+}
+
+type PlanLimitsResponse struct {
+	MaxImagesPerMonth int      `json:"max_images_per_month"`
+	MaxStorageBytes   int64    `json:"max_storage_bytes"`
+	MaxFileBytes      int64    `json:"max_file_bytes"`
+	MaxDimension      int      `json:"max_dimension"`
+	AllowedFormats    []string `json:"allowed_formats"`
+}
+
+type SubscriptionResponse struct {
+	Plan               PlanResponse `json:"plan"`
+	Status             string       `json:"status"`
+	CurrentPeriodStart *time.Time   `json:"current_period_start,omitempty"`
+	CurrentPeriodEnd   *time.Time   `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd  bool         `json:"cancel_at_period_end"`
+}
+
+func NewPlanResponse(plan entity.Plan) PlanResponse {
+	return PlanResponse{
+		ID:         plan.ID,
+		Name:       plan.Name,
+		Tier:       plan.Tier,
+		PriceCents: plan.PriceCents,
+		Currency:   plan.Currency,
+		Interval:   plan.Interval,
+		Limits: PlanLimitsResponse{
+			MaxImagesPerMonth: plan.Limits.MaxImagesPerMonth,
+			MaxStorageBytes:   plan.Limits.MaxStorageBytes,
+			MaxFileBytes:      plan.Limits.MaxFileBytes,
+			MaxDimension:      plan.Limits.MaxDimension,
+			AllowedFormats:    plan.Limits.AllowedFormats,
+		},
+	}
+}
+
+// NewSubscriptionResponse leaves the period out for the implicit free plan subscription.
+func NewSubscriptionResponse(sub entity.Subscription) SubscriptionResponse {
+	response := SubscriptionResponse{
+		Plan:              NewPlanResponse(sub.Plan),
+		Status:            sub.Status,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+	}
+
+	if !sub.CurrentPeriodStart.IsZero() {
+		response.CurrentPeriodStart = &sub.CurrentPeriodStart
+		response.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+	}
+
+	return response
+}
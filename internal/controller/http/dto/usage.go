@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
+type UsageResponse struct {
+	Plan            PlanResponse `json:"plan"`
+	Month           time.Time    `json:"month"`
+	ImagesThisMonth int          `json:"images_this_month"`
+	BytesStored     int64        `json:"bytes_stored"`
+	PendingImages   int          `json:"pending_images"`
+	PendingBytes    int64        `json:"pending_bytes"`
+}
+
+func NewUsageResponse(usage entity.Usage) UsageResponse {
+	return UsageResponse{
+		Plan:            NewPlanResponse(usage.Plan),
+		Month:           usage.Month,
+		ImagesThisMonth: usage.ImagesThisMonth,
+		BytesStored:     usage.BytesStored,
+		PendingImages:   usage.PendingImages,
+		PendingBytes:    usage.PendingBytes,
+	}
+}
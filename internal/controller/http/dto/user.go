@@ -1,5 +1,11 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
 type ChangePasswordDTO struct {
 	Email       string `json:"email"`
 	OldPassword string `json:"old_password"`
@@ -10,3 +16,74 @@ type TokensDTO struct {
 	AccessToken  string `json:"acces_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+// ListUsersQuery searches the admin user listing, q matches names and emails.
+type ListUsersQuery struct {
+	Query  string `form:"q"`
+	Role   string `form:"role" binding:"omitempty,oneof=user admin"`
+	Status string `form:"status" binding:"omitempty,oneof=active suspended"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+type SetRoleDTO struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UserResponse struct {
+	ID                    string     `json:"id"`
+	Name                  string     `json:"name"`
+	Email                 string     `json:"email"`
+	Role                  string     `json:"role"`
+	Status                string     `json:"status"`
+	PhotosUploaded        int        `json:"photos_uploaded"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	SessionsRevokedAt     *time.Time `json:"sessions_revoked_at,omitempty"`
+	RegisteredAt          time.Time  `json:"registered_at"`
+}
+
+type UserDetailsResponse struct {
+	User         UserResponse         `json:"user"`
+	Subscription SubscriptionResponse `json:"subscription"`
+	Usage        UsageResponse        `json:"usage"`
+}
+
+func NewUserResponse(user entity.User) UserResponse {
+	response := UserResponse{
+		ID:                    user.ID,
+		Name:                  user.Name,
+		Email:                 user.Email,
+		Role:                  user.Role,
+		Status:                entity.UserActive,
+		PhotosUploaded:        user.PhotosUploaded,
+		PasswordResetRequired: user.PasswordResetRequired,
+		RegisteredAt:          user.RegisteredAt,
+	}
+
+	if user.Disabled {
+		response.Status = entity.UserSuspended
+	}
+
+	if !user.SessionsRevokedAt.IsZero() {
+		response.SessionsRevokedAt = &user.SessionsRevokedAt
+	}
+
+	return response
+}
+
+func NewUserResponses(users []entity.User) []UserResponse {
+	response := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, NewUserResponse(user))
+	}
+
+	return response
+}
+
+func NewUserDetailsResponse(details entity.UserDetails) UserDetailsResponse {
+	return UserDetailsResponse{
+		User:         NewUserResponse(details.User),
+		Subscription: NewSubscriptionResponse(details.Subscription),
+		Usage:        NewUsageResponse(details.Usage),
+	}
+}
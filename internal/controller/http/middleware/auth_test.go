@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/nordew/UploadApp/pkg/auth"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthenticate(t *testing.T) {
+	issuedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	claims := &auth.ParseTokenClaimsOutput{Sub: "u1", Role: entity.RoleAdmin, IssuedAt: issuedAt}
+
+	tests := []struct {
+		name  string
+		token string
+		roles []string
+		// expect sets up the token parsing and the session check.
+		expect func(authenticator *mocks.Authenticator, users *mocks.Users)
+		code   errs.Code
+		actor  entity.Actor
+	}{
+		{
+			name:   "no token",
+			expect: func(*mocks.Authenticator, *mocks.Users) {},
+			code:   errs.Unauthorized,
+		},
+		{
+			name:  "invalid token",
+			token: "bad",
+			expect: func(authenticator *mocks.Authenticator, _ *mocks.Users) {
+				authenticator.On("ParseToken", "bad").Return(nil, jwt.ErrTokenExpired)
+			},
+			code: errs.Unauthorized,
+		},
+		{
+			name:  "revoked session",
+			token: "token",
+			expect: func(authenticator *mocks.Authenticator, users *mocks.Users) {
+				authenticator.On("ParseToken", "token").Return(claims, nil)
+				users.On("CheckSession", mock.Anything, "u1", issuedAt).Return(nil, service.ErrSessionRevoked)
+			},
+			code: errs.CodeOf(service.ErrSessionRevoked),
+		},
+		{
+			name:  "user",
+			token: "token",
+			expect: func(authenticator *mocks.Authenticator, users *mocks.Users) {
+				authenticator.On("ParseToken", "token").Return(claims, nil)
+				users.On("CheckSession", mock.Anything, "u1", issuedAt).Return(&entity.User{ID: "u1", Role: entity.RoleUser}, nil)
+			},
+			actor: entity.Actor{UserID: "u1", Role: entity.RoleUser},
+		},
+		{
+			name:  "demoted admin",
+			token: "token",
+			roles: []string{entity.RoleAdmin},
+			expect: func(authenticator *mocks.Authenticator, users *mocks.Users) {
+				// The token still says admin, the stored role counts.
+				authenticator.On("ParseToken", "token").Return(claims, nil)
+				users.On("CheckSession", mock.Anything, "u1", issuedAt).Return(&entity.User{ID: "u1", Role: entity.RoleUser}, nil)
+			},
+			code: errs.Forbidden,
+		},
+		{
+			name:  "admin",
+			token: "token",
+			roles: []string{entity.RoleAdmin},
+			expect: func(authenticator *mocks.Authenticator, users *mocks.Users) {
+				authenticator.On("ParseToken", "token").Return(claims, nil)
+				users.On("CheckSession", mock.Anything, "u1", issuedAt).Return(&entity.User{ID: "u1", Role: entity.RoleAdmin}, nil)
+			},
+			actor: entity.Actor{UserID: "u1", Role: entity.RoleAdmin},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := mocks.NewAuthenticator(t)
+			users := mocks.NewUsers(t)
+			tt.expect(authenticator, users)
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.PanicLevel)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				c.Request.Header.Set("Authorization", tt.token)
+			}
+
+			middleware.Authenticate(authenticator, users, logger, tt.roles...)(c)
+
+			actor, ok := middleware.GetActor(c)
+
+			if tt.code != "" {
+				assert.True(t, c.IsAborted())
+				assert.False(t, ok)
+				if assert.Len(t, c.Errors, 1) {
+					assert.Equal(t, tt.code, errs.CodeOf(c.Errors[0].Err))
+				}
+
+				return
+			}
+
+			assert.False(t, c.IsAborted())
+			assert.True(t, ok)
+			assert.Equal(t, tt.actor, actor)
+		})
+	}
+}
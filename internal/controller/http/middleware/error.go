@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/dto"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/sirupsen/logrus"
+)
+
+const problemContentType = "application/problem+json"
+
+var statusByCode = map[errs.Code]int{
+	errs.NotFound:      http.StatusNotFound,
+	errs.Conflict:      http.StatusConflict,
+	errs.Validation:    http.StatusBadRequest,
+	errs.Unauthorized:  http.StatusUnauthorized,
+	errs.Forbidden:     http.StatusForbidden,
+	errs.QuotaExceeded: http.StatusTooManyRequests,
+	errs.TooLarge:      http.StatusRequestEntityTooLarge,
+	errs.Timeout:       http.StatusGatewayTimeout,
+}
+
+// Errors renders the last error attached to the context with c.Error as an RFC 7807 problem response.
+// Typed domain errors are mapped to their status, anything else is logged and reported as a 500
+// without exposing its message.
+func Errors(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		logger := GetLogger(c, logger)
+
+		if errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil {
+			logger.Info("client went away before the request was served")
+			return
+		}
+
+		if c.Writer.Written() {
+			logger.WithError(err).Error("error after response was written")
+			return
+		}
+
+		problem := NewProblem(c, err)
+		if problem.Status == http.StatusInternalServerError {
+			logger.WithError(err).Error("internal server error")
+		}
+
+		body, marshalErr := json.Marshal(problem)
+		if marshalErr != nil {
+			logger.WithError(marshalErr).Error("failed to marshal problem")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.Data(problem.Status, problemContentType, body)
+	}
+}
+
+// NewProblem builds the problem details describing err for the current request.
+func NewProblem(c *gin.Context, err error) dto.Problem {
+	problem := dto.Problem{
+		Instance:  c.Request.URL.Path,
+		RequestID: GetRequestID(c),
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errs.Wrap(err, errs.Timeout, "the request took too long to complete")
+	}
+
+	var domainErr *errs.Error
+	status, ok := http.StatusInternalServerError, false
+	if errors.As(err, &domainErr) {
+		status, ok = statusByCode[domainErr.Code]
+	}
+
+	if !ok {
+		problem.Type = "/problems/" + string(errs.Internal)
+		problem.Title = http.StatusText(http.StatusInternalServerError)
+		problem.Status = http.StatusInternalServerError
+		problem.Code = errs.Internal
+		return problem
+	}
+
+	problem.Type = "/problems/" + string(domainErr.Code)
+	problem.Title = http.StatusText(status)
+	problem.Status = status
+	problem.Detail = domainErr.Message
+	problem.Code = domainErr.Code
+
+	if q := domainErr.Quota; q != nil {
+		problem.Quota = &dto.ProblemQuota{
+			Plan:  q.Plan,
+			Limit: q.Limit,
+			Max:   q.Max,
+			Used:  q.Used,
+		}
+	}
+
+	for _, f := range domainErr.Fields {
+		problem.Errors = append(problem.Errors, dto.ProblemField{
+			Field:   f.Field,
+			Message: f.Message,
+		})
+	}
+
+	return problem
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logger puts a logger tagged with the request ID, route and trace ID into the request context
+// and writes an access log line once the request is served.
+// It has to run after RequestID.
+func Logger(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		fields := logrus.Fields{
+			"request_id": GetRequestID(c),
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+		}
+
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), logger, fields))
+
+		c.Next()
+
+		entry := logging.FromContext(c.Request.Context(), logger).WithFields(logrus.Fields{
+			"status":    c.Writer.Status(),
+			"latency":   time.Since(start).String(),
+			"client_ip": c.ClientIP(),
+			"path":      c.Request.URL.Path,
+		})
+
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Error("request served")
+		case status >= http.StatusBadRequest:
+			entry.Warn("request served")
+		default:
+			entry.Info("request served")
+		}
+	}
+}
+
+// AddLogFields enriches the request logger with fields, e.g. the authenticated user.
+func AddLogFields(c *gin.Context, logger *logrus.Logger, fields logrus.Fields) {
+	c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), logger, fields))
+}
+
+// GetLogger returns the request logger set up by Logger.
+func GetLogger(c *gin.Context, fallback *logrus.Logger) *logrus.Entry {
+	return logging.FromContext(c.Request.Context(), fallback)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+)
+
+// RequestInfo puts the client's IP and user agent into the request context, so the audit logs recorded
+// while serving the request name them.
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(service.WithRequestInfo(c.Request.Context(), entity.RequestInfo{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}))
+
+		c.Next()
+	}
+}
+
+// SetRequestUser names the authenticated user as the actor of the audit logs recorded while serving the request.
+func SetRequestUser(c *gin.Context, userID string) {
+	c.Request = c.Request.WithContext(service.WithRequestUser(c.Request.Context(), userID))
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeouts holds the time budget of requests.
+// Routes maps gin route paths, e.g. "/images/upload", to their own budget, every other route gets Default.
+// A non-positive budget disables the timeout.
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// For returns the budget of the given route path.
+func (t Timeouts) For(path string) time.Duration {
+	if budget, ok := t.Routes[strings.ToLower(path)]; ok {
+		return budget
+	}
+
+	return t.Default
+}
+
+// Timeout bounds the request context by the budget of the matched route,
+// so the storage calls made while serving it are cancelled once the budget is spent.
+func Timeout(timeouts Timeouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget := timeouts.For(c.FullPath())
+		if budget <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+)
+
+// TokenError converts a token parsing failure into an unauthorized error with a client facing reason.
+func TokenError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return errs.Wrap(err, errs.Unauthorized, "invalid token signature")
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errs.Wrap(err, errs.Unauthorized, "token has expired")
+	default:
+		return errs.Wrap(err, errs.Unauthorized, "failed to parse token")
+	}
+}
@@ -0,0 +1,59 @@
+package probe
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/pkg/health"
+)
+
+type checkResponse struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Handler struct {
+	health *health.Health
+}
+
+func NewHandler(health *health.Health) *Handler {
+	return &Handler{health: health}
+}
+
+// Init registers the liveness and readiness routes.
+func (h *Handler) Init(router *gin.Engine) {
+	router.GET("/healthz", h.liveness)
+	router.GET("/readyz", h.readiness)
+}
+
+// liveness only tells that the process is able to serve requests.
+func (h *Handler) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// readiness tells whether the instance and every dependency it needs can take traffic.
+func (h *Handler) readiness(c *gin.Context) {
+	report := h.health.Check(c.Request.Context())
+
+	checks := make([]checkResponse, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		checks = append(checks, checkResponse{
+			Name:      check.Name,
+			Status:    check.Status,
+			LatencyMS: float64(check.Latency.Microseconds()) / 1000,
+			Error:     check.Error,
+		})
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"status": report.Status,
+		"checks": checks,
+	})
+}
@@ -1,8 +1,8 @@
 package v1
 
 import (
-	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
 	"github.com/nordew/UploadApp/internal/domain/entity"
 	"net/http"
 )
@@ -11,14 +11,14 @@ func (h *Handler) signUp(c *gin.Context) {
 	var input entity.SignUpInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.WithError(err).Error("signUp: invalid JSON body")
-		invalidJSONResponse(c)
+		middleware.GetLogger(c, h.logger).WithError(err).Error("signUp: invalid JSON body")
+		invalidJSONError(c, err)
 		return
 	}
 
-	if err := h.userService.SignUp(context.TODO(), input); err != nil {
-		h.logger.WithError(err).Error("signUp: failed to SignUp")
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to SignUp", err.Error())
+	if err := h.userService.SignUp(c.Request.Context(), input); err != nil {
+		middleware.GetLogger(c, h.logger).WithError(err).Error("signUp: failed to SignUp")
+		_ = c.Error(err)
 		return
 	}
 
@@ -29,15 +29,15 @@ func (h *Handler) signIn(c *gin.Context) {
 	var input entity.SignInInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.WithError(err).Error("signIn: failed to parse data")
-		invalidJSONResponse(c)
+		middleware.GetLogger(c, h.logger).WithError(err).Error("signIn: failed to parse data")
+		invalidJSONError(c, err)
 		return
 	}
 
-	accessToken, refreshToken, err := h.userService.SignIn(context.Background(), input)
+	accessToken, refreshToken, err := h.userService.SignIn(c.Request.Context(), input)
 	if err != nil {
-		h.logger.WithError(err).Error("signIn: failed to SignIn")
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to SignIn", err.Error())
+		middleware.GetLogger(c, h.logger).WithError(err).Error("signIn: failed to SignIn")
+		_ = c.Error(err)
 		return
 	}
 
@@ -45,11 +45,14 @@ func (h *Handler) signIn(c *gin.Context) {
 }
 
 func (h *Handler) refresh(c *gin.Context) {
-	claims := h.getRefreshTokenFromRequest(c)
+	token, claims := h.getRefreshTokenFromRequest(c)
+	if claims == nil {
+		return
+	}
 
-	accessToken, refreshToken, err := h.userService.Refresh(context.Background(), claims.Sub, claims.Role)
+	accessToken, refreshToken, err := h.userService.Refresh(c.Request.Context(), claims.Sub, token, claims.IssuedAt)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to generate new tokens", err.Error())
+		_ = c.Error(err)
 		return
 	}
 
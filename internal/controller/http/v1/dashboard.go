@@ -1,24 +1,414 @@
 package v1
 
 import (
-	"github.com/gin-gonic/gin"
-	"log"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/dto"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+)
+
+const (
+	defaultLogsLimit = 50
+	maxLogsLimit     = 500
+	// exportFlushEvery is how many exported logs are buffered before they are sent.
+	exportFlushEvery = 100
+
+	defaultPaymentsLimit = 20
+	maxPaymentsLimit     = 100
+
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
 )
 
 func (h *Handler) getLogs(c *gin.Context) {
-	logs, err := h.dashboardService.GetLogs(c.Request.Context())
+	var query dto.ListLogsQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultLogsLimit
+	}
+
+	if query.Limit < 0 || query.Limit > maxLogsLimit {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxLogsLimit)}))
+		return
+	}
+
+	page, err := h.dashboardService.Logs(c.Request.Context(), entity.AuditLogListParams{
+		AuditLogQuery: auditLogQuery(query.LogQuery),
+		Cursor:        query.Cursor,
+		Limit:         query.Limit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{
+		"logs":        dto.NewAuditLogResponses(page.Logs),
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// exportLogs streams the matching logs oldest first as CSV or, with format=ndjson, as one JSON object per line.
+// Once the first log is sent the status can't change anymore, a failure midway cuts the export short.
+func (h *Handler) exportLogs(c *gin.Context) {
+	var query dto.ExportLogsQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
+		return
+	}
+
+	if query.Format == "" {
+		query.Format = "csv"
+	}
+
+	csvWriter := csv.NewWriter(c.Writer)
+	jsonEncoder := json.NewEncoder(c.Writer)
+
+	var exported int
+
+	// The headers are only sent with the first log, so a query failing right away still gets a problem response.
+	start := func() {
+		if exported > 0 {
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		if query.Format == "ndjson" {
+			contentType = "application/x-ndjson"
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-logs.%s"`, query.Format))
+		c.Status(http.StatusOK)
+
+		if query.Format == "csv" {
+			_ = csvWriter.Write(dto.AuditLogCSVHeader)
+		}
+	}
+
+	flush := func() {
+		csvWriter.Flush()
+		c.Writer.Flush()
+	}
+
+	err := h.dashboardService.ExportLogs(c.Request.Context(), auditLogQuery(query.LogQuery), func(log *entity.AuditLog) error {
+		start()
+		exported++
+
+		if query.Format == "ndjson" {
+			if err := jsonEncoder.Encode(dto.NewAuditLogResponse(*log)); err != nil {
+				return err
+			}
+		} else if err := csvWriter.Write(dto.AuditLogCSVRecord(*log)); err != nil {
+			return err
+		}
+
+		if exported%exportFlushEvery == 0 {
+			flush()
+			return csvWriter.Error()
+		}
+
+		return nil
+	})
+	if err != nil {
+		if exported > 0 {
+			flush()
+		}
+
+		_ = c.Error(err)
+		return
+	}
+
+	start()
+	flush()
+}
+
+func (h *Handler) deleteLog(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(errs.NewValidation("invalid log ID", errs.FieldError{Field: "id", Message: "must be an integer"}))
+		return
+	}
+
+	if err := h.dashboardService.DeleteLog(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{})
+}
+
+func (h *Handler) deleteLogs(c *gin.Context) {
+	var query dto.LogQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
+		return
+	}
+
+	deleted, err := h.dashboardService.DeleteLogs(c.Request.Context(), auditLogQuery(query))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func (h *Handler) verifyLogs(c *gin.Context) {
+	report, err := h.dashboardService.VerifyLogs(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"report": dto.NewChainReportResponse(*report)})
+}
+
+func auditLogQuery(query dto.LogQuery) entity.AuditLogQuery {
+	return entity.AuditLogQuery{
+		UserID:      query.UserID,
+		TargetID:    query.TargetID,
+		ActionTypes: query.ActionType,
+		From:        query.From,
+		To:          query.To,
+	}
+}
+
+func (h *Handler) listPayments(c *gin.Context) {
+	var query dto.ListPaymentsQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultPaymentsLimit
+	}
+
+	if query.Limit < 0 || query.Limit > maxPaymentsLimit {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxPaymentsLimit)}))
+		return
+	}
+
+	page, err := h.billingService.Payments(c.Request.Context(), entity.PaymentListParams{
+		UserID:        query.UserID,
+		PlanID:        query.PlanID,
+		Status:        query.Status,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		Cursor:        query.Cursor,
+		Limit:         query.Limit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{
+		"payments":    dto.NewPaymentResponses(page.Payments),
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func (h *Handler) getBillingHistory(c *gin.Context) {
+	history, err := h.billingService.History(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"billing": dto.NewBillingHistoryResponse(*history)})
+}
+
+func (h *Handler) refundPayment(c *gin.Context) {
+	var refundDto dto.RefundDTO
+
+	if err := c.ShouldBindJSON(&refundDto); err != nil {
+		invalidJSONError(c, err)
+		return
+	}
+
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	refund, payment, err := h.billingService.Refund(c.Request.Context(), actor, c.Param("id"), refundDto.AmountCents, refundDto.Reason)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusCreated, gin.H{
+		"refund":  dto.NewRefundResponse(*refund),
+		"payment": dto.NewPaymentResponse(*payment),
+	})
+}
+
+func (h *Handler) cancelSubscription(c *gin.Context) {
+	var cancelDto dto.CancelSubscriptionDTO
+
+	if err := c.ShouldBindJSON(&cancelDto); err != nil {
+		invalidJSONError(c, err)
+		return
+	}
+
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.billingService.CancelSubscription(c.Request.Context(), actor, c.Param("id"), cancelDto.AtPeriodEnd)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"subscription": dto.NewSubscriptionResponse(*sub)})
+}
+
+func (h *Handler) listUsers(c *gin.Context) {
+	var query dto.ListUsersQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultUsersLimit
+	}
+
+	if query.Limit < 0 || query.Limit > maxUsersLimit {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxUsersLimit)}))
+		return
+	}
+
+	page, err := h.accountService.List(c.Request.Context(), entity.UserListParams{
+		Query:  query.Query,
+		Role:   query.Role,
+		Status: query.Status,
+		Cursor: query.Cursor,
+		Limit:  query.Limit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{
+		"users":       dto.NewUserResponses(page.Users),
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func (h *Handler) getUserDetails(c *gin.Context) {
+	details, err := h.accountService.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"user": dto.NewUserDetailsResponse(*details)})
+}
+
+func (h *Handler) setUserRole(c *gin.Context) {
+	var roleDto dto.SetRoleDTO
+
+	if err := c.ShouldBindJSON(&roleDto); err != nil {
+		invalidJSONError(c, err)
+		return
+	}
+
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.accountService.SetRole(c.Request.Context(), actor, c.Param("id"), roleDto.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"user": dto.NewUserResponse(*user)})
+}
+
+func (h *Handler) suspendUser(c *gin.Context) {
+	h.setUserSuspended(c, true)
+}
+
+func (h *Handler) reactivateUser(c *gin.Context) {
+	h.setUserSuspended(c, false)
+}
+
+func (h *Handler) setUserSuspended(c *gin.Context, suspended bool) {
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.accountService.SetSuspended(c.Request.Context(), actor, c.Param("id"), suspended)
 	if err != nil {
-		log.Println(err)
-		writeErrorResponse(c, http.StatusInternalServerError, "logs", "failed to get logs")
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"user": dto.NewUserResponse(*user)})
+}
+
+func (h *Handler) forcePasswordReset(c *gin.Context) {
+	actor, ok := h.getActor(c)
+	if !ok {
 		return
 	}
 
-	response := gin.H{"logs": logs}
+	if err := h.accountService.ForcePasswordReset(c.Request.Context(), actor, c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
 
-	writeResponse(c, http.StatusOK, response)
+	writeResponse(c, http.StatusOK, gin.H{})
 }
 
-func (h *Handler) deletePayment(c *gin.Context) {}
+func (h *Handler) revokeSessions(c *gin.Context) {
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
 
-func (h *Handler) deleteLog(c *gin.Context) {}
+	if err := h.accountService.RevokeSessions(c.Request.Context(), actor, c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{})
+}
+
+func (h *Handler) deleteUser(c *gin.Context) {
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.Delete(c.Request.Context(), actor, c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{})
+}
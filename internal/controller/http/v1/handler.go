@@ -2,43 +2,95 @@ package v1
 
 import (
 	"github.com/sirupsen/logrus"
-	"net/http"
+	"time"
 
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/metrics"
 	"github.com/nordew/UploadApp/pkg/auth"
+	"github.com/nordew/UploadApp/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nordew/UploadApp/internal/domain/service"
-	"github.com/streadway/amqp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
 )
 
+// UploadOptions configures how uploads are accepted and queued.
+type UploadOptions struct {
+	// Queue is the name of the queue image jobs are staged for in the outbox.
+	Queue string
+	// JobTimeout is the time a queued image job may wait and run before the consumer abandons it.
+	JobTimeout time.Duration
+	// MaxUploadBytes limits the size of an upload request kept in memory.
+	MaxUploadBytes int64
+}
+
+// PaymentOptions configures the payment routes.
+type PaymentOptions struct {
+	// Simulate serves POST /payment/simulate. It's meant for local development with the fake provider only.
+	Simulate bool
+}
+
+var tracer = otel.Tracer("github.com/nordew/UploadApp/internal/controller/http/v1")
+
 type Handler struct {
-	imageService     service.Images
-	userService      service.Users
-	dashboardService service.Dashboards
-	logger           *logrus.Logger
-	channel          *amqp.Channel
-	auth             auth.Authenticator
+	imageService        service.Images
+	userService         service.Users
+	dashboardService    service.Dashboards
+	subscriptionService service.Subscription
+	quotaService        service.Quotas
+	paymentService      service.Payments
+	billingService      service.Billing
+	accountService      service.Accounts
+	logger              *logrus.Logger
+	outboxService       service.Outbox
+	auth                auth.Authenticator
+	timeouts            middleware.Timeouts
+	uploadOptions       UploadOptions
+	paymentOptions      PaymentOptions
+	metrics             *metrics.Metrics
 }
 
 func NewHandler(
 	userService service.Users,
 	imageService service.Images,
 	dashboardService service.Dashboards,
+	subscriptionService service.Subscription,
+	quotaService service.Quotas,
+	paymentService service.Payments,
+	billingService service.Billing,
+	accountService service.Accounts,
 	logger *logrus.Logger,
-	channel *amqp.Channel,
-	auth auth.Authenticator) *Handler {
+	outboxService service.Outbox,
+	auth auth.Authenticator,
+	timeouts middleware.Timeouts,
+	uploadOptions UploadOptions,
+	paymentOptions PaymentOptions,
+	metrics *metrics.Metrics) *Handler {
 	return &Handler{
-		userService:      userService,
-		imageService:     imageService,
-		dashboardService: dashboardService,
-		logger:           logger,
-		channel:          channel,
-		auth:             auth,
+		userService:         userService,
+		imageService:        imageService,
+		dashboardService:    dashboardService,
+		subscriptionService: subscriptionService,
+		quotaService:        quotaService,
+		paymentService:      paymentService,
+		billingService:      billingService,
+		accountService:      accountService,
+		logger:              logger,
+		outboxService:       outboxService,
+		auth:                auth,
+		timeouts:            timeouts,
+		uploadOptions:       uploadOptions,
+		paymentOptions:      paymentOptions,
+		metrics:             metrics,
 	}
 }
 
 func (h *Handler) Init() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), otelgin.Middleware(tracing.ServiceName), middleware.RequestID(), middleware.RequestInfo(), middleware.Logger(h.logger), middleware.Metrics(h.metrics), middleware.Errors(h.logger), middleware.Timeout(h.timeouts))
 
 	root := router.Group("/")
 	{
@@ -52,9 +104,12 @@ func (h *Handler) Init() *gin.Engine {
 		auth.GET("/refresh", h.refresh)
 	}
 
+	router.GET("/plans", h.listPlans)
+
 	profile := router.Group("/profile")
 	profile.Use(h.AuthMiddleware())
 	{
+		profile.GET("/usage", h.getUsage)
 		profile.GET("/:sub", h.getUser)
 	}
 
@@ -62,7 +117,7 @@ func (h *Handler) Init() *gin.Engine {
 	image.Use(h.AuthMiddleware())
 	{
 		image.POST("/upload", h.upload)
-		image.GET("/all", h.getAllImages)
+		image.GET("", h.listImages)
 		image.GET("/by-size", h.getBySize)
 		image.DELETE("/delete/:name", h.deleteAllImages)
 	}
@@ -71,13 +126,38 @@ func (h *Handler) Init() *gin.Engine {
 	dashboard.Use(h.AuthAdminMiddleware())
 	{
 		dashboard.GET("/logs", h.getLogs)
+		dashboard.GET("/logs/export", h.exportLogs)
+		dashboard.GET("/logs/verify", h.verifyLogs)
+		dashboard.DELETE("/logs", h.deleteLogs)
 		dashboard.DELETE("/logs/:id", h.deleteLog)
+
+		dashboard.GET("/payments", h.listPayments)
+		dashboard.POST("/payments/:id/refund", h.refundPayment)
+		dashboard.GET("/payments/users/:id", h.getBillingHistory)
+		dashboard.POST("/payments/users/:id/cancel-subscription", h.cancelSubscription)
+
+		dashboard.GET("/users", h.listUsers)
+		dashboard.GET("/users/:id", h.getUserDetails)
+		dashboard.PUT("/users/:id/role", h.setUserRole)
+		dashboard.POST("/users/:id/suspend", h.suspendUser)
+		dashboard.POST("/users/:id/reactivate", h.reactivateUser)
+		dashboard.POST("/users/:id/reset-password", h.forcePasswordReset)
+		dashboard.POST("/users/:id/revoke-sessions", h.revokeSessions)
+		dashboard.DELETE("/users/:id", h.deleteUser)
 	}
 
+	router.POST("/payment/webhook", h.paymentWebhook)
+
 	payment := router.Group("/payment")
 	payment.Use(h.AuthMiddleware())
 	{
-		payment.GET("/create-intent", h.createPaymentIntent)
+		payment.POST("/create-intent", h.createPaymentIntent)
+		payment.POST("/checkout", h.createCheckout)
+		payment.GET("/history", h.getPaymentHistory)
+
+		if h.paymentOptions.Simulate {
+			payment.POST("/simulate", h.simulatePayment)
+		}
 	}
 
 	return router
@@ -87,63 +167,45 @@ func writeResponse(c *gin.Context, statusCode int, h gin.H) {
 	c.JSON(statusCode, h)
 }
 
-func invalidJSONResponse(c *gin.Context) {
-	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+func invalidJSONError(c *gin.Context, err error) {
+	_ = c.Error(errs.Wrap(err, errs.Validation, "invalid JSON body"))
 }
 
-func writeErrorResponse(c *gin.Context, statusCode int, error string, errorDesc string) {
-	response := gin.H{
-		"error":             error,
-		"error_description": errorDesc,
-	}
-
-	c.JSON(statusCode, response)
-}
-
-func (h *Handler) getAccessTokenFromRequest(c *gin.Context) *auth.ParseTokenClaimsOutput {
-	logger := h.logger.WithField("function", "getAccessTokenFromRequest")
-
-	accessToken := extractTokenFromHeader(c.Request.Header, "Authorization")
-
-	if accessToken == "" {
-		logger.Error("access token not provided in headers")
-		writeErrorResponse(c, http.StatusUnauthorized, "auth", "access token not provided in headers")
-		c.Abort()
-		return nil
-	}
-
-	accessTokenClaims, err := h.auth.ParseToken(accessToken)
-	if err != nil {
-		logger.WithError(err).Error("failed to parse access token")
-		writeErrorResponse(c, http.StatusUnauthorized, "auth", "failed to parse access token")
-		c.Abort()
-		return nil
-	}
-
-	return accessTokenClaims
-}
-
-func (h *Handler) getRefreshTokenFromRequest(c *gin.Context) *auth.ParseTokenClaimsOutput {
-	logger := h.logger.WithField("function", "getRefreshTokenFromRequest")
+// getRefreshTokenFromRequest returns the refresh token of the request and its claims.
+// It returns nil claims after attaching an error if the token is missing or invalid.
+func (h *Handler) getRefreshTokenFromRequest(c *gin.Context) (string, *auth.ParseTokenClaimsOutput) {
+	logger := middleware.GetLogger(c, h.logger).WithField("function", "getRefreshTokenFromRequest")
 
 	refreshToken := extractTokenFromHeader(c.Request.Header, "Refresh-Token")
 
 	if refreshToken == "" {
 		logger.Error("refresh token not provided in headers")
-		writeErrorResponse(c, http.StatusUnauthorized, "auth", "refresh token not provided in headers")
+		_ = c.Error(errs.New(errs.Unauthorized, "refresh token not provided in headers"))
 		c.Abort()
-		return nil
+		return "", nil
 	}
 
 	refreshTokenClaims, err := h.auth.ParseToken(refreshToken)
 	if err != nil {
 		logger.WithError(err).Error("failed to parse refresh token")
-		writeErrorResponse(c, http.StatusUnauthorized, "auth", "failed to parse refresh token")
+		_ = c.Error(middleware.TokenError(err))
+		c.Abort()
+		return "", nil
+	}
+
+	return refreshToken, refreshTokenClaims
+}
+
+// getActor returns the user authenticated by AuthMiddleware or AuthAdminMiddleware.
+// It returns false after attaching an error if the request went through neither.
+func (h *Handler) getActor(c *gin.Context) (entity.Actor, bool) {
+	actor, ok := middleware.GetActor(c)
+	if !ok {
+		_ = c.Error(errs.New(errs.Unauthorized, "request is not authenticated"))
 		c.Abort()
-		return nil
 	}
 
-	return refreshTokenClaims
+	return actor, ok
 }
 
 func extractTokenFromHeader(headers map[string][]string, headerKey string) string {
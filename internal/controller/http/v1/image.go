@@ -4,175 +4,236 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"image"
+	_ "image/gif"
 	"image/jpeg"
+	_ "image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
-	"strings"
-	"sync"
+	"time"
 
 	"github.com/nordew/UploadApp/internal/controller/http/dto"
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
+	controller "github.com/nordew/UploadApp/internal/controller/rabbit"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/pkg/tracing"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultImagesLimit = 20
+	maxImagesLimit     = 100
+)
+
 func (h *Handler) upload(c *gin.Context) {
-	err := c.Request.ParseMultipartForm(10 << 20)
+	err := c.Request.ParseMultipartForm(h.uploadOptions.MaxUploadBytes)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to parse form")
+		_ = c.Error(errs.Wrap(err, errs.Validation, "failed to parse form"))
 		return
 	}
 
 	files, ok := c.Request.MultipartForm.File["photo"]
 	if !ok || len(files) == 0 {
-		writeErrorResponse(c, http.StatusBadRequest, "image", "No file found in the 'photo' field")
+		_ = c.Error(errs.NewValidation("no file found", errs.FieldError{Field: "photo", Message: "is required"}))
 		return
 	}
 
-	claims := h.getAccessTokenFromRequest(c)
-	if err != nil {
-		writeErrorResponse(c, http.StatusUnauthorized, "jwt", "failed to parse accesss token")
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
 	}
 
+	contents := make([][]byte, 0, len(files))
+	uploads := make([]entity.UploadInfo, 0, len(files))
+
 	for _, file := range files {
-		if !strings.HasSuffix(strings.ToLower(file.Filename), ".jpeg") {
-			writeErrorResponse(c, http.StatusBadRequest, "image", "Only .jpeg files are allowed")
+		content, upload, err := h.processFile(file)
+		if err != nil {
+			_ = c.Error(err)
 			return
 		}
 
-		if err := h.processFile(c, file, claims.Sub); err != nil {
-			return
+		contents = append(contents, content)
+		uploads = append(uploads, upload)
+	}
+
+	// The files are checked against the quotas together and either all of them are queued or none. They're only
+	// decoded once the quotas allow them, so an image too large for the plan is never decoded.
+	deadline := time.Now().Add(h.uploadOptions.JobTimeout)
+
+	err = h.quotaService.ReserveUploads(c.Request.Context(), actor.UserID, uploads, deadline, func(ctx context.Context, i int) (string, error) {
+		imgBytes, err := encodeJPEG(contents[i])
+		if err != nil {
+			return "", err
 		}
+
+		return h.enqueueImage(ctx, c, imgBytes, actor.UserID, deadline)
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
 	writeResponse(c, http.StatusCreated, gin.H{})
 }
 
-func (h *Handler) processFile(c *gin.Context, file *multipart.FileHeader, userId string) error {
+// processFile reads the uploaded file and returns it together with what the quotas check. Only the image header is
+// decoded: the size of the file stands in for the size of its JPEG encoding, which isn't known before decoding it.
+func (h *Handler) processFile(file *multipart.FileHeader) ([]byte, entity.UploadInfo, error) {
 	openedFile, err := file.Open()
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to open file")
-		return err
+		return nil, entity.UploadInfo{}, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer openedFile.Close()
 
+	h.metrics.AddUploadBytes(file.Size)
+
 	content, err := io.ReadAll(openedFile)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to read file")
-		return err
+		return nil, entity.UploadInfo{}, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, entity.UploadInfo{}, errs.Wrap(err, errs.Validation, "failed to decode image")
+	}
+
+	return content, entity.UploadInfo{
+		Bytes:       file.Size,
+		Width:       config.Width,
+		Height:      config.Height,
+		Format:      format,
+		StoredBytes: service.EstimateStoredBytes(file.Size),
+	}, nil
+}
+
+// encodeJPEG decodes the uploaded image and returns it encoded as JPEG.
+func encodeJPEG(content []byte) ([]byte, error) {
 	img, _, err := image.Decode(bytes.NewReader(content))
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to convert image")
-		return err
+		return nil, errs.Wrap(err, errs.Validation, "failed to decode image")
 	}
 
 	var imgBytesBuffer bytes.Buffer
 	if err := jpeg.Encode(&imgBytesBuffer, img, nil); err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to convert image to bytes")
-		return err
+		return nil, fmt.Errorf("failed to convert image to bytes: %w", err)
 	}
 
-	imgBytes := imgBytesBuffer.Bytes()
-
-	if err := h.publishImageToQueue(c, imgBytes, userId); err != nil {
-		return err
-	}
-
-	return nil
+	return imgBytesBuffer.Bytes(), nil
 }
 
-func (h *Handler) publishImageToQueue(c *gin.Context, imgBytes []byte, userId string) error {
-	message := struct {
-		UserID    string `json:"userId"`
-		ImageData []byte `json:"imageData"`
-	}{
+// enqueueImage stages the image job in the outbox and returns its ID, the relay publishes it once the
+// transaction of ctx commits.
+func (h *Handler) enqueueImage(ctx context.Context, c *gin.Context, imgBytes []byte, userId string, deadline time.Time) (id string, err error) {
+	ctx, span := tracer.Start(ctx, "enqueueImage",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", h.uploadOptions.Queue)))
+	defer tracing.End(span, &err)
+
+	message := controller.ImageMessage{
 		UserID:    userId,
 		ImageData: imgBytes,
 	}
 
 	marshalledMsg, err := json.Marshal(&message)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to marshal message")
-		return err
-	}
-
-	err = h.channel.Publish(
-		"",
-		"image",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        marshalledMsg,
-		},
-	)
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	headers := amqp.Table{}
+	controller.SetDeadline(headers, deadline)
+	controller.SetRequestID(headers, middleware.GetRequestID(c))
+	controller.InjectTraceContext(ctx, headers)
+
+	id, err = h.outboxService.Enqueue(ctx, h.uploadOptions.Queue, headers, marshalledMsg)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "Failed to add message to queue")
-		return err
+		return "", fmt.Errorf("failed to enqueue image job: %w", err)
 	}
 
-	return nil
+	span.SetAttributes(attribute.String("messaging.message.id", id))
+
+	return id, nil
 }
 
-func (h *Handler) getAllImages(c *gin.Context) {
-	var getAllImageDTO dto.GetAllImageDTO
+func (h *Handler) listImages(c *gin.Context) {
+	var query dto.ListImagesQuery
 
-	if err := c.ShouldBindJSON(&getAllImageDTO); err != nil {
-		invalidJSONResponse(c)
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid query parameters"))
 		return
 	}
 
-	h.authorizeImageAccess(c, getAllImageDTO.ID)
+	if query.Limit == 0 {
+		query.Limit = defaultImagesLimit
+	}
 
-	images, err := h.imageService.GetAll(context.Background(), getAllImageDTO.ID)
-	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to get photos", err.Error())
+	if query.Limit < 0 || query.Limit > maxImagesLimit {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxImagesLimit)}))
 		return
 	}
 
-	c.Writer.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
-
-	var wg sync.WaitGroup
-
-	for _, v := range images {
-		wg.Add(1)
+	if query.Sort != "" && query.Sort != "created_at" {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "sort", Message: "only created_at is supported"}))
+		return
+	}
 
-		go func(entityImage entity.Image) {
-			defer wg.Done()
+	if query.Order == "" {
+		query.Order = entity.OrderDesc
+	}
 
-			img, _, err := image.Decode(entityImage.Reader)
-			if err != nil {
-				writeErrorResponse(c, http.StatusInternalServerError, "image", "failed to decode image")
-				return
-			}
+	if query.Order != entity.OrderAsc && query.Order != entity.OrderDesc {
+		_ = c.Error(errs.NewValidation("invalid query parameters", errs.FieldError{Field: "order", Message: "must be either asc or desc"}))
+		return
+	}
 
-			var encodedImageBuffer bytes.Buffer
-			if err := jpeg.Encode(&encodedImageBuffer, img, nil); err != nil {
-				writeErrorResponse(c, http.StatusInternalServerError, "image", "failed to encode image")
-				return
-			}
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
 
-			c.Data(http.StatusOK, "image/jpeg", encodedImageBuffer.Bytes())
-		}(v)
+	page, err := h.imageService.List(c.Request.Context(), entity.ImageListParams{
+		UserID:       actor.UserID,
+		Cursor:       query.Cursor,
+		Limit:        query.Limit,
+		Order:        query.Order,
+		Format:       query.Format,
+		CreatedAfter: query.CreatedAfter,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	wg.Wait()
+	images := make([]dto.ImageResponse, 0, len(page.Images))
+	for _, img := range page.Images {
+		images = append(images, dto.NewImageResponse(img))
+	}
 
-	c.AbortWithStatus(http.StatusOK)
+	writeResponse(c, http.StatusOK, gin.H{
+		"images":      images,
+		"next_cursor": page.NextCursor,
+	})
 }
 
 func (h *Handler) deleteAllImages(c *gin.Context) {
 	name := c.Param("name")
 
-	h.authorizeImageAccess(c, name)
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
 
-	if err := h.imageService.DeleteAllImages(context.Background(), name); err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to delete image", err.Error())
+	if err := h.imageService.DeleteAllImages(c.Request.Context(), actor, name); err != nil {
+		_ = c.Error(err)
+		return
 	}
 
 	writeResponse(c, http.StatusOK, gin.H{})
@@ -182,48 +243,20 @@ func (h *Handler) getBySize(c *gin.Context) {
 	var GetImageBySizeDTO dto.GetImageBySizeDTO
 
 	if err := c.ShouldBindJSON(&GetImageBySizeDTO); err != nil {
-		invalidJSONResponse(c)
+		invalidJSONError(c, err)
 		return
 	}
 
-	h.authorizeImageAccess(c, GetImageBySizeDTO.ID)
-
-	entityImg, err := h.imageService.GetBySize(context.Background(), GetImageBySizeDTO.ID, GetImageBySizeDTO.Size)
-	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image (failed to make getBySize())", err.Error())
+	actor, ok := h.getActor(c)
+	if !ok {
 		return
 	}
 
-	img, _, err := image.Decode(entityImg.Reader)
+	entityImg, err := h.imageService.GetBySize(c.Request.Context(), actor, GetImageBySizeDTO.ID, GetImageBySizeDTO.Size)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "failed to decode image")
-		return
-	}
-
-	var encodedImageBuffer bytes.Buffer
-	if err := jpeg.Encode(&encodedImageBuffer, img, nil); err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "image", "failed to encode image")
-		return
-	}
-
-	c.Data(http.StatusOK, "image/jpeg", encodedImageBuffer.Bytes())
-}
-
-func (h *Handler) authorizeImageAccess(c *gin.Context, id string) {
-	claims := h.getAccessTokenFromRequest(c)
-
-	idInFile := h.extractUserIDFromImageFilename(id)
-
-	if claims.Sub != idInFile {
-		writeErrorResponse(c, http.StatusBadRequest, "access denied", "the user account associated with your request does not match the required credentials for this image")
+		_ = c.Error(err)
 		return
 	}
-}
-
-func (h *Handler) extractUserIDFromImageFilename(str string) string {
-	startIndex := strings.LastIndex(str, "_") + 1
-	endIndex := strings.LastIndex(str, ".")
-	substring := str[startIndex:endIndex]
 
-	return substring
+	c.DataFromReader(http.StatusOK, entityImg.Size, "image/jpeg", entityImg.Reader, nil)
 }
@@ -1,9 +1,101 @@
 package v1
 
 import (
+	"context"
+	"io"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/dto"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
 )
 
+// maxWebhookBytes limits the size of webhook payloads read into memory.
+const maxWebhookBytes = 1 << 20
+
 func (h *Handler) createPaymentIntent(c *gin.Context) {
+	h.startPayment(c, h.paymentService.CreateIntent)
+}
+
+func (h *Handler) createCheckout(c *gin.Context) {
+	h.startPayment(c, h.paymentService.CreateCheckout)
+}
+
+func (h *Handler) startPayment(c *gin.Context, start func(ctx context.Context, userID, planID string) (*entity.PaymentStart, error)) {
+	var paymentDto dto.PaymentDTO
+
+	if err := c.ShouldBindJSON(&paymentDto); err != nil {
+		invalidJSONError(c, err)
+		return
+	}
+
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	started, err := start(c.Request.Context(), actor.UserID, paymentDto.PlanID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusCreated, gin.H{"payment": dto.NewPaymentStartResponse(*started)})
+}
+
+func (h *Handler) getPaymentHistory(c *gin.Context) {
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	payments, err := h.paymentService.History(c.Request.Context(), actor.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"payments": dto.NewPaymentResponses(payments)})
+}
+
+// paymentWebhook receives the provider's events. It's authenticated by the payload signature, not by a token.
+func (h *Handler) paymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "failed to read webhook payload"))
+		return
+	}
+
+	if err := h.paymentService.HandleWebhook(c.Request.Context(), payload, c.GetHeader(h.paymentService.WebhookSignatureHeader())); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"received": true})
+}
+
+// simulatePayment completes a pending payment of the user without paying. It's only routed when
+// PaymentOptions.Simulate is set and answers 404 unless the fake provider is configured.
+func (h *Handler) simulatePayment(c *gin.Context) {
+	var simulateDto dto.SimulatePaymentDTO
+
+	if err := c.ShouldBindJSON(&simulateDto); err != nil {
+		invalidJSONError(c, err)
+		return
+	}
+
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	succeed := simulateDto.Outcome == entity.PaymentSucceeded
+
+	if err := h.paymentService.Simulate(c.Request.Context(), actor.UserID, simulateDto.PaymentID, succeed); err != nil {
+		_ = c.Error(err)
+		return
+	}
 
+	writeResponse(c, http.StatusOK, gin.H{"payment_id": simulateDto.PaymentID, "outcome": simulateDto.Outcome})
 }
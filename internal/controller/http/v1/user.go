@@ -1,29 +1,35 @@
 package v1
 
 import (
-	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/nordew/UploadApp/internal/controller/http/dto"
-	"log"
+	"github.com/nordew/UploadApp/internal/domain/errs"
 	"net/http"
 )
 
 func (h *Handler) getUser(c *gin.Context) {
-	claims := h.getAccessTokenFromRequest(c)
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
 
 	id := c.Param("sub")
 
-	log.Println(claims.Sub)
-	log.Println(id)
+	if actor.UserID != id {
+		_ = c.Error(errs.New(errs.Forbidden, "user ID in the token does not match the requested user ID"))
+		return
+	}
 
-	if claims.Sub != id {
-		writeErrorResponse(c, http.StatusUnauthorized, "Authentication error", "User ID in the token does not match the requested user ID.")
+	user, err := h.userService.GetCredentials(c.Request.Context(), id, false)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	user, err := h.userService.GetCredentials(context.Background(), id, false)
+	subscription, err := h.subscriptionService.Current(c.Request.Context(), id)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "GetCredantials failed", err.Error())
+		_ = c.Error(err)
+		return
 	}
 
 	response := gin.H{
@@ -32,7 +38,7 @@ func (h *Handler) getUser(c *gin.Context) {
 			"email":           user.Email,
 			"photos_uploaded": user.PhotosUploaded,
 		},
-		"subscription": gin.H{},
+		"subscription": dto.NewSubscriptionResponse(*subscription),
 	}
 
 	writeResponse(c, http.StatusOK, response)
@@ -42,15 +48,45 @@ func (h *Handler) changePassword(c *gin.Context) {
 	var passDto dto.ChangePasswordDTO
 
 	if err := c.ShouldBindJSON(&passDto); err != nil {
-		invalidJSONResponse(c)
+		invalidJSONError(c, err)
 		return
 	}
 
-	err := h.userService.ChangePassword(context.Background(), passDto.Email, passDto.OldPassword, passDto.NewPassword)
+	err := h.userService.ChangePassword(c.Request.Context(), passDto.Email, passDto.OldPassword, passDto.NewPassword)
 	if err != nil {
-		writeErrorResponse(c, http.StatusInternalServerError, "failed to change password", err.Error())
+		_ = c.Error(err)
 		return
 	}
 
 	writeResponse(c, http.StatusOK, gin.H{})
 }
+
+func (h *Handler) listPlans(c *gin.Context) {
+	plans, err := h.subscriptionService.Plans(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := make([]dto.PlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		response = append(response, dto.NewPlanResponse(plan))
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"plans": response})
+}
+
+func (h *Handler) getUsage(c *gin.Context) {
+	actor, ok := h.getActor(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.quotaService.Usage(c.Request.Context(), actor.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"usage": dto.NewUsageResponse(*usage)})
+}
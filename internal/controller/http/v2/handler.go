@@ -0,0 +1,54 @@
+package v2
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/middleware"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/pkg/auth"
+	"github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	imageService service.Images
+	userService  service.Users
+	logger       *logrus.Logger
+	auth         auth.Authenticator
+}
+
+func NewHandler(imageService service.Images, userService service.Users, logger *logrus.Logger, auth auth.Authenticator) *Handler {
+	return &Handler{
+		imageService: imageService,
+		userService:  userService,
+		logger:       logger,
+		auth:         auth,
+	}
+}
+
+// Init registers the v2 routes on the given router next to the v1 ones.
+func (h *Handler) Init(router *gin.Engine) {
+	v2 := router.Group("/v2")
+
+	images := v2.Group("/images")
+	images.Use(h.AuthMiddleware())
+	{
+		images.GET("/:id", h.getImage)
+		images.GET("/:id/variants/:variant", h.getImageVariant)
+		images.PATCH("/:id", h.updateImage)
+		images.DELETE("/:id", h.deleteImage)
+	}
+}
+
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+	return middleware.Authenticate(h.auth, h.userService, h.logger)
+}
+
+// getActor returns the user authenticated by AuthMiddleware, every v2 route goes through it.
+func getActor(c *gin.Context) entity.Actor {
+	actor, _ := middleware.GetActor(c)
+	return actor
+}
+
+func writeResponse(c *gin.Context, statusCode int, h gin.H) {
+	c.JSON(statusCode, h)
+}
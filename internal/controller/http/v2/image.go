@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nordew/UploadApp/internal/controller/http/dto"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+)
+
+func (h *Handler) getImage(c *gin.Context) {
+	meta, err := h.imageService.Get(c.Request.Context(), getActor(c), c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"image": dto.NewImageResponse(*meta)})
+}
+
+func (h *Handler) getImageVariant(c *gin.Context) {
+	size, ok := entity.VariantSize(c.Param("variant"))
+	if !ok {
+		_ = c.Error(errs.New(errs.NotFound, "unknown variant"))
+		return
+	}
+
+	img, err := h.imageService.GetVariant(c.Request.Context(), getActor(c), c.Param("id"), size)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, img.Size, mime.TypeByExtension(path.Ext(img.Name)), img.Reader, nil)
+}
+
+func (h *Handler) updateImage(c *gin.Context) {
+	var input dto.UpdateImageDTO
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(errs.Wrap(err, errs.Validation, "invalid JSON body"))
+		return
+	}
+
+	updated, err := h.imageService.Update(c.Request.Context(), getActor(c), c.Param("id"), entity.ImageUpdate{
+		Title:       input.Title,
+		Description: input.Description,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeResponse(c, http.StatusOK, gin.H{"image": dto.NewImageResponse(*updated)})
+}
+
+func (h *Handler) deleteImage(c *gin.Context) {
+	if err := h.imageService.Delete(c.Request.Context(), getActor(c), c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// DeadlineHeader carries the moment after which an image job isn't worth processing anymore.
+	DeadlineHeader = "x-deadline"
+	// RequestIDHeader carries the ID of the HTTP request which published the job.
+	RequestIDHeader = "x-request-id"
+)
+
+// ImageMessage is the body of a job published to the image queue.
+type ImageMessage struct {
+	UserID    string `json:"userId"`
+	ImageData []byte `json:"imageData"`
+}
+
+// SetDeadline stores the job deadline in the message headers.
+func SetDeadline(headers amqp.Table, deadline time.Time) {
+	headers[DeadlineHeader] = deadline.UTC().Format(time.RFC3339Nano)
+}
+
+// GetDeadline returns the job deadline stored in the message headers.
+func GetDeadline(headers amqp.Table) (time.Time, bool) {
+	raw, ok := headers[DeadlineHeader].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	deadline, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return deadline, true
+}
+
+// SetRequestID stores the ID of the publishing request in the message headers.
+func SetRequestID(headers amqp.Table, id string) {
+	if id != "" {
+		headers[RequestIDHeader] = id
+	}
+}
+
+// GetRequestID returns the ID of the publishing request stored in the message headers.
+func GetRequestID(headers amqp.Table) string {
+	id, _ := headers[RequestIDHeader].(string)
+	return id
+}
+
+// headerCarrier adapts AMQP message headers to the OpenTelemetry propagation API.
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// InjectTraceContext stores the trace context of ctx in the message headers.
+func InjectTraceContext(ctx context.Context, headers amqp.Table) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+}
+
+// ExtractTraceContext returns ctx carrying the trace context stored in the message headers.
+func ExtractTraceContext(ctx context.Context, headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier(headers))
+}
@@ -4,14 +4,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
 	"github.com/nordew/UploadApp/internal/domain/entity"
 	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/metrics"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"image"
 	"time"
 )
 
+const (
+	consumerTag   = "image-consumer"
+	prefetchCount = 1
+)
+
+var tracer = otel.Tracer("github.com/nordew/UploadApp/internal/controller/rabbit")
+
+// errJobExpired is returned by handle for jobs whose deadline passed before they were picked up.
+var errJobExpired = errors.New("image job expired")
+
 type Consumer struct {
 	channel          *amqp.Channel
 	queue            amqp.Queue
@@ -19,9 +36,13 @@ type Consumer struct {
 	imageService     service.Images
 	dashboardService service.Dashboards
 	userService      service.Users
+	outboxService    service.Outbox
+	quotaService     service.Quotas
+	txManager        psqldb.TxManager
+	metrics          *metrics.Metrics
 }
 
-func NewConsumer(channel *amqp.Channel, queue amqp.Queue, logger *logrus.Logger, imageService service.Images, dashboardService service.Dashboards, userService service.Users) *Consumer {
+func NewConsumer(channel *amqp.Channel, queue amqp.Queue, logger *logrus.Logger, imageService service.Images, dashboardService service.Dashboards, userService service.Users, outboxService service.Outbox, quotaService service.Quotas, txManager psqldb.TxManager, metrics *metrics.Metrics) *Consumer {
 	return &Consumer{
 		channel:          channel,
 		queue:            queue,
@@ -29,14 +50,26 @@ func NewConsumer(channel *amqp.Channel, queue amqp.Queue, logger *logrus.Logger,
 		imageService:     imageService,
 		dashboardService: dashboardService,
 		userService:      userService,
+		outboxService:    outboxService,
+		quotaService:     quotaService,
+		txManager:        txManager,
+		metrics:          metrics,
 	}
 }
 
+// Consume processes image jobs one at a time until ctx is cancelled or the delivery channel is closed.
+// Cancelling ctx only stops taking new jobs: the job in progress runs to completion and is acked
+// before Consume returns. Jobs delivered but not yet started are requeued by the broker.
 func (c *Consumer) Consume(ctx context.Context) error {
+	if err := c.channel.Qos(prefetchCount, 0, false); err != nil {
+		c.logger.Error("Qos() error: ", err)
+		return err
+	}
+
 	msgs, err := c.channel.Consume(
 		c.queue.Name, // queue
-		"",           // consumer
-		true,         // auto-ack
+		consumerTag,  // consumer
+		false,        // auto-ack
 		false,        // exclusive
 		false,        // no-local
 		false,        // no-wait
@@ -47,30 +80,141 @@ func (c *Consumer) Consume(ctx context.Context) error {
 		return err
 	}
 
-	for d := range msgs {
-		var message struct {
-			UserID    string `json:"userId"`
-			ImageData []byte `json:"imageData"`
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Consume(): context cancelled, stopping")
+
+			if err := c.channel.Cancel(consumerTag, false); err != nil {
+				c.logger.Error("Cancel() error: ", err)
+				return err
+			}
+
+			return nil
+		case d, ok := <-msgs:
+			if !ok {
+				c.logger.Info("Consume(): delivery channel closed")
+				return nil
+			}
+
+			c.process(context.WithoutCancel(ctx), d)
 		}
+	}
+}
 
-		if err := json.Unmarshal(d.Body, &message); err != nil {
-			c.logger.Error("Unmarshal() error: ", err)
-			return err
+// process handles a delivery and acknowledges it.
+// Failed jobs are rejected without requeueing so a broken message can't block the queue,
+// expired jobs are acknowledged and dropped.
+func (c *Consumer) process(ctx context.Context, d amqp.Delivery) {
+	logger := c.logger.WithField("request_id", GetRequestID(d.Headers))
+	ctx = logging.WithLogger(ctx, logger)
+
+	err := c.handle(ctx, d)
+
+	// Processed jobs release their quota reservation together with recording the usage, the quota of
+	// abandoned ones is given back here.
+	if err != nil && d.MessageId != "" {
+		if err := c.quotaService.ReleaseUpload(ctx, d.MessageId); err != nil {
+			logger.WithError(err).Error("ReleaseUpload() error")
 		}
+	}
 
-		img, _, err := image.Decode(bytes.NewReader(message.ImageData))
-		if err != nil {
-			c.logger.Error("Decode() error: ", err)
-			return err
+	switch {
+	case errors.Is(err, errJobExpired):
+		logger.Warn("abandoning expired image job")
+		c.metrics.IncJobs(metrics.OutcomeExpired)
+	case err != nil:
+		logger.WithError(err).Error("failed to handle image job")
+		c.metrics.IncJobs(metrics.OutcomeFailed)
+
+		if err := d.Nack(false, false); err != nil {
+			logger.WithError(err).Error("Nack() error")
+		}
+
+		return
+	default:
+		c.metrics.IncJobs(metrics.OutcomeSucceeded)
+	}
+
+	if err := d.Ack(false); err != nil {
+		logger.WithError(err).Error("Ack() error")
+	}
+}
+
+// handle processes a single image job within the deadline carried in its headers.
+// The job gets its own trace linked to the one of the request which published it.
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) (err error) {
+	publisher := trace.SpanContextFromContext(ExtractTraceContext(ctx, d.Headers))
+
+	ctx, span := tracer.Start(ctx, "Consumer.handle",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.Link{SpanContext: publisher}))
+	defer tracing.End(span, &err)
+
+	ctx = logging.WithFields(ctx, c.logger, logrus.Fields{"trace_id": span.SpanContext().TraceID().String()})
+	logger := logging.FromContext(ctx, c.logger)
+
+	if deadline, ok := GetDeadline(d.Headers); ok {
+		if time.Now().After(deadline) {
+			return errJobExpired
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	var message ImageMessage
+
+	if err := json.Unmarshal(d.Body, &message); err != nil {
+		logger.WithError(err).Error("Unmarshal() error")
+		return err
+	}
+
+	ctx = logging.WithFields(ctx, c.logger, logrus.Fields{"user_id": message.UserID})
+	logger = logging.FromContext(ctx, c.logger)
+
+	decodeStart := time.Now()
+	img, _, err := image.Decode(bytes.NewReader(message.ImageData))
+	c.metrics.ObserveJobStage(metrics.StageDecode, "", time.Since(decodeStart))
+
+	if err != nil {
+		logger.WithError(err).Error("Decode() error")
+		return err
+	}
+
+	// The image metadata, the upload counter, the release of the quota reservation and the audit log are
+	// written together so they can't drift.
+	// Objects stored before a rollback are left for images gc.
+	// The idempotency key is recorded in the same transaction: a redelivered job is skipped once the
+	// first delivery committed, and processed again if it rolled back.
+	return c.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if d.MessageId != "" {
+			first, err := c.outboxService.MarkProcessed(ctx, d.MessageId)
+			if err != nil {
+				logger.WithError(err).Error("MarkProcessed() error")
+				return err
+			}
+
+			if !first {
+				logger.WithField("message_id", d.MessageId).Info("skipping duplicate image job")
+				return nil
+			}
+
+			if err := c.quotaService.ReleaseUpload(ctx, d.MessageId); err != nil {
+				logger.WithError(err).Error("ReleaseUpload() error")
+				return err
+			}
 		}
 
 		if err := c.imageService.Upload(ctx, img, message.UserID); err != nil {
-			c.logger.Error("Upload() error: ", err)
+			logger.WithError(err).Error("Upload() error")
 			return err
 		}
 
 		if err := c.userService.IncrementPhotosUploaded(ctx, message.UserID); err != nil {
-			c.logger.Error("IncrementPhotosUploaded() error: ", err)
+			logger.WithError(err).Error("IncrementPhotosUploaded() error")
 			return err
 		}
 
@@ -81,10 +225,10 @@ func (c *Consumer) Consume(ctx context.Context) error {
 		}
 
 		if err := c.dashboardService.CreateLog(ctx, log); err != nil {
-			c.logger.Error("CreateLog() error: ", err)
+			logger.WithError(err).Error("CreateLog() error")
 			return err
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/metrics"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errNotConfirmed is returned when the broker rejects a message or doesn't confirm it in time.
+var errNotConfirmed = errors.New("message not confirmed by the broker")
+
+// RelayOptions configures the outbox relay.
+type RelayOptions struct {
+	// Interval is the pause between two polls of the outbox.
+	Interval time.Duration
+	// BatchSize is the maximum number of messages published per poll.
+	BatchSize int
+	// ConfirmTimeout bounds how long the broker may take to confirm a message.
+	ConfirmTimeout time.Duration
+	// Retention is how long sent messages and idempotency keys are kept.
+	Retention time.Duration
+}
+
+// Relay publishes the messages staged in the outbox.
+// Every message is published with publisher confirms and only marked sent once the broker acked it,
+// so a message may be published more than once but is never lost. Consumers drop the duplicates
+// by the idempotency key sent as the message ID.
+type Relay struct {
+	channel       *amqp.Channel
+	outboxService service.Outbox
+	txManager     psqldb.TxManager
+	logger        *logrus.Logger
+	metrics       *metrics.Metrics
+	options       RelayOptions
+
+	confirms chan amqp.Confirmation
+	sequence uint64
+}
+
+func NewRelay(channel *amqp.Channel, outboxService service.Outbox, txManager psqldb.TxManager, logger *logrus.Logger, metrics *metrics.Metrics, options RelayOptions) *Relay {
+	return &Relay{
+		channel:       channel,
+		outboxService: outboxService,
+		txManager:     txManager,
+		logger:        logger,
+		metrics:       metrics,
+		options:       options,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled. The relay owns its channel, which is put in confirm mode.
+func (r *Relay) Run(ctx context.Context) error {
+	if err := r.channel.Confirm(false); err != nil {
+		r.logger.Error("Confirm() error: ", err)
+		return err
+	}
+
+	r.confirms = r.channel.NotifyPublish(make(chan amqp.Confirmation, r.options.BatchSize))
+
+	ticker := time.NewTicker(r.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.relay(context.WithoutCancel(ctx)); err != nil {
+			r.logger.WithError(err).Error("failed to relay outbox")
+		}
+
+		if err := r.prune(ctx); err != nil {
+			r.logger.WithError(err).Error("failed to prune outbox")
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Relay.Run(): context cancelled, stopping")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes one batch of pending messages. The rows stay locked while they are published,
+// so relays running in several instances don't publish the same message at once.
+func (r *Relay) relay(ctx context.Context) error {
+	return r.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		messages, err := r.outboxService.Pending(ctx, r.options.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range messages {
+			logger := r.logger.WithFields(logrus.Fields{
+				"message_id": msg.ID,
+				"request_id": GetRequestID(msg.Headers),
+			})
+			ctx := logging.WithLogger(ctx, logger)
+
+			if err := r.publish(ctx, msg); err != nil {
+				logger.WithError(err).Error("failed to publish message")
+				r.metrics.IncPublishFailures()
+
+				if err := r.outboxService.MarkFailed(ctx, msg.ID, err.Error()); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := r.outboxService.MarkSent(ctx, msg.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// publish sends msg and waits for the broker to confirm it.
+// Jobs whose deadline already passed are not published, the consumer would abandon them anyway.
+func (r *Relay) publish(ctx context.Context, msg entity.OutboxMessage) (err error) {
+	headers := amqp.Table(msg.Headers)
+
+	ctx, span := tracer.Start(ctx, "Relay.publish",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithLinks(trace.Link{SpanContext: trace.SpanContextFromContext(ExtractTraceContext(ctx, headers))}),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", msg.Queue),
+			attribute.String("messaging.message.id", msg.ID)))
+	defer tracing.End(span, &err)
+
+	// The row is marked sent once the broker confirmed the message, so the broker has to keep it across restarts.
+	publishing := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.ID,
+		Headers:      headers,
+		Body:         msg.Body,
+	}
+
+	if deadline, ok := GetDeadline(headers); ok {
+		ttl := time.Until(deadline)
+		if ttl <= 0 {
+			logging.FromContext(ctx, r.logger).Warn("dropping expired message")
+			return nil
+		}
+
+		publishing.Expiration = strconv.FormatInt(ttl.Milliseconds(), 10)
+	}
+
+	if err := r.channel.Publish("", msg.Queue, false, false, publishing); err != nil {
+		return err
+	}
+
+	r.sequence++
+
+	return r.waitConfirm(r.sequence)
+}
+
+// waitConfirm waits for the confirmation of the publishing with the given delivery tag.
+// Late confirmations of earlier publishings which timed out are skipped.
+func (r *Relay) waitConfirm(tag uint64) error {
+	timeout := time.NewTimer(r.options.ConfirmTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-r.confirms:
+			if !ok {
+				return errors.New("channel closed while waiting for confirmation")
+			}
+
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+
+			if !confirm.Ack {
+				return fmt.Errorf("%w: nacked", errNotConfirmed)
+			}
+
+			return nil
+		case <-timeout.C:
+			return fmt.Errorf("%w: timed out after %s", errNotConfirmed, r.options.ConfirmTimeout)
+		}
+	}
+}
+
+func (r *Relay) prune(ctx context.Context) error {
+	deleted, err := r.outboxService.Prune(ctx, r.options.Retention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		r.logger.Infof("pruned %d outbox rows", deleted)
+	}
+
+	return nil
+}
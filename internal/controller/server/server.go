@@ -1,11 +1,37 @@
 package server
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"net/http"
+)
 
-func Run(router *gin.Engine, port string) error {
-	if err := router.Run(port); err != nil {
+type Server struct {
+	httpServer *http.Server
+}
+
+// NewServer returns a server listening on addr, e.g. ":8080".
+func NewServer(handler http.Handler, addr string) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:    addr,
+			Handler: handler,
+		},
+	}
+}
+
+// Run serves HTTP requests until the server is shut down.
+// It returns nil once Shutdown was called.
+func (s *Server) Run() error {
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
 	return nil
 }
+
+// Shutdown stops accepting connections and waits for in-flight requests to finish
+// until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
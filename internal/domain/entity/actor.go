@@ -0,0 +1,40 @@
+package entity
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permission is a capability granted to a role.
+type Permission string
+
+const (
+	// PermissionManageAnyImage allows reading, changing and deleting images of other users.
+	PermissionManageAnyImage Permission = "images:manage_any"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {PermissionManageAnyImage},
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
+// Actor is the authenticated user on whose behalf an operation is performed.
+type Actor struct {
+	UserID string
+	Role   string
+}
+
+// HasPermission reports whether the actor's role grants the permission.
+func (a Actor) HasPermission(permission Permission) bool {
+	for _, p := range rolePermissions[a.Role] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,13 @@
 package entity
 
-import "github.com/go-playground/validator"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+)
 
 var validate *validator.Validate
 
@@ -20,9 +27,45 @@ type SignUpInput struct {
 }
 
 func (i SignInInput) Validate() error {
-	return validate.Struct(i)
+	return validateStruct(i)
 }
 
 func (i SignUpInput) Validate() error {
-	return validate.Struct(i)
+	return validateStruct(i)
+}
+
+// validateStruct validates s and reports every rejected field in a validation error.
+func validateStruct(s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	fields := make([]errs.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, errs.FieldError{
+			Field:   strings.ToLower(fe.Field()),
+			Message: fieldMessage(fe),
+		})
+	}
+
+	return errs.NewValidation("invalid input", fields...)
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "gte":
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	default:
+		return fmt.Sprintf("failed on the %q rule", fe.Tag())
+	}
 }
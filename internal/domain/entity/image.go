@@ -2,11 +2,130 @@ package entity
 
 import (
 	"io"
+	"time"
 )
 
+const (
+	FormatJPEG = "jpeg"
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const (
+	VariantOriginal = "original"
+	VariantLarge    = "large"
+	VariantMedium   = "medium"
+	VariantSmall    = "small"
+)
+
+var variantSizes = map[string]int{
+	VariantOriginal: 100,
+	VariantLarge:    75,
+	VariantMedium:   50,
+	VariantSmall:    25,
+}
+
+// VariantSize returns the size in percent of the original for the named variant.
+func VariantSize(name string) (int, bool) {
+	size, ok := variantSizes[name]
+	return size, ok
+}
+
+// VariantName returns the name of the variant stored with the given size in percent.
+func VariantName(size int) string {
+	for name, s := range variantSizes {
+		if s == size {
+			return name
+		}
+	}
+
+	return ""
+}
+
 type Image struct {
 	ID     string
 	Name   string
 	Size   int64
 	Reader io.Reader
 }
+
+// ImageVariant is a single stored rendition of an uploaded image.
+type ImageVariant struct {
+	Size   int
+	Key    string
+	Width  int
+	Height int
+	Bytes  int64
+	URL    string
+}
+
+// ImageMeta describes an uploaded image and all of its stored variants.
+type ImageMeta struct {
+	ID          string
+	UserID      string
+	Title       string
+	Description string
+	Format      string
+	Width       int
+	Height      int
+	Variants    []ImageVariant
+	CreatedAt   time.Time
+}
+
+// Variant returns the variant of the image stored with the given size in percent.
+func (m *ImageMeta) Variant(size int) (ImageVariant, bool) {
+	for _, v := range m.Variants {
+		if v.Size == size {
+			return v, true
+		}
+	}
+
+	return ImageVariant{}, false
+}
+
+// Bytes returns the storage taken by all variants of the image.
+func (m *ImageMeta) Bytes() int64 {
+	var total int64
+	for _, v := range m.Variants {
+		total += v.Bytes
+	}
+
+	return total
+}
+
+// ImageUpdate holds the editable image metadata, nil fields are left unchanged.
+type ImageUpdate struct {
+	Title       *string
+	Description *string
+}
+
+// ImageListParams holds the user facing options of an image listing request.
+type ImageListParams struct {
+	UserID       string
+	Cursor       string
+	Limit        int
+	Order        string
+	Format       string
+	CreatedAfter time.Time
+}
+
+// ImageFilter is the storage level query derived from ImageListParams.
+// AfterCreatedAt and AfterID hold the keyset position of the last returned row.
+type ImageFilter struct {
+	UserID         string
+	Limit          int
+	Order          string
+	Format         string
+	CreatedAfter   time.Time
+	AfterCreatedAt time.Time
+	AfterID        string
+}
+
+// ImagePage is one page of an image listing.
+type ImagePage struct {
+	Images     []ImageMeta
+	NextCursor string
+}
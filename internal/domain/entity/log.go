@@ -1,17 +1,190 @@
 package entity
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
 
+// Audit log action types.
 const (
 	Upload = "upload"
 	Delete = "delete"
+
+	SignUp         = "sign_up"
+	SignIn         = "sign_in"
+	SignInFailed   = "sign_in_failed"
+	ChangePassword = "change_password"
+	RefreshToken   = "refresh_token"
+	ChangeRole     = "change_role"
+	DisableUser    = "disable_user"
+	EnableUser     = "enable_user"
+	RevokeSessions = "revoke_sessions"
+	ResetPassword  = "force_password_reset"
+	DeleteUser     = "delete_user"
+
+	PaymentEvent       = "payment_event"
+	RefundPayment      = "refund_payment"
+	CancelSubscription = "cancel_subscription"
+
+	DeleteAuditLogs = "delete_audit_logs"
 )
 
+// AuditLog records an action. UserID is the actor, empty for actions nobody signed in performed, e.g. failed
+// sign-ins and payment provider events. TargetID is the ID of the user, image or payment acted on.
+// OldData and NewData hold the changed record as JSON before and after the action, if there is one.
+// The logs form a single hash chain in ID order: PrevHash is the Hash of the log written before.
 type AuditLog struct {
 	LogID      int
 	UserID     string
 	ActionType string
-	OldData    []byte
-	NewData    []byte
+	TargetID   string
+	IP         string
+	UserAgent  string
+	OldData    json.RawMessage
+	NewData    json.RawMessage
 	Timestamp  time.Time
+	PrevHash   string
+	Hash       string
+}
+
+// ChainHash returns the hash the log is stored with, covering every field but Hash itself.
+// It's computed from the log as read back from the database, e.g. with JSON normalized and the timestamp
+// rounded to microseconds, so rereading the log yields the same hash.
+func (l *AuditLog) ChainHash() string {
+	h := sha256.New()
+
+	fields := []string{
+		l.PrevHash,
+		strconv.Itoa(l.LogID),
+		l.UserID,
+		l.ActionType,
+		l.TargetID,
+		l.IP,
+		l.UserAgent,
+		string(l.OldData),
+		string(l.NewData),
+		strconv.FormatInt(l.Timestamp.UnixMicro(), 10),
+	}
+
+	// Length prefixes keep the boundaries between fields unambiguous.
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Audit chain break reasons.
+const (
+	// ChainBrokenLink means the log doesn't link to the log before it, which was deleted without a record
+	// or altered.
+	ChainBrokenLink = "broken_link"
+	// ChainHashMismatch means the log was altered after it was written.
+	ChainHashMismatch = "hash_mismatch"
+	// ChainTruncated means the chain doesn't end at its head, the last logs were deleted without a record.
+	ChainTruncated = "truncated"
+)
+
+// ChainBreak is a log whose place in the hash chain doesn't check out. For ChainTruncated it's the head.
+type ChainBreak struct {
+	LogID      int
+	ActionType string
+	Reason     string
+}
+
+// ChainReport is the outcome of verifying the audit log hash chain. Unchained counts the logs written before
+// the chain existed, which can't be verified, Gaps the recorded deletions the chain was followed across.
+// Breaks lists at most a limited number of breaks, Truncated is set if there were more.
+type ChainReport struct {
+	Checked   int64
+	Unchained int64
+	Gaps      int64
+	Breaks    []ChainBreak
+	Truncated bool
+}
+
+// ChainGap records logs deleted from the hash chain: every log after AfterID up to and including ThroughID.
+// AfterHash is the hash of the log the gap follows, empty at the start of the chain, and ThroughHash the hash
+// the log after the gap links to.
+type ChainGap struct {
+	AfterID     int
+	AfterHash   string
+	ThroughID   int
+	ThroughHash string
+	Deleted     int64
+}
+
+// ChainAnchors holds what the hash chain is verified against besides the logs. Logs up to StartID predate the
+// chain. HeadID and HeadHash are the last log written, Gaps the recorded deletions keyed by AfterID.
+type ChainAnchors struct {
+	StartID  int
+	HeadID   int
+	HeadHash string
+	Gaps     map[int]ChainGap
+}
+
+// RetentionPolicy tells how long audit logs are kept. ByAction overrides Default for the listed action types,
+// a zero duration keeps the logs forever.
+type RetentionPolicy struct {
+	Default  time.Duration
+	ByAction map[string]time.Duration
+}
+
+// For returns how long logs of the action type are kept.
+func (p RetentionPolicy) For(actionType string) time.Duration {
+	if retention, ok := p.ByAction[actionType]; ok {
+		return retention
+	}
+
+	return p.Default
+}
+
+// AuditLogQuery selects audit logs, zero fields don't filter. ActionTypes matches any of the listed actions,
+// From is inclusive and To exclusive.
+type AuditLogQuery struct {
+	UserID      string
+	TargetID    string
+	ActionTypes []string
+	From        time.Time
+	To          time.Time
+}
+
+// IsEmpty reports whether the query selects every audit log.
+func (q AuditLogQuery) IsEmpty() bool {
+	return q.UserID == "" && q.TargetID == "" && len(q.ActionTypes) == 0 && q.From.IsZero() && q.To.IsZero()
+}
+
+// AuditLogListParams holds the options of an audit log listing.
+type AuditLogListParams struct {
+	AuditLogQuery
+	Cursor string
+	Limit  int
+}
+
+// AuditLogFilter is the storage level query derived from AuditLogListParams. Logs are listed newest first
+// unless Order is OrderAsc, AfterTimestamp and AfterID hold the keyset position of the last returned row.
+// A zero Limit returns every matching log.
+type AuditLogFilter struct {
+	AuditLogQuery
+	Order          string
+	Limit          int
+	AfterTimestamp time.Time
+	AfterID        int64
+}
+
+// AuditLogPage is one page of an audit log listing.
+type AuditLogPage struct {
+	Logs       []AuditLog
+	NextCursor string
+}
+
+// RequestInfo describes the client a request came from and the user it authenticated as, if any.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	UserID    string
 }
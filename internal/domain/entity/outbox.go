@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// OutboxMessage is a queue message stored in the outbox until the relay has published it.
+// ID doubles as the idempotency key consumers use to drop redelivered messages.
+type OutboxMessage struct {
+	ID        string
+	Queue     string
+	Headers   map[string]interface{}
+	Body      []byte
+	Attempts  int
+	CreatedAt time.Time
+}
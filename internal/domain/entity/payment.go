@@ -0,0 +1,109 @@
+package entity
+
+import "time"
+
+const (
+	PaymentKindIntent   = "payment_intent"
+	PaymentKindCheckout = "checkout_session"
+)
+
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentCanceled  = "canceled"
+	// PaymentPartiallyRefunded and PaymentRefunded are succeeded payments refunded in part or in full.
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// Payment records a payment for a plan started with a payment provider.
+type Payment struct {
+	ID string
+	// UserID is empty once the user was deleted, their payments are kept.
+	UserID string
+	PlanID string
+	Kind   string
+	// Provider names the payment provider and ProviderID is the ID it gave the payment intent or checkout session.
+	Provider    string
+	ProviderID  string
+	AmountCents int64
+	// RefundedCents is the part of AmountCents refunded so far.
+	RefundedCents int64
+	Currency      string
+	Status        string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// IsPaid reports whether the payment succeeded, regardless of later refunds.
+func (p Payment) IsPaid() bool {
+	return p.Status == PaymentSucceeded || p.Status == PaymentPartiallyRefunded || p.Status == PaymentRefunded
+}
+
+// RefundableCents returns the amount which can still be refunded.
+func (p Payment) RefundableCents() int64 {
+	if !p.IsPaid() {
+		return 0
+	}
+
+	return p.AmountCents - p.RefundedCents
+}
+
+// Refund is a refund of a payment issued by an admin.
+type Refund struct {
+	ID         string
+	PaymentID  string
+	ProviderID string
+	// AmountCents is in the currency of the payment.
+	AmountCents int64
+	Reason      string
+	// CreatedBy is the ID of the admin who issued the refund.
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// PaymentListParams holds the options of an admin payment listing, zero fields don't filter.
+type PaymentListParams struct {
+	UserID        string
+	PlanID        string
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Cursor        string
+	Limit         int
+}
+
+// PaymentFilter is the storage level query derived from PaymentListParams. Payments are listed newest first,
+// AfterCreatedAt and AfterID hold the keyset position of the last returned row.
+type PaymentFilter struct {
+	UserID         string
+	PlanID         string
+	Status         string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	Limit          int
+	AfterCreatedAt time.Time
+	AfterID        string
+}
+
+// PaymentPage is one page of a payment listing.
+type PaymentPage struct {
+	Payments   []Payment
+	NextCursor string
+}
+
+// BillingHistory is everything a user was billed and refunded together with their current subscription.
+type BillingHistory struct {
+	Subscription Subscription
+	Payments     []Payment
+	Refunds      []Refund
+}
+
+// PaymentStart is a freshly created payment together with what the client needs to complete it.
+// ClientSecret is set for payment intents and CheckoutURL for checkout sessions.
+type PaymentStart struct {
+	Payment      Payment
+	ClientSecret string
+	CheckoutURL  string
+}
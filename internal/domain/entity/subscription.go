@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+const (
+	PlanFree     = "free"
+	PlanPro      = "pro"
+	PlanBusiness = "business"
+)
+
+const (
+	SubscriptionActive   = "active"
+	SubscriptionCanceled = "canceled"
+	SubscriptionExpired  = "expired"
+)
+
+// Plan is a subscription tier. Users without an active subscription are on the free plan.
+type Plan struct {
+	ID         string
+	Name       string
+	Tier       int
+	PriceCents int64
+	Currency   string
+	Interval   string
+	Limits     PlanLimits
+}
+
+// PlanLimits caps what users of a plan may upload and store. A limit of 0 means unlimited.
+type PlanLimits struct {
+	MaxImagesPerMonth int
+	MaxStorageBytes   int64
+	MaxFileBytes      int64
+	// MaxDimension caps both the width and the height of uploaded images in pixels.
+	MaxDimension   int
+	AllowedFormats []string
+}
+
+// AllowsFormat reports whether images in the given format may be uploaded.
+func (l PlanLimits) AllowsFormat(format string) bool {
+	for _, f := range l.AllowedFormats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	ID                 string
+	UserID             string
+	Plan               Plan
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	// CancelAtPeriodEnd means the subscription stays active until CurrentPeriodEnd and isn't renewed.
+	CancelAtPeriodEnd bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// IsActive reports whether the subscription grants its plan at the given time.
+func (s *Subscription) IsActive(at time.Time) bool {
+	return s.Status == SubscriptionActive && at.Before(s.CurrentPeriodEnd)
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// Names of the plan limits reported in quota errors.
+const (
+	LimitImagesPerMonth = "images_per_month"
+	LimitStorageBytes   = "storage_bytes"
+	LimitFileBytes      = "file_bytes"
+	LimitDimension      = "dimension"
+)
+
+// Usage is what a user consumed of the limits of their plan.
+type Usage struct {
+	UserID string
+	Plan   Plan
+	// Month is the start of the calendar month, in UTC, ImagesThisMonth is counted for.
+	Month           time.Time
+	ImagesThisMonth int
+	BytesStored     int64
+	// PendingImages and PendingBytes are held by uploads which are queued but not processed yet.
+	PendingImages int
+	PendingBytes  int64
+}
+
+// UploadInfo describes an image a user asks to upload.
+type UploadInfo struct {
+	// Bytes is the size of the uploaded file.
+	Bytes  int64
+	Width  int
+	Height int
+	Format string
+	// StoredBytes estimates the size of the variants stored for the image.
+	StoredBytes int64
+}
+
+// UploadReservation holds quota for a queued upload job until the job is processed or its deadline passed.
+type UploadReservation struct {
+	// ID is the ID of the outbox message of the job.
+	ID        string
+	UserID    string
+	Bytes     int64
+	ExpiresAt time.Time
+}
+
+// MonthStart returns the start of the calendar month of t in UTC.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
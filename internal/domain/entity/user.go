@@ -11,6 +11,51 @@ type User struct {
 	Password       string
 	PhotosUploaded int
 	Role           string
+	Disabled       bool
 	refresh_token  string
 	RegisteredAt   time.Time
+	// PasswordResetRequired keeps the user from signing in until they change their password.
+	PasswordResetRequired bool
+	// SessionsRevokedAt invalidates the tokens issued before it, it's zero if sessions were never revoked.
+	SessionsRevokedAt time.Time
+}
+
+// User statuses as shown to admins. Suspended users are the disabled ones.
+const (
+	UserActive    = "active"
+	UserSuspended = "suspended"
+)
+
+// UserListParams holds the options of an admin user listing, zero fields don't filter.
+// Query matches a part of the name or email.
+type UserListParams struct {
+	Query  string
+	Role   string
+	Status string
+	Cursor string
+	Limit  int
+}
+
+// UserFilter is the storage level query derived from UserListParams. Users are listed newest first,
+// AfterRegisteredAt and AfterID hold the keyset position of the last returned row.
+type UserFilter struct {
+	Query             string
+	Role              string
+	Status            string
+	Limit             int
+	AfterRegisteredAt time.Time
+	AfterID           string
+}
+
+// UserPage is one page of a user listing.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
+
+// UserDetails is a user together with their current subscription and usage.
+type UserDetails struct {
+	User         User
+	Subscription Subscription
+	Usage        Usage
 }
@@ -0,0 +1,92 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code classifies an error independently of the layer it was produced in.
+type Code string
+
+const (
+	Internal      Code = "internal"
+	NotFound      Code = "not_found"
+	Conflict      Code = "conflict"
+	Validation    Code = "validation"
+	Unauthorized  Code = "unauthorized"
+	Forbidden     Code = "forbidden"
+	QuotaExceeded Code = "quota_exceeded"
+	TooLarge      Code = "too_large"
+	Timeout       Code = "timeout"
+)
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Quota describes the plan limit a request ran into.
+type Quota struct {
+	Plan  string
+	Limit string
+	Max   int64
+	Used  int64
+}
+
+// Error is a typed domain error.
+// Message is safe to show to clients, the wrapped error is kept for logs only.
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Quota   *Quota
+	Err     error
+}
+
+// New returns an error with the given code and client facing message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap returns an error with the given code and client facing message which wraps err.
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// NewValidation returns a validation error carrying the rejected fields.
+func NewValidation(message string, fields ...FieldError) *Error {
+	return &Error{Code: Validation, Message: message, Fields: fields}
+}
+
+// NewQuota returns an error with the given code and client facing message reporting the exceeded limit.
+func NewQuota(code Code, message string, quota Quota) *Error {
+	return &Error{Code: code, Message: message, Quota: &quota}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CodeOf returns the code of the first typed error in err's chain, or Internal if there is none.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return Internal
+}
+
+// Is reports whether err's chain contains a typed error with the given code.
+func Is(err error, code Code) bool {
+	return err != nil && CodeOf(err) == code
+}
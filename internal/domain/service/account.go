@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrSelfManagement = errs.New(errs.Conflict, "admins can't demote, suspend or delete themselves")
+
+// Accounts is the admin side of users: it finds them, changes their role and status, ends their sessions
+// and deletes them. Every change is recorded in the audit log.
+type Accounts interface {
+	// List returns a page of the users matching params, newest first.
+	// It returns ErrInvalidCursor if params.Cursor wasn't issued by a previous List call.
+	List(ctx context.Context, params entity.UserListParams) (*entity.UserPage, error)
+
+	// Get returns the user with the given ID together with their subscription and usage.
+	// It returns psqldb.ErrUserNotFound if there is no such user.
+	Get(ctx context.Context, id string) (*entity.UserDetails, error)
+
+	// SetRole changes the role of the user and returns the updated user.
+	// It returns ErrInvalidRole if the role is unknown and ErrSelfManagement if admins demote themselves.
+	SetRole(ctx context.Context, actor entity.Actor, id, role string) (*entity.User, error)
+
+	// SetSuspended suspends or reactivates the user and returns the updated user. Suspended users can neither
+	// sign in nor use the tokens they hold. It returns ErrSelfManagement if admins suspend themselves.
+	SetSuspended(ctx context.Context, actor entity.Actor, id string, suspended bool) (*entity.User, error)
+
+	// ForcePasswordReset revokes the sessions of the user and keeps them from signing in until they
+	// change their password.
+	ForcePasswordReset(ctx context.Context, actor entity.Actor, id string) error
+
+	// RevokeSessions invalidates every token issued to the user so far.
+	RevokeSessions(ctx context.Context, actor entity.Actor, id string) error
+
+	// Delete deletes the images of the user and then the user together with their subscription. Their payments
+	// and refunds are kept for the billing records, without the user.
+	// It returns ErrSelfManagement if admins delete themselves.
+	Delete(ctx context.Context, actor entity.Actor, id string) error
+}
+
+type accountService struct {
+	storage       psqldb.UserStorage
+	users         Users
+	images        Images
+	quota         Quotas
+	subscriptions Subscription
+	auditor       Auditor
+	logger        *logrus.Logger
+}
+
+func NewAccountService(storage psqldb.UserStorage, users Users, images Images, quota Quotas, subscriptions Subscription, auditor Auditor, logger *logrus.Logger) *accountService {
+	return &accountService{
+		storage:       storage,
+		users:         users,
+		images:        images,
+		quota:         quota,
+		subscriptions: subscriptions,
+		auditor:       auditor,
+		logger:        logger,
+	}
+}
+
+func (s *accountService) List(ctx context.Context, params entity.UserListParams) (*entity.UserPage, error) {
+	filter := entity.UserFilter{
+		Query:  params.Query,
+		Role:   params.Role,
+		Status: params.Status,
+		// One extra user tells whether there is a next page.
+		Limit: params.Limit + 1,
+	}
+
+	if params.Cursor != "" {
+		registeredAt, id, err := decodeCursor(params.Cursor)
+		if err == nil {
+			_, err = uuid.Parse(id)
+		}
+
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("List: failed to decode cursor")
+			return nil, ErrInvalidCursor
+		}
+
+		filter.AfterRegisteredAt, filter.AfterID = registeredAt, id
+	}
+
+	users, err := s.storage.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.UserPage{Users: users}
+
+	if len(users) > params.Limit {
+		page.Users = users[:params.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeCursor(last.RegisteredAt, last.ID)
+	}
+
+	return page, nil
+}
+
+func (s *accountService) Get(ctx context.Context, id string) (*entity.UserDetails, error) {
+	user, err := s.users.GetCredentials(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.subscriptions.Current(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.quota.Usage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.UserDetails{User: *user, Subscription: *sub, Usage: *usage}, nil
+}
+
+func (s *accountService) SetRole(ctx context.Context, actor entity.Actor, id, role string) (*entity.User, error) {
+	if actor.UserID == id && role != entity.RoleAdmin {
+		return nil, ErrSelfManagement
+	}
+
+	if err := s.users.SetRole(ctx, id, role); err != nil {
+		return nil, err
+	}
+
+	return s.users.GetCredentials(ctx, id, false)
+}
+
+func (s *accountService) SetSuspended(ctx context.Context, actor entity.Actor, id string, suspended bool) (*entity.User, error) {
+	if actor.UserID == id && suspended {
+		return nil, ErrSelfManagement
+	}
+
+	if err := s.users.SetDisabled(ctx, id, suspended); err != nil {
+		return nil, err
+	}
+
+	return s.users.GetCredentials(ctx, id, false)
+}
+
+func (s *accountService) ForcePasswordReset(ctx context.Context, actor entity.Actor, id string) error {
+	if err := s.storage.RequirePasswordReset(ctx, id); err != nil {
+		return err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{ActionType: entity.ResetPassword, TargetID: id})
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "ForcePasswordReset", "target_user_id": id}).Info("password reset required")
+	return nil
+}
+
+func (s *accountService) RevokeSessions(ctx context.Context, actor entity.Actor, id string) error {
+	if err := s.storage.RevokeSessions(ctx, id); err != nil {
+		return err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{ActionType: entity.RevokeSessions, TargetID: id})
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "RevokeSessions", "target_user_id": id}).Info("sessions revoked")
+	return nil
+}
+
+func (s *accountService) Delete(ctx context.Context, actor entity.Actor, id string) error {
+	logger := logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "Delete", "target_user_id": id})
+
+	if actor.UserID == id {
+		return ErrSelfManagement
+	}
+
+	user, err := s.users.GetCredentials(ctx, id, false)
+	if err != nil {
+		return err
+	}
+
+	// Images go first, their objects live outside the database. If deleting the user fails afterwards it's
+	// left without images and can be deleted again.
+	images, err := s.images.DeleteByUser(ctx, actor, id)
+	if err != nil {
+		logger.WithError(err).WithField("deleted_images", images).Error("failed to delete images")
+		return fmt.Errorf("failed to delete images of user %s: %w", id, err)
+	}
+
+	if err := s.storage.Delete(ctx, id); err != nil {
+		logger.WithError(err).Error("failed to delete user")
+		return err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		ActionType: entity.DeleteUser,
+		TargetID:   id,
+		OldData: auditJSON(map[string]interface{}{
+			"id":             user.ID,
+			"name":           user.Name,
+			"email":          user.Email,
+			"role":           user.Role,
+			"disabled":       user.Disabled,
+			"registered_at":  user.RegisteredAt,
+			"deleted_images": images,
+		}),
+	})
+
+	logger.WithField("deleted_images", images).Info("user deleted")
+	return nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/metrics"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying the client of the request, which audit logs are tagged with.
+func WithRequestInfo(ctx context.Context, info entity.RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// WithRequestUser returns a copy of ctx whose request info names the authenticated user.
+func WithRequestUser(ctx context.Context, userID string) context.Context {
+	info := requestInfoFrom(ctx)
+	info.UserID = userID
+
+	return WithRequestInfo(ctx, info)
+}
+
+func requestInfoFrom(ctx context.Context) entity.RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(entity.RequestInfo)
+	return info
+}
+
+// Auditor records audit logs.
+type Auditor interface {
+	// Record queues the log for writing and returns right away, the log may be lost if writing fails.
+	// Missing actor, IP and user agent are filled in from the request info in ctx, see WithRequestInfo,
+	// a missing timestamp with the current time.
+	Record(ctx context.Context, log entity.AuditLog)
+}
+
+type auditItem struct {
+	ctx context.Context
+	log entity.AuditLog
+}
+
+// AuditWriterOptions configures the AuditWriter.
+type AuditWriterOptions struct {
+	// BufferSize is how many logs may wait for writing, logs recorded while the buffer is full are dropped.
+	BufferSize int
+	// WriteTimeout limits the time spent writing a single log.
+	WriteTimeout time.Duration
+}
+
+// AuditWriter is an Auditor writing logs in the background, so recording never blocks the caller.
+type AuditWriter struct {
+	storage psqldb.DashboardStorage
+	logger  *logrus.Logger
+	metrics *metrics.Metrics
+	options AuditWriterOptions
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan auditItem
+	done   chan struct{}
+}
+
+func NewAuditWriter(storage psqldb.DashboardStorage, logger *logrus.Logger, metrics *metrics.Metrics, options AuditWriterOptions) *AuditWriter {
+	return &AuditWriter{
+		storage: storage,
+		logger:  logger,
+		metrics: metrics,
+		options: options,
+		queue:   make(chan auditItem, options.BufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// fillRequestInfo fills in the missing actor, IP and user agent of log from the request info in ctx and a missing
+// timestamp with the current time.
+func fillRequestInfo(ctx context.Context, log *entity.AuditLog) {
+	info := requestInfoFrom(ctx)
+	if log.UserID == "" {
+		log.UserID = info.UserID
+	}
+
+	if log.IP == "" {
+		log.IP = info.IP
+	}
+
+	if log.UserAgent == "" {
+		log.UserAgent = info.UserAgent
+	}
+
+	if log.Timestamp.IsZero() {
+		log.Timestamp = time.Now()
+	}
+}
+
+func (w *AuditWriter) Record(ctx context.Context, log entity.AuditLog) {
+	fillRequestInfo(ctx, &log)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.closed {
+		select {
+		// The log outlives the request, its context mustn't cancel the write.
+		case w.queue <- auditItem{ctx: context.WithoutCancel(ctx), log: log}:
+			return
+		default:
+		}
+	}
+
+	w.metrics.IncAuditDropped()
+	logging.FromContext(ctx, w.logger).WithField("action_type", log.ActionType).Warn("Record: audit log dropped")
+}
+
+// Run writes the recorded logs until Close is called and every queued log is written.
+func (w *AuditWriter) Run() {
+	defer close(w.done)
+
+	for item := range w.queue {
+		w.write(item)
+	}
+}
+
+// Close stops accepting logs and waits until the queued ones are written or ctx is done.
+func (w *AuditWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *AuditWriter) write(item auditItem) {
+	ctx, cancel := context.WithTimeout(item.ctx, w.options.WriteTimeout)
+	defer cancel()
+
+	if err := w.storage.CreateLog(ctx, &item.log); err != nil {
+		w.metrics.IncAuditDropped()
+		logging.FromContext(ctx, w.logger).WithError(err).WithField("action_type", item.log.ActionType).Error("write: failed to write audit log")
+	}
+}
+
+// auditJSON encodes the data of an audit log, nil stays empty.
+func auditJSON(data map[string]interface{}) json.RawMessage {
+	if data == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+
+	return encoded
+}
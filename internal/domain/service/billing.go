@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/payment"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidRefundAmount  = errs.New(errs.Validation, "refund amount exceeds what's left of the payment")
+	ErrPaymentNotRefundable = errs.New(errs.Conflict, "payment is not refundable")
+)
+
+// Billing is the admin side of payments: it lists them, refunds them and cancels subscriptions.
+// Every change is recorded in the audit log with the record before and after it.
+type Billing interface {
+	// Payments returns a page of the payments matching params, newest first.
+	// It returns ErrInvalidCursor if params.Cursor wasn't issued by a previous Payments call.
+	Payments(ctx context.Context, params entity.PaymentListParams) (*entity.PaymentPage, error)
+
+	// History returns the subscription, payments and refunds of the user.
+	// It returns psqldb.ErrUserNotFound if there is no such user.
+	History(ctx context.Context, userID string) (*entity.BillingHistory, error)
+
+	// Refund refunds amountCents of the payment through the provider, or what's left of it if amountCents is 0.
+	// It returns the refund and the updated payment.
+	// It returns ErrPaymentNotRefundable if the payment didn't succeed or is refunded in full already and
+	// ErrInvalidRefundAmount if amountCents is more than what's left.
+	Refund(ctx context.Context, actor entity.Actor, paymentID string, amountCents int64, reason string) (*entity.Refund, *entity.Payment, error)
+
+	// CancelSubscription cancels the active subscription of the user, see Subscription.Cancel.
+	CancelSubscription(ctx context.Context, actor entity.Actor, userID string, atPeriodEnd bool) (*entity.Subscription, error)
+}
+
+type billingService struct {
+	payments      psqldb.PaymentStorage
+	subscriptions Subscription
+	users         Users
+	auditor       Auditor
+	provider      payment.Provider
+	txManager     psqldb.TxManager
+	logger        *logrus.Logger
+}
+
+func NewBillingService(payments psqldb.PaymentStorage, subscriptions Subscription, users Users, auditor Auditor, provider payment.Provider, txManager psqldb.TxManager, logger *logrus.Logger) *billingService {
+	return &billingService{
+		payments:      payments,
+		subscriptions: subscriptions,
+		users:         users,
+		auditor:       auditor,
+		provider:      provider,
+		txManager:     txManager,
+		logger:        logger,
+	}
+}
+
+func (s *billingService) Payments(ctx context.Context, params entity.PaymentListParams) (*entity.PaymentPage, error) {
+	filter := entity.PaymentFilter{
+		UserID:        params.UserID,
+		PlanID:        params.PlanID,
+		Status:        params.Status,
+		CreatedAfter:  params.CreatedAfter,
+		CreatedBefore: params.CreatedBefore,
+		// One extra payment tells whether there is a next page.
+		Limit: params.Limit + 1,
+	}
+
+	if params.Cursor != "" {
+		createdAt, id, err := decodeCursor(params.Cursor)
+		if err == nil {
+			_, err = uuid.Parse(id)
+		}
+
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Payments: failed to decode cursor")
+			return nil, ErrInvalidCursor
+		}
+
+		filter.AfterCreatedAt, filter.AfterID = createdAt, id
+	}
+
+	payments, err := s.payments.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.PaymentPage{Payments: payments}
+
+	if len(payments) > params.Limit {
+		page.Payments = payments[:params.Limit]
+		last := page.Payments[len(page.Payments)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return page, nil
+}
+
+func (s *billingService) History(ctx context.Context, userID string) (*entity.BillingHistory, error) {
+	if _, err := s.users.GetCredentials(ctx, userID, false); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.subscriptions.Current(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := s.payments.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds, err := s.payments.ListRefundsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.BillingHistory{
+		Subscription: *sub,
+		Payments:     payments,
+		Refunds:      refunds,
+	}, nil
+}
+
+func (s *billingService) Refund(ctx context.Context, actor entity.Actor, paymentID string, amountCents int64, reason string) (*entity.Refund, *entity.Payment, error) {
+	logger := logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "Refund", "payment_id": paymentID})
+
+	var (
+		old, updated *entity.Payment
+		refund       *entity.Refund
+	)
+
+	// The payment stays locked from reading what's left of it until the refund is recorded, so concurrent
+	// refunds of the same payment can't both pass the check and refund more than was paid.
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) (err error) {
+		old, err = s.payments.GetForUpdate(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+
+		refundable := old.RefundableCents()
+		if refundable <= 0 {
+			return fmt.Errorf("%w: %s is %s", ErrPaymentNotRefundable, paymentID, old.Status)
+		}
+
+		if amountCents == 0 {
+			amountCents = refundable
+		}
+
+		if amountCents > refundable {
+			return invalidRefundAmount(refundable)
+		}
+
+		refund = &entity.Refund{
+			ID:          uuid.NewString(),
+			PaymentID:   old.ID,
+			AmountCents: amountCents,
+			Reason:      reason,
+			CreatedBy:   actor.UserID,
+		}
+
+		providerRefund, err := s.provider.Refund(ctx, payment.RefundParams{
+			PaymentID:   old.ProviderID,
+			AmountCents: amountCents,
+			Reason:      reason,
+			Metadata: map[string]string{
+				PaymentMetadataID: old.ID,
+				"refund_id":       refund.ID,
+			},
+			IdempotencyKey: refund.ID,
+		})
+		if err != nil {
+			if errors.Is(err, payment.ErrNotRefundable) {
+				return fmt.Errorf("%w: %s", ErrPaymentNotRefundable, paymentID)
+			}
+
+			logger.WithError(err).Error("failed to refund payment")
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+
+		refund.ProviderID = providerRefund.ID
+
+		updated, err = s.payments.RecordRefund(ctx, refund)
+		if err != nil {
+			// The money is back with the customer at this point, the record has to be fixed by hand.
+			logger.WithError(err).WithField("provider_refund_id", providerRefund.ID).Error("failed to record refund issued with the provider")
+
+			if errors.Is(err, psqldb.ErrRefundTooLarge) {
+				return invalidRefundAmount(refundable)
+			}
+
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		UserID:     actor.UserID,
+		ActionType: entity.RefundPayment,
+		TargetID:   updated.ID,
+		OldData:    auditJSON(paymentAuditData(*old)),
+		NewData:    auditJSON(paymentAuditData(*updated)),
+	})
+
+	logger.WithFields(logrus.Fields{"refund_id": refund.ID, "amount_cents": amountCents}).Info("payment refunded")
+	return refund, updated, nil
+}
+
+// invalidRefundAmount returns ErrInvalidRefundAmount with the amount left to refund.
+func invalidRefundAmount(refundable int64) error {
+	return errs.NewValidation(ErrInvalidRefundAmount.Message, errs.FieldError{
+		Field:   "amount_cents",
+		Message: fmt.Sprintf("must be at most %d", refundable),
+	})
+}
+
+func (s *billingService) CancelSubscription(ctx context.Context, actor entity.Actor, userID string, atPeriodEnd bool) (*entity.Subscription, error) {
+	logger := logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "CancelSubscription", "target_user_id": userID})
+
+	var old, canceled *entity.Subscription
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) (err error) {
+		old, err = s.subscriptions.Current(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// The free plan fallback isn't a subscription which can be canceled.
+		if old.ID == "" {
+			return fmt.Errorf("%w: %s", psqldb.ErrSubscriptionNotFound, userID)
+		}
+
+		canceled, err = s.subscriptions.Cancel(ctx, userID, atPeriodEnd)
+		return err
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to cancel subscription")
+		return nil, err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		UserID:     actor.UserID,
+		ActionType: entity.CancelSubscription,
+		TargetID:   userID,
+		OldData:    auditJSON(subscriptionAuditData(*old)),
+		NewData:    auditJSON(subscriptionAuditData(*canceled)),
+	})
+
+	logger.WithField("at_period_end", atPeriodEnd).Info("subscription canceled")
+	return canceled, nil
+}
+
+func paymentAuditData(p entity.Payment) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             p.ID,
+		"user_id":        p.UserID,
+		"plan_id":        p.PlanID,
+		"provider_id":    p.ProviderID,
+		"amount_cents":   p.AmountCents,
+		"refunded_cents": p.RefundedCents,
+		"currency":       p.Currency,
+		"status":         p.Status,
+	}
+}
+
+func subscriptionAuditData(sub entity.Subscription) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   sub.ID,
+		"user_id":              sub.UserID,
+		"plan_id":              sub.Plan.ID,
+		"status":               sub.Status,
+		"current_period_start": sub.CurrentPeriodStart,
+		"current_period_end":   sub.CurrentPeriodEnd,
+		"cancel_at_period_end": sub.CancelAtPeriodEnd,
+	}
+}
@@ -2,25 +2,57 @@ package service
 
 import (
 	"context"
+	"strconv"
+
 	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/sirupsen/logrus"
 )
 
+var ErrEmptyLogQuery = errs.New(errs.Validation, "refusing to delete every audit log, narrow the query down")
+
 type Dashboards interface {
 	CreateLog(ctx context.Context, log *entity.AuditLog) error
 
-	GetLogs(ctx context.Context) ([]entity.AuditLog, error)
+	// Logs returns a page of the audit logs matching params, newest first.
+	// It returns ErrInvalidCursor if params.Cursor wasn't issued by a previous Logs call.
+	Logs(ctx context.Context, params entity.AuditLogListParams) (*entity.AuditLogPage, error)
 
+	// ExportLogs calls fn with every audit log matching the query, oldest first.
+	// It stops at and returns the first error fn returns.
+	ExportLogs(ctx context.Context, query entity.AuditLogQuery, fn func(log *entity.AuditLog) error) error
+
+	// DeleteLog deletes the audit log with the given ID and logs the deletion in the same transaction.
+	// It returns psqldb.ErrLogNotFound if there is no such log.
 	DeleteLog(ctx context.Context, id int64) error
+
+	// DeleteLogs deletes the audit logs matching the query, logs the deletion in the same transaction and returns
+	// how many were deleted.
+	// It returns ErrEmptyLogQuery if the query would match every log.
+	DeleteLogs(ctx context.Context, query entity.AuditLogQuery) (int64, error)
+
+	// VerifyLogs checks the hash chain of the logs and reports the logs which were altered or follow logs deleted
+	// without a record, and whether logs at the end were deleted. Deletions through DeleteLog, DeleteLogs and the
+	// retention are recorded as gaps the chain is followed across.
+	VerifyLogs(ctx context.Context) (*entity.ChainReport, error)
 }
 
+// maxChainBreaks bounds the breaks listed in a ChainReport.
+const maxChainBreaks = 100
+
 type dashboardService struct {
 	dashboardStorage psqldb.DashboardStorage
+	txManager        psqldb.TxManager
+	logger           *logrus.Logger
 }
 
-func NewDashboardService(dashboardStorage psqldb.DashboardStorage) *dashboardService {
+func NewDashboardService(dashboardStorage psqldb.DashboardStorage, txManager psqldb.TxManager, logger *logrus.Logger) *dashboardService {
 	return &dashboardService{
 		dashboardStorage: dashboardStorage,
+		txManager:        txManager,
+		logger:           logger,
 	}
 }
 
@@ -28,10 +60,180 @@ func (s *dashboardService) CreateLog(ctx context.Context, log *entity.AuditLog)
 	return s.dashboardStorage.CreateLog(ctx, log)
 }
 
-func (s *dashboardService) GetLogs(ctx context.Context) ([]entity.AuditLog, error) {
-	return s.dashboardStorage.GetLogs(ctx)
+func (s *dashboardService) Logs(ctx context.Context, params entity.AuditLogListParams) (*entity.AuditLogPage, error) {
+	filter := entity.AuditLogFilter{
+		AuditLogQuery: params.AuditLogQuery,
+		Order:         entity.OrderDesc,
+		// One extra log tells whether there is a next page.
+		Limit: params.Limit + 1,
+	}
+
+	if params.Cursor != "" {
+		timestamp, id, err := decodeCursor(params.Cursor)
+		if err == nil {
+			filter.AfterID, err = strconv.ParseInt(id, 10, 64)
+		}
+
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Logs: failed to decode cursor")
+			return nil, ErrInvalidCursor
+		}
+
+		filter.AfterTimestamp = timestamp
+	}
+
+	logs, err := s.dashboardStorage.ListLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.AuditLogPage{Logs: logs}
+
+	if len(logs) > params.Limit {
+		page.Logs = logs[:params.Limit]
+		last := page.Logs[len(page.Logs)-1]
+		page.NextCursor = encodeCursor(last.Timestamp, strconv.Itoa(last.LogID))
+	}
+
+	return page, nil
+}
+
+func (s *dashboardService) ExportLogs(ctx context.Context, query entity.AuditLogQuery, fn func(log *entity.AuditLog) error) error {
+	return s.dashboardStorage.StreamLogs(ctx, entity.AuditLogFilter{AuditLogQuery: query, Order: entity.OrderAsc}, fn)
 }
 
 func (s *dashboardService) DeleteLog(ctx context.Context, id int64) error {
-	return s.dashboardStorage.DeleteLog(ctx, id)
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.dashboardStorage.DeleteLog(ctx, id); err != nil {
+			return err
+		}
+
+		return s.recordDeletion(ctx, entity.AuditLog{
+			ActionType: entity.DeleteAuditLogs,
+			TargetID:   strconv.FormatInt(id, 10),
+			NewData:    auditJSON(map[string]interface{}{"deleted": 1}),
+		})
+	})
+}
+
+func (s *dashboardService) DeleteLogs(ctx context.Context, query entity.AuditLogQuery) (int64, error) {
+	if query.IsEmpty() {
+		return 0, ErrEmptyLogQuery
+	}
+
+	var deleted int64
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if deleted, err = s.dashboardStorage.DeleteLogs(ctx, query); err != nil {
+			return err
+		}
+
+		return s.recordDeletion(ctx, entity.AuditLog{
+			ActionType: entity.DeleteAuditLogs,
+			NewData: auditJSON(map[string]interface{}{
+				"user_id":      query.UserID,
+				"target_id":    query.TargetID,
+				"action_types": query.ActionTypes,
+				"from":         query.From,
+				"to":           query.To,
+				"deleted":      deleted,
+			}),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"function": "DeleteLogs", "deleted": deleted}).Info("audit logs deleted")
+	return deleted, nil
+}
+
+// recordDeletion writes the log of a deletion of audit logs right away rather than through the auditor, so it's
+// written in the same transaction as the deletion and the gap it leaves in the chain, or neither is.
+func (s *dashboardService) recordDeletion(ctx context.Context, log entity.AuditLog) error {
+	fillRequestInfo(ctx, &log)
+	return s.dashboardStorage.CreateLog(ctx, &log)
+}
+
+func (s *dashboardService) VerifyLogs(ctx context.Context) (*entity.ChainReport, error) {
+	report := &entity.ChainReport{}
+
+	// The anchors and the logs are read from one snapshot, so logs written or pruned meanwhile don't show up as breaks.
+	err := s.txManager.WithinSnapshot(ctx, func(ctx context.Context) error {
+		anchors, err := s.dashboardStorage.ChainAnchors(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The last log seen, the chain starts after log 0 with an empty hash.
+		var prevID int
+		var prevHash string
+
+		// next returns the ID and hash the log after the last one seen links to, following a gap recorded after it.
+		// It reports false if the gap doesn't follow that log.
+		next := func() (int, string, bool) {
+			gap, ok := anchors.Gaps[prevID]
+			if !ok {
+				return prevID, prevHash, true
+			}
+
+			report.Gaps++
+			return gap.ThroughID, gap.ThroughHash, gap.AfterHash == prevHash
+		}
+
+		err = s.dashboardStorage.StreamChain(ctx, func(log *entity.AuditLog) error {
+			linkedID, linkedHash, ok := next()
+
+			// The chain's links are checked from the first log written after it existed on.
+			switch {
+			case log.LogID <= anchors.StartID:
+				report.Unchained++
+			default:
+				report.Checked++
+
+				if !ok || linkedID >= log.LogID || log.PrevHash != linkedHash {
+					addChainBreak(report, log, entity.ChainBrokenLink)
+				}
+
+				if log.Hash == "" || log.ChainHash() != log.Hash {
+					addChainBreak(report, log, entity.ChainHashMismatch)
+				}
+			}
+
+			// The stored hash is followed even if it doesn't match, so an altered log is reported once.
+			prevID, prevHash = log.LogID, log.Hash
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// The chain has to end at the head, or the last logs were deleted without a record.
+		if lastID, lastHash, ok := next(); !ok || lastID != anchors.HeadID || lastHash != anchors.HeadHash {
+			addChainBreak(report, &entity.AuditLog{LogID: anchors.HeadID}, entity.ChainTruncated)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"function": "VerifyLogs",
+		"checked":  report.Checked,
+		"breaks":   len(report.Breaks),
+	}).Info("audit log chain verified")
+
+	return report, nil
+}
+
+func addChainBreak(report *entity.ChainReport, log *entity.AuditLog, reason string) {
+	if len(report.Breaks) == maxChainBreaks {
+		report.Truncated = true
+		return
+	}
+
+	report.Breaks = append(report.Breaks, entity.ChainBreak{LogID: log.LogID, ActionType: log.ActionType, Reason: reason})
 }
@@ -0,0 +1,316 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuditChain returns n chained logs alternating between sign-ins and uploads. The first unchained ones are
+// written before the chain existed and have no hash.
+func testAuditChain(n, unchained int) []entity.AuditLog {
+	logs := make([]entity.AuditLog, n)
+	actionTypes := []string{entity.SignIn, entity.Upload}
+	timestamp := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var prevHash string
+
+	for i := range logs {
+		log := entity.AuditLog{
+			LogID:      i + 1,
+			UserID:     testOwnerID,
+			ActionType: actionTypes[i%2],
+			Timestamp:  timestamp.Add(time.Duration(i) * time.Minute),
+		}
+
+		if i >= unchained {
+			log.PrevHash = prevHash
+			log.Hash = log.ChainHash()
+		}
+
+		logs[i] = log
+		prevHash = log.Hash
+	}
+
+	return logs
+}
+
+// without returns the logs but the ones with the given IDs.
+func without(logs []entity.AuditLog, ids ...int) []entity.AuditLog {
+	deleted := make(map[int]bool)
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	var remaining []entity.AuditLog
+	for _, log := range logs {
+		if !deleted[log.LogID] {
+			remaining = append(remaining, log)
+		}
+	}
+
+	return remaining
+}
+
+// gap records the deletion of the logs after afterID up to and including throughID.
+func gap(logs []entity.AuditLog, afterID, throughID int) entity.ChainGap {
+	g := entity.ChainGap{AfterID: afterID, ThroughID: throughID, ThroughHash: logs[throughID-1].Hash, Deleted: int64(throughID - afterID)}
+	if afterID > 0 {
+		g.AfterHash = logs[afterID-1].Hash
+	}
+
+	return g
+}
+
+func TestDashboardServiceVerifyLogs(t *testing.T) {
+	chain := testAuditChain(6, 0)
+	head := func(anchors entity.ChainAnchors) *entity.ChainAnchors {
+		anchors.HeadID, anchors.HeadHash = 6, chain[5].Hash
+		return &anchors
+	}
+
+	altered := testAuditChain(6, 0)
+	altered[2].TargetID = "someone else"
+
+	legacy := testAuditChain(6, 2)
+
+	tests := []struct {
+		name      string
+		logs      []entity.AuditLog
+		anchors   *entity.ChainAnchors
+		breaks    []entity.ChainBreak
+		checked   int64
+		unchained int64
+		gaps      int64
+	}{
+		{
+			name:    "intact",
+			logs:    chain,
+			anchors: head(entity.ChainAnchors{}),
+			checked: 6,
+		},
+		{
+			name:    "altered log",
+			logs:    altered,
+			anchors: head(entity.ChainAnchors{}),
+			breaks:  []entity.ChainBreak{{LogID: 3, ActionType: entity.SignIn, Reason: entity.ChainHashMismatch}},
+			checked: 6,
+		},
+		{
+			name:    "log deleted without a record",
+			logs:    without(chain, 3),
+			anchors: head(entity.ChainAnchors{}),
+			breaks:  []entity.ChainBreak{{LogID: 4, ActionType: entity.Upload, Reason: entity.ChainBrokenLink}},
+			checked: 5,
+		},
+		{
+			name:    "every log of a type deleted without a record",
+			logs:    without(chain, 1, 3, 5),
+			anchors: head(entity.ChainAnchors{}),
+			breaks: []entity.ChainBreak{
+				{LogID: 2, ActionType: entity.Upload, Reason: entity.ChainBrokenLink},
+				{LogID: 4, ActionType: entity.Upload, Reason: entity.ChainBrokenLink},
+				{LogID: 6, ActionType: entity.Upload, Reason: entity.ChainBrokenLink},
+			},
+			checked: 3,
+		},
+		{
+			name: "every log of a type deleted with gaps recorded",
+			logs: without(chain, 1, 3, 5),
+			anchors: head(entity.ChainAnchors{Gaps: map[int]entity.ChainGap{
+				0: gap(chain, 0, 1),
+				2: gap(chain, 2, 3),
+				4: gap(chain, 4, 5),
+			}}),
+			checked: 3,
+			gaps:    3,
+		},
+		{
+			name:    "tail deleted without a record",
+			logs:    without(chain, 5, 6),
+			anchors: head(entity.ChainAnchors{}),
+			breaks:  []entity.ChainBreak{{LogID: 6, Reason: entity.ChainTruncated}},
+			checked: 4,
+		},
+		{
+			name:    "tail deleted with a gap recorded",
+			logs:    without(chain, 5, 6),
+			anchors: head(entity.ChainAnchors{Gaps: map[int]entity.ChainGap{4: gap(chain, 4, 6)}}),
+			checked: 4,
+			gaps:    1,
+		},
+		{
+			name:    "everything deleted with a gap recorded",
+			anchors: head(entity.ChainAnchors{Gaps: map[int]entity.ChainGap{0: gap(chain, 0, 6)}}),
+			gaps:    1,
+		},
+		{
+			name:    "everything deleted without a record",
+			anchors: head(entity.ChainAnchors{}),
+			breaks:  []entity.ChainBreak{{LogID: 6, Reason: entity.ChainTruncated}},
+		},
+		{
+			name: "gap not following the log before it",
+			logs: without(chain, 3),
+			anchors: head(entity.ChainAnchors{Gaps: map[int]entity.ChainGap{
+				2: {AfterID: 2, AfterHash: chain[0].Hash, ThroughID: 3, ThroughHash: chain[2].Hash, Deleted: 1},
+			}}),
+			breaks:  []entity.ChainBreak{{LogID: 4, ActionType: entity.Upload, Reason: entity.ChainBrokenLink}},
+			checked: 5,
+			gaps:    1,
+		},
+		{
+			name:      "logs written before the chain",
+			logs:      legacy,
+			anchors:   &entity.ChainAnchors{StartID: 2, HeadID: 6, HeadHash: legacy[5].Hash},
+			checked:   4,
+			unchained: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewDashboardStorage(t)
+			txManager := mocks.NewTxManager(t)
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.PanicLevel)
+
+			s := service.NewDashboardService(storage, txManager, logger)
+
+			txManager.On("WithinSnapshot", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+				return fn(ctx)
+			})
+			storage.On("ChainAnchors", mock.Anything).Return(tt.anchors, nil)
+			storage.On("StreamChain", mock.Anything, mock.Anything).Return(func(_ context.Context, fn func(log *entity.AuditLog) error) error {
+				for _, log := range tt.logs {
+					if err := fn(&log); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+
+			report, err := s.VerifyLogs(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.breaks, report.Breaks)
+			assert.Equal(t, tt.checked, report.Checked)
+			assert.Equal(t, tt.unchained, report.Unchained)
+			assert.Equal(t, tt.gaps, report.Gaps)
+		})
+	}
+}
+
+// testTxKey marks the context passed into a transaction by withinTestTx.
+type testTxKey struct{}
+
+// withinTestTx makes txManager run the functions passed to WithinTx with a context marking the transaction.
+func withinTestTx(txManager *mocks.TxManager) {
+	txManager.On("WithinTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(context.WithValue(ctx, testTxKey{}, true))
+	})
+}
+
+func inTestTx(ctx context.Context) bool {
+	inTx, _ := ctx.Value(testTxKey{}).(bool)
+	return inTx
+}
+
+// deletionLogged matches the log of a deletion of audit logs by the admin of testRequestInfo, written in the
+// transaction.
+func deletionLogged(ctx context.Context, log *entity.AuditLog) bool {
+	return inTestTx(ctx) &&
+		log.ActionType == entity.DeleteAuditLogs &&
+		log.UserID == testRequestInfo.UserID &&
+		log.IP == testRequestInfo.IP &&
+		log.UserAgent == testRequestInfo.UserAgent &&
+		!log.Timestamp.IsZero()
+}
+
+var testRequestInfo = entity.RequestInfo{UserID: "admin", IP: "203.0.113.7", UserAgent: "test"}
+
+func TestDashboardServiceDeleteLog(t *testing.T) {
+	storage := mocks.NewDashboardStorage(t)
+	txManager := mocks.NewTxManager(t)
+	s := service.NewDashboardService(storage, txManager, logrus.New())
+
+	withinTestTx(txManager)
+	storage.On("DeleteLog", mock.MatchedBy(inTestTx), int64(7)).Return(nil)
+	storage.On("CreateLog", mock.Anything, mock.MatchedBy(func(log *entity.AuditLog) bool {
+		return log.TargetID == "7"
+	})).Run(func(args mock.Arguments) {
+		assert.True(t, deletionLogged(args.Get(0).(context.Context), args.Get(1).(*entity.AuditLog)))
+	}).Return(nil)
+
+	require.NoError(t, s.DeleteLog(service.WithRequestInfo(context.Background(), testRequestInfo), 7))
+}
+
+func TestDashboardServiceDeleteLogNotFound(t *testing.T) {
+	storage := mocks.NewDashboardStorage(t)
+	txManager := mocks.NewTxManager(t)
+	s := service.NewDashboardService(storage, txManager, logrus.New())
+
+	withinTestTx(txManager)
+	// Nothing was deleted, so nothing is logged.
+	storage.On("DeleteLog", mock.Anything, int64(7)).Return(psqldb.ErrLogNotFound)
+
+	assert.ErrorIs(t, s.DeleteLog(context.Background(), 7), psqldb.ErrLogNotFound)
+}
+
+func TestDashboardServiceDeleteLogs(t *testing.T) {
+	query := entity.AuditLogQuery{ActionTypes: []string{entity.SignIn}}
+
+	storage := mocks.NewDashboardStorage(t)
+	txManager := mocks.NewTxManager(t)
+	s := service.NewDashboardService(storage, txManager, logrus.New())
+
+	withinTestTx(txManager)
+	storage.On("DeleteLogs", mock.MatchedBy(inTestTx), query).Return(int64(3), nil)
+	storage.On("CreateLog", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		log := args.Get(1).(*entity.AuditLog)
+		assert.True(t, deletionLogged(args.Get(0).(context.Context), log))
+
+		var data struct {
+			ActionTypes []string `json:"action_types"`
+			Deleted     int64    `json:"deleted"`
+		}
+		require.NoError(t, json.Unmarshal(log.NewData, &data))
+		assert.Equal(t, query.ActionTypes, data.ActionTypes)
+		assert.Equal(t, int64(3), data.Deleted)
+	}).Return(nil)
+
+	deleted, err := s.DeleteLogs(service.WithRequestInfo(context.Background(), testRequestInfo), query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+}
+
+func TestDashboardServiceDeleteLogsFailsWithoutLog(t *testing.T) {
+	query := entity.AuditLogQuery{ActionTypes: []string{entity.SignIn}}
+	errWrite := errors.New("write failed")
+
+	storage := mocks.NewDashboardStorage(t)
+	txManager := mocks.NewTxManager(t)
+	s := service.NewDashboardService(storage, txManager, logrus.New())
+
+	withinTestTx(txManager)
+	storage.On("DeleteLogs", mock.Anything, query).Return(int64(3), nil)
+	// The deletion isn't committed if its log can't be written.
+	storage.On("CreateLog", mock.Anything, mock.Anything).Return(errWrite)
+
+	deleted, err := s.DeleteLogs(context.Background(), query)
+	assert.ErrorIs(t, err, errWrite)
+	assert.Zero(t, deleted)
+}
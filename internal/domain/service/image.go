@@ -3,22 +3,42 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"image"
 	"image/jpeg"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nfnt/resize"
 	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/metrics"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	stepOptimization = 3
+
+	variantURLExpiry = 15 * time.Minute
+
+	deleteByUserBatchSize = 100
+)
+
+var (
+	ErrInvalidCursor     = errs.New(errs.Validation, "invalid cursor")
+	ErrImageNotFound     = errs.New(errs.NotFound, "image not found")
+	ErrImageAccessDenied = errs.New(errs.Forbidden, "access to the image is denied")
 )
 
 // Images is the interface that defines methods for interacting with image-related operations.
@@ -29,44 +49,133 @@ type Images interface {
 	// It returns the generated identifier and an error if the upload fails.
 	Upload(ctx context.Context, image image.Image, userId string) error
 
-	// GetAll retrieves all images associated with the specified identifier.
-	// It returns a slice of Image entities and an error if the retrieval fails.
-	GetAll(ctx context.Context, id string) ([]entity.Image, error)
+	// List returns a page of metadata of the user's images matching the given params.
+	// Every variant of the returned images carries a temporary download URL.
+	// It returns ErrInvalidCursor if params.Cursor wasn't issued by a previous List call.
+	List(ctx context.Context, params entity.ImageListParams) (*entity.ImagePage, error)
+
+	// Get returns the metadata of the image with the given ID.
+	// Every variant of the returned image carries a temporary download URL.
+	// It returns ErrImageNotFound if there is no such image and ErrImageAccessDenied if the actor may not access it.
+	Get(ctx context.Context, actor entity.Actor, id string) (*entity.ImageMeta, error)
+
+	// GetVariant returns the content of the variant with the given size in percent of the image with the given ID.
+	// It returns ErrImageNotFound if either the image or the variant doesn't exist
+	// and ErrImageAccessDenied if the actor may not access it.
+	GetVariant(ctx context.Context, actor entity.Actor, id string, size int) (*entity.Image, error)
+
+	// Update changes the editable metadata of the image with the given ID and returns the updated metadata.
+	// It returns ErrImageNotFound if there is no such image and ErrImageAccessDenied if the actor may not access it.
+	Update(ctx context.Context, actor entity.Actor, id string, update entity.ImageUpdate) (*entity.ImageMeta, error)
 
-	// GetBySize retrieves an image of the specified size associated with the given identifier.
-	// It returns the requested Image entity and an error if the retrieval fails.
-	GetBySize(ctx context.Context, id string, size int) (*entity.Image, error)
+	// Delete removes every variant of the image with the given ID together with its metadata.
+	// It returns ErrImageNotFound if there is no such image and ErrImageAccessDenied if the actor may not access it.
+	Delete(ctx context.Context, actor entity.Actor, id string) error
 
-	// DeleteAllImages deletes all versions of images associated with the specified identifier.
-	// It removes images with different qualities (e.g., different compression levels) to clean up storage.
-	// The method takes a context and an identifier as input and returns an error if the deletion fails.
-	DeleteAllImages(ctx context.Context, id string) error
+	// GetBySize works like GetVariant but addresses the image by the storage name of any of its variants.
+	// It's kept for the v1 routes.
+	GetBySize(ctx context.Context, actor entity.Actor, name string, size int) (*entity.Image, error)
+
+	// DeleteAllImages works like Delete but addresses the image by the storage name of any of its variants.
+	// It's kept for the v1 routes.
+	DeleteAllImages(ctx context.Context, actor entity.Actor, name string) error
+
+	// DeleteByUser deletes every image of the user with the given ID like Delete does and returns how many
+	// were deleted. It returns ErrImageAccessDenied if the actor may not manage the images of other users.
+	DeleteByUser(ctx context.Context, actor entity.Actor, userID string) (int, error)
+
+	// Reprocess renders the variants of the image with the given ID again from its original and replaces the stored ones.
+	// It returns ErrImageNotFound if either the image or its original doesn't exist.
+	Reprocess(ctx context.Context, id string) error
+
+	// CollectGarbage removes stored objects older than olderThan which don't belong to any image, e.g. leftovers
+	// of failed uploads, and returns their keys. Nothing is removed if dryRun is set.
+	CollectGarbage(ctx context.Context, olderThan time.Duration, dryRun bool) ([]string, error)
 }
 
 type ImageService struct {
-	storage miniodb.ImageStorage
-	logger  *logrus.Logger
+	storage   miniodb.ImageStorage
+	metadata  psqldb.ImageStorage
+	usage     psqldb.UsageStorage
+	txManager psqldb.TxManager
+	auditor   Auditor
+	logger    *logrus.Logger
+	metrics   *metrics.Metrics
 }
 
-func NewImageService(storage miniodb.ImageStorage, logger *logrus.Logger) *ImageService {
+func NewImageService(storage miniodb.ImageStorage, metadata psqldb.ImageStorage, usage psqldb.UsageStorage, txManager psqldb.TxManager, auditor Auditor, logger *logrus.Logger, metrics *metrics.Metrics) *ImageService {
 	return &ImageService{
-		storage: storage,
-		logger:  logger,
+		storage:   storage,
+		metadata:  metadata,
+		usage:     usage,
+		txManager: txManager,
+		auditor:   auditor,
+		logger:    logger,
+		metrics:   metrics,
 	}
 }
 
-func (s *ImageService) Upload(ctx context.Context, reqImage image.Image, userId string) error {
-	imagesRendered, quality, err := ImageQuality(reqImage)
+func (s *ImageService) Upload(ctx context.Context, reqImage image.Image, userId string) (err error) {
+	ctx, span := tracer.Start(ctx, "ImageService.Upload", trace.WithAttributes(attribute.String("user.id", userId)))
+	defer tracing.End(span, &err)
+
+	generatedId := uuid.NewString()
+
+	variants, err := s.storeVariants(ctx, reqImage, generatedId, userId)
 	if err != nil {
-		s.logger.WithError(err).Error("failed to calculate image quality")
 		return err
 	}
 
-	generatedId := uuid.NewString()
+	meta := &entity.ImageMeta{
+		ID:        generatedId,
+		UserID:    userId,
+		Format:    entity.FormatJPEG,
+		Width:     reqImage.Bounds().Dx(),
+		Height:    reqImage.Bounds().Dy(),
+		Variants:  variants,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	createStart := time.Now()
+	err = s.metadata.Create(ctx, meta)
+	s.observeStorage(metrics.DriverPostgres, "create", createStart)
+
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("failed to save image metadata")
+		return err
+	}
+
+	if err := s.usage.RecordUpload(ctx, userId, meta.Bytes(), entity.MonthStart(meta.CreatedAt)); err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("failed to record upload usage")
+		return err
+	}
+
+	logging.FromContext(ctx, s.logger).Info("image upload completed successfully")
+	return nil
+}
+
+// storeVariants renders every variant of img and uploads them under the keys of the image with the given ID.
+func (s *ImageService) storeVariants(ctx context.Context, img image.Image, id, userId string) ([]entity.ImageVariant, error) {
+	imagesRendered, quality, err := ImageQuality(img, func(size int) func() {
+		variantName := entity.VariantName(size)
+
+		_, resizeSpan := tracer.Start(ctx, "ImageService.resize", trace.WithAttributes(attribute.String("image.variant", variantName)))
+		start := time.Now()
+
+		return func() {
+			s.metrics.ObserveJobStage(metrics.StageResize, variantName, time.Since(start))
+			resizeSpan.End()
+		}
+	})
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("failed to calculate image quality")
+		return nil, err
+	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errCh := make(chan error, len(imagesRendered))
+	variants := make([]entity.ImageVariant, len(imagesRendered))
 
 	for i, v := range imagesRendered {
 		wg.Add(1)
@@ -74,17 +183,25 @@ func (s *ImageService) Upload(ctx context.Context, reqImage image.Image, userId
 		go func(i int, v image.Image) {
 			defer wg.Done()
 
+			variantName := entity.VariantName(quality[i])
+
+			_, encodeSpan := tracer.Start(ctx, "ImageService.encode", trace.WithAttributes(attribute.String("image.variant", variantName)))
+			encodeStart := time.Now()
 			buf := new(bytes.Buffer)
-			if err := jpeg.Encode(buf, v, nil); err != nil {
+			err := jpeg.Encode(buf, v, nil)
+			tracing.End(encodeSpan, &err)
+
+			if err != nil {
 				mu.Lock()
 				errCh <- fmt.Errorf("failed to encode image")
 				mu.Unlock()
 				return
 			}
+			s.metrics.ObserveJobStage(metrics.StageEncode, variantName, time.Since(encodeStart))
 
 			reader := bytes.NewReader(buf.Bytes())
 
-			idFormatted := fmt.Sprintf("%s_%d_%s.jpeg", generatedId, quality[i], userId)
+			idFormatted := fmt.Sprintf("%s_%d_%s.jpeg", id, quality[i], userId)
 
 			resImage := entity.Image{
 				Name:   idFormatted,
@@ -92,11 +209,25 @@ func (s *ImageService) Upload(ctx context.Context, reqImage image.Image, userId
 				Reader: reader,
 			}
 
-			if uploadErr := s.storage.Upload(ctx, resImage); uploadErr != nil {
+			storeStart := time.Now()
+			uploadErr := s.storage.Upload(ctx, resImage)
+			s.observeStorage(metrics.DriverMinio, "upload", storeStart)
+			s.metrics.ObserveJobStage(metrics.StageStore, variantName, time.Since(storeStart))
+
+			if uploadErr != nil {
 				mu.Lock()
 				errCh <- fmt.Errorf("upload error: %s", uploadErr)
 				mu.Unlock()
-				s.logger.WithError(uploadErr).Error("failed to upload image")
+				logging.FromContext(ctx, s.logger).WithError(uploadErr).Error("failed to upload image")
+				return
+			}
+
+			variants[i] = entity.ImageVariant{
+				Size:   quality[i],
+				Key:    idFormatted,
+				Width:  v.Bounds().Dx(),
+				Height: v.Bounds().Dy(),
+				Bytes:  resImage.Size,
 			}
 		}(i, v)
 	}
@@ -108,58 +239,440 @@ func (s *ImageService) Upload(ctx context.Context, reqImage image.Image, userId
 
 	for err := range errCh {
 		if err != nil {
-			s.logger.WithError(err).Error("error encountered during image processing")
+			logging.FromContext(ctx, s.logger).WithError(err).Error("error encountered during image processing")
+			return nil, err
+		}
+	}
+
+	return variants, nil
+}
+
+func (s *ImageService) List(ctx context.Context, params entity.ImageListParams) (*entity.ImagePage, error) {
+	filter := entity.ImageFilter{
+		UserID:       params.UserID,
+		Limit:        params.Limit + 1,
+		Order:        params.Order,
+		Format:       params.Format,
+		CreatedAfter: params.CreatedAfter,
+	}
+
+	if params.Cursor != "" {
+		createdAt, id, err := decodeCursor(params.Cursor)
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("List: failed to decode cursor")
+			return nil, ErrInvalidCursor
+		}
+
+		filter.AfterCreatedAt = createdAt
+		filter.AfterID = id
+	}
+
+	listStart := time.Now()
+	images, err := s.metadata.List(ctx, filter)
+	s.observeStorage(metrics.DriverPostgres, "list", listStart)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("List: failed to list images")
+		return nil, err
+	}
+
+	page := &entity.ImagePage{}
+
+	if len(images) > params.Limit {
+		images = images[:params.Limit]
+
+		last := images[len(images)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	for i := range images {
+		if err := s.signVariants(ctx, &images[i]); err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("List: failed to presign variant URL")
+			return nil, err
+		}
+	}
+
+	page.Images = images
+
+	return page, nil
+}
+
+func (s *ImageService) Get(ctx context.Context, actor entity.Actor, id string) (*entity.ImageMeta, error) {
+	meta, err := s.authorize(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.signVariants(ctx, meta); err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Get: failed to presign variant URL")
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func (s *ImageService) GetVariant(ctx context.Context, actor entity.Actor, id string, size int) (*entity.Image, error) {
+	meta, err := s.authorize(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	variant, ok := meta.Variant(size)
+	if !ok {
+		return nil, fmt.Errorf("%w: no variant of size %d", ErrImageNotFound, size)
+	}
+
+	getStart := time.Now()
+	img, err := s.storage.Get(ctx, variant.Key)
+	s.observeStorage(metrics.DriverMinio, "get", getStart)
+	if err != nil {
+		if errors.Is(err, miniodb.ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+
+		logging.FromContext(ctx, s.logger).WithError(err).Error("GetVariant: failed to get variant")
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func (s *ImageService) Update(ctx context.Context, actor entity.Actor, id string, update entity.ImageUpdate) (*entity.ImageMeta, error) {
+	if _, err := s.authorize(ctx, actor, id); err != nil {
+		return nil, err
+	}
+
+	updateStart := time.Now()
+	err := s.metadata.Update(ctx, id, update)
+	s.observeStorage(metrics.DriverPostgres, "update", updateStart)
+
+	if err != nil {
+		if errors.Is(err, psqldb.ErrImageNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Update: failed to update image")
+		return nil, err
+	}
+
+	return s.Get(ctx, actor, id)
+}
+
+func (s *ImageService) Delete(ctx context.Context, actor entity.Actor, id string) error {
+	meta, err := s.authorize(ctx, actor, id)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range meta.Variants {
+		deleteStart := time.Now()
+		err := s.storage.DeleteAllImages(ctx, v.Key)
+		s.observeStorage(metrics.DriverMinio, "delete", deleteStart)
+
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Delete: failed to delete variant")
+			return err
+		}
+	}
+
+	// The metadata and the stored bytes change together, a failure leaves both as they were.
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		deleteStart := time.Now()
+		err := s.metadata.Delete(ctx, id)
+		s.observeStorage(metrics.DriverPostgres, "delete", deleteStart)
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.usage.AddBytes(ctx, meta.UserID, -meta.Bytes()); err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Delete: failed to release stored bytes")
 			return err
 		}
+
+		return nil
+	})
+	if err != nil {
+		// A concurrent deletion got there first and released the bytes itself.
+		if errors.Is(err, psqldb.ErrImageNotFound) {
+			return nil
+		}
+
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Delete: failed to delete image metadata")
+		return err
 	}
 
-	s.logger.Info("image upload completed successfully")
+	s.auditor.Record(ctx, entity.AuditLog{
+		UserID:     actor.UserID,
+		ActionType: entity.Delete,
+		TargetID:   meta.ID,
+		OldData: auditJSON(map[string]interface{}{
+			"id":      meta.ID,
+			"user_id": meta.UserID,
+			"title":   meta.Title,
+			"format":  meta.Format,
+			"width":   meta.Width,
+			"height":  meta.Height,
+			"bytes":   meta.Bytes(),
+		}),
+	})
+
 	return nil
 }
 
-func (s *ImageService) GetAll(ctx context.Context, id string) ([]entity.Image, error) {
-	return s.storage.GetAll(ctx, id)
+func (s *ImageService) GetBySize(ctx context.Context, actor entity.Actor, name string, size int) (*entity.Image, error) {
+	return s.GetVariant(ctx, actor, imageIDFromName(name), size)
 }
 
-func (s *ImageService) GetBySize(ctx context.Context, id string, size int) (*entity.Image, error) {
-	return s.storage.GetBySize(ctx, id, size)
+func (s *ImageService) DeleteAllImages(ctx context.Context, actor entity.Actor, name string) error {
+	return s.Delete(ctx, actor, imageIDFromName(name))
 }
 
-func (s *ImageService) DeleteAllImages(ctx context.Context, id string) error {
-	quality := []string{"100", "75", "50", "25"}
+func (s *ImageService) DeleteByUser(ctx context.Context, actor entity.Actor, userID string) (int, error) {
+	if actor.UserID != userID && !actor.HasPermission(entity.PermissionManageAnyImage) {
+		return 0, ErrImageAccessDenied
+	}
+
+	var deleted int
+
+	// Deleted images drop out of the listing, so the first page is fetched until it comes back empty.
+	for {
+		images, err := s.metadata.List(ctx, entity.ImageFilter{UserID: userID, Limit: deleteByUserBatchSize, Order: entity.OrderAsc})
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("DeleteByUser: failed to list images")
+			return deleted, err
+		}
+
+		if len(images) == 0 {
+			return deleted, nil
+		}
+
+		for _, image := range images {
+			if err := s.Delete(ctx, actor, image.ID); err != nil {
+				return deleted, err
+			}
+
+			deleted++
+		}
+	}
+}
+
+func (s *ImageService) Reprocess(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "ImageService.Reprocess", trace.WithAttributes(attribute.String("image.id", id)))
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx, s.logger).WithField("image_id", id)
+
+	meta, err := s.metadata.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, psqldb.ErrImageNotFound) {
+			return fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+
+		logger.WithError(err).Error("Reprocess: failed to get image metadata")
+		return err
+	}
+
+	original, ok := meta.Variant(100)
+	if !ok {
+		return fmt.Errorf("%w: %s has no original", ErrImageNotFound, id)
+	}
+
+	object, err := s.storage.Get(ctx, original.Key)
+	if err != nil {
+		if errors.Is(err, miniodb.ErrObjectNotFound) {
+			return fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+
+		logger.WithError(err).Error("Reprocess: failed to get original")
+		return err
+	}
 
-	uuid, userId, err := extractUUIDAndUserID(id)
+	img, _, err := image.Decode(object.Reader)
 	if err != nil {
-		s.logger.WithError(err).Error("DeleteAllImages: failed to extract UUID and userID")
+		logger.WithError(err).Error("Reprocess: failed to decode original")
 		return err
 	}
 
-	for _, v := range quality {
-		connStr := fmt.Sprintf("%s_%s_%s", uuid, v, userId)
+	variants, err := s.storeVariants(ctx, img, meta.ID, meta.UserID)
+	if err != nil {
+		return err
+	}
 
-		if err := s.storage.DeleteAllImages(ctx, connStr); err != nil {
-			s.logger.WithError(err).Error("DeleteAllImages: failed to delete images")
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.metadata.SetVariants(ctx, id, variants); err != nil {
+			logger.WithError(err).Error("Reprocess: failed to save variants")
 			return err
 		}
+
+		reprocessed := entity.ImageMeta{Variants: variants}
+		if err := s.usage.AddBytes(ctx, meta.UserID, reprocessed.Bytes()-meta.Bytes()); err != nil {
+			logger.WithError(err).Error("Reprocess: failed to update stored bytes")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	logger.Info("image reprocessed successfully")
 	return nil
 }
 
-func extractUUIDAndUserID(input string) (uuid, userID string, err error) {
-	parts := strings.Split(input, "_")
+func (s *ImageService) CollectGarbage(ctx context.Context, olderThan time.Duration, dryRun bool) ([]string, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "CollectGarbage")
 
-	if len(parts) != 3 {
-		err = fmt.Errorf("invalid input format")
-		return
+	objects, err := s.storage.ListObjects(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	uuid = parts[0]
-	userID = parts[2]
-	return
+	cutoff := time.Now().Add(-olderThan)
+	keysByImage := make(map[string]map[string]bool)
+
+	var removed []string
+
+	for _, object := range objects {
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+
+		id := imageIDFromName(object.Key)
+
+		keys, ok := keysByImage[id]
+		if !ok {
+			keys = make(map[string]bool)
+
+			meta, err := s.metadata.Get(ctx, id)
+			switch {
+			case errors.Is(err, psqldb.ErrImageNotFound):
+			case err != nil:
+				return removed, err
+			default:
+				for _, v := range meta.Variants {
+					keys[v.Key] = true
+				}
+			}
+
+			keysByImage[id] = keys
+		}
+
+		if keys[object.Key] {
+			continue
+		}
+
+		if !dryRun {
+			if err := s.storage.DeleteAllImages(ctx, object.Key); err != nil {
+				return removed, err
+			}
+		}
+
+		logger.WithField("key", object.Key).Info("collected orphaned object")
+		removed = append(removed, object.Key)
+	}
+
+	return removed, nil
+}
+
+// authorize loads the image metadata and checks that the actor owns the image
+// or is allowed to manage images of other users.
+func (s *ImageService) authorize(ctx context.Context, actor entity.Actor, id string) (*entity.ImageMeta, error) {
+	getStart := time.Now()
+	meta, err := s.metadata.Get(ctx, id)
+	s.observeStorage(metrics.DriverPostgres, "get", getStart)
+
+	if err != nil {
+		if errors.Is(err, psqldb.ErrImageNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+
+		logging.FromContext(ctx, s.logger).WithError(err).Error("failed to get image metadata")
+		return nil, err
+	}
+
+	if meta.UserID != actor.UserID && !actor.HasPermission(entity.PermissionManageAnyImage) {
+		logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+			"image_id": id,
+			"actor_id": actor.UserID,
+		}).Warn("image access denied")
+		return nil, ErrImageAccessDenied
+	}
+
+	return meta, nil
+}
+
+func (s *ImageService) signVariants(ctx context.Context, meta *entity.ImageMeta) error {
+	for i := range meta.Variants {
+		presignStart := time.Now()
+		url, err := s.storage.PresignedURL(ctx, meta.Variants[i].Key, variantURLExpiry)
+		s.observeStorage(metrics.DriverMinio, "presign", presignStart)
+
+		if err != nil {
+			return err
+		}
+
+		meta.Variants[i].URL = url
+	}
+
+	return nil
 }
 
-func ImageQuality(img image.Image) ([]image.Image, []int, error) {
+var tracer = otel.Tracer("github.com/nordew/UploadApp/internal/domain/service")
+
+func (s *ImageService) observeStorage(driver, operation string, start time.Time) {
+	s.metrics.ObserveStorageOperation(driver, operation, time.Since(start))
+}
+
+// imageIDFromName returns the image ID from a variant storage name in the "<id>_<size>_<user id>.jpeg" form.
+func imageIDFromName(name string) string {
+	id, _, _ := strings.Cut(name, "_")
+	return id
+}
+
+// encodeCursor packs the keyset position of a row into an opaque token.
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return parsed, id, nil
+}
+
+// variantSizes are the sizes in percent of the variants rendered by ImageQuality.
+var variantSizes = []int{100, 75, 50, 25}
+
+// EstimateStoredBytes estimates the bytes taken by the variants ImageQuality renders of an image whose full size
+// JPEG encoding takes jpegBytes, assuming the size of a variant grows with its area.
+func EstimateStoredBytes(jpegBytes int64) int64 {
+	var total int64
+	for _, size := range variantSizes {
+		total += jpegBytes * int64(size*size) / (100 * 100)
+	}
+
+	return total
+}
+
+// ImageQuality renders the variants of img and returns them together with their sizes in percent.
+// If observe isn't nil it's called before resizing each variant and the function it returns once the variant is done.
+func ImageQuality(img image.Image, observe func(size int) func()) ([]image.Image, []int, error) {
 	if img == nil {
 		return nil, nil, errors.New("input image is nil")
 	}
@@ -173,9 +686,9 @@ func ImageQuality(img image.Image) ([]image.Image, []int, error) {
 
 	width := []uint{photoWidth, photoWidth - (photoWidth / 4), photoWidth / 2, photoWidth / 4}
 	height := []uint{photoHeight, photoHeight - (photoHeight / 4), photoHeight / 2, photoHeight / 4}
-	quality := []int{100, 75, 50, 25}
+	quality := append([]int(nil), variantSizes...)
 
-	resizedImages, err := reSize(img, width, height)
+	resizedImages, err := reSize(img, width, height, quality, observe)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to resize images: %w", err)
 	}
@@ -183,14 +696,21 @@ func ImageQuality(img image.Image) ([]image.Image, []int, error) {
 	return resizedImages, quality, nil
 }
 
-func reSize(img image.Image, width, height []uint) ([]image.Image, error) {
+func reSize(img image.Image, width, height []uint, quality []int, observe func(size int) func()) ([]image.Image, error) {
 	if img == nil {
 		return nil, errors.New("input image is nil")
 	}
 
 	var pictures []image.Image
 	for i := 0; i <= stepOptimization; i++ {
+		done := func() {}
+		if observe != nil {
+			done = observe(quality[i])
+		}
+
 		resizedImg := resize.Resize(width[i], height[i], img, resize.Lanczos3)
+		done()
+
 		if resizedImg == nil {
 			return nil, fmt.Errorf("failed to resize image at index %d", i)
 		}
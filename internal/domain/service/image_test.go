@@ -0,0 +1,183 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testImageID    = "9b2f6c84-5e0a-4c4b-8d0e-0f3c2a1b7d61"
+	testOwnerID    = "owner"
+	testVariantKey = testImageID + "_100_" + testOwnerID + ".jpeg"
+)
+
+var (
+	ownerActor = entity.Actor{UserID: testOwnerID, Role: entity.RoleUser}
+	otherActor = entity.Actor{UserID: "other", Role: entity.RoleUser}
+	adminActor = entity.Actor{UserID: "admin", Role: entity.RoleAdmin}
+)
+
+type imageServiceMocks struct {
+	storage   *mocks.ImageStorage
+	metadata  *mocks.ImageMetadataStorage
+	usage     *mocks.UsageStorage
+	txManager *mocks.TxManager
+	auditor   *mocks.Auditor
+}
+
+func newTestImageService(t *testing.T) (*service.ImageService, imageServiceMocks) {
+	m := imageServiceMocks{
+		storage:   mocks.NewImageStorage(t),
+		metadata:  mocks.NewImageMetadataStorage(t),
+		usage:     mocks.NewUsageStorage(t),
+		txManager: mocks.NewTxManager(t),
+		auditor:   mocks.NewAuditor(t),
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return service.NewImageService(m.storage, m.metadata, m.usage, m.txManager, m.auditor, logger, nil), m
+}
+
+func testImageMeta() *entity.ImageMeta {
+	return &entity.ImageMeta{
+		ID:       testImageID,
+		UserID:   testOwnerID,
+		Format:   entity.FormatJPEG,
+		Variants: []entity.ImageVariant{{Size: 100, Key: testVariantKey, Bytes: 1024}},
+	}
+}
+
+func TestImageServiceAccess(t *testing.T) {
+	operations := []struct {
+		name string
+		// expect sets up the calls an allowed operation makes after the ownership check.
+		expect func(m imageServiceMocks)
+		call   func(s *service.ImageService, actor entity.Actor) error
+	}{
+		{
+			name: "Get",
+			expect: func(m imageServiceMocks) {
+				m.storage.On("PresignedURL", mock.Anything, testVariantKey, mock.Anything).Return("https://example.com/v", nil)
+			},
+			call: func(s *service.ImageService, actor entity.Actor) error {
+				_, err := s.Get(context.Background(), actor, testImageID)
+				return err
+			},
+		},
+		{
+			name: "GetVariant",
+			expect: func(m imageServiceMocks) {
+				m.storage.On("Get", mock.Anything, testVariantKey).Return(&entity.Image{Name: testVariantKey}, nil)
+			},
+			call: func(s *service.ImageService, actor entity.Actor) error {
+				_, err := s.GetVariant(context.Background(), actor, testImageID, 100)
+				return err
+			},
+		},
+		{
+			name: "Update",
+			expect: func(m imageServiceMocks) {
+				m.metadata.On("Update", mock.Anything, testImageID, mock.Anything).Return(nil)
+				m.storage.On("PresignedURL", mock.Anything, testVariantKey, mock.Anything).Return("https://example.com/v", nil)
+			},
+			call: func(s *service.ImageService, actor entity.Actor) error {
+				title := "renamed"
+				_, err := s.Update(context.Background(), actor, testImageID, entity.ImageUpdate{Title: &title})
+				return err
+			},
+		},
+		{
+			name:   "Delete",
+			expect: expectImageDeleted,
+			call: func(s *service.ImageService, actor entity.Actor) error {
+				return s.Delete(context.Background(), actor, testImageID)
+			},
+		},
+		{
+			name:   "DeleteAllImages",
+			expect: expectImageDeleted,
+			call: func(s *service.ImageService, actor entity.Actor) error {
+				return s.DeleteAllImages(context.Background(), actor, testVariantKey)
+			},
+		},
+	}
+
+	actors := []struct {
+		name    string
+		actor   entity.Actor
+		allowed bool
+	}{
+		{name: "owner", actor: ownerActor, allowed: true},
+		{name: "other user", actor: otherActor, allowed: false},
+		{name: "admin", actor: adminActor, allowed: true},
+	}
+
+	for _, op := range operations {
+		for _, a := range actors {
+			t.Run(op.name+"/"+a.name, func(t *testing.T) {
+				s, m := newTestImageService(t)
+
+				m.metadata.On("Get", mock.Anything, testImageID).Return(testImageMeta(), nil)
+
+				// Denied operations mustn't touch anything past the ownership check, the mocks fail on
+				// unexpected calls.
+				if a.allowed {
+					op.expect(m)
+				}
+
+				err := op.call(s, a.actor)
+
+				if a.allowed {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, service.ErrImageAccessDenied)
+				}
+			})
+		}
+	}
+}
+
+func expectImageDeleted(m imageServiceMocks) {
+	m.storage.On("DeleteAllImages", mock.Anything, testVariantKey).Return(nil)
+	// The metadata and the stored bytes change in one transaction.
+	withinTestTx(m.txManager)
+	m.metadata.On("Delete", mock.MatchedBy(inTestTx), testImageID).Return(nil)
+	m.usage.On("AddBytes", mock.MatchedBy(inTestTx), testOwnerID, int64(-1024)).Return(nil)
+	m.auditor.On("Record", mock.Anything, mock.MatchedBy(func(log entity.AuditLog) bool {
+		return log.ActionType == entity.Delete && log.TargetID == testImageID
+	})).Return()
+}
+
+func TestImageServiceAccessUnknownImage(t *testing.T) {
+	s, m := newTestImageService(t)
+
+	m.metadata.On("Get", mock.Anything, testImageID).Return(nil, psqldb.ErrImageNotFound)
+
+	_, err := s.Get(context.Background(), adminActor, testImageID)
+	assert.ErrorIs(t, err, service.ErrImageNotFound)
+}
+
+func TestImageServiceDeleteUsageFailure(t *testing.T) {
+	s, m := newTestImageService(t)
+
+	m.metadata.On("Get", mock.Anything, testImageID).Return(testImageMeta(), nil)
+	m.storage.On("DeleteAllImages", mock.Anything, testVariantKey).Return(nil)
+
+	// The failed update of the stored bytes fails the transaction deleting the metadata, nothing is audited.
+	withinTestTx(m.txManager)
+	m.metadata.On("Delete", mock.MatchedBy(inTestTx), testImageID).Return(nil)
+	m.usage.On("AddBytes", mock.MatchedBy(inTestTx), testOwnerID, int64(-1024)).Return(assert.AnError)
+
+	err := s.Delete(context.Background(), ownerActor, testImageID)
+	assert.ErrorIs(t, err, assert.AnError)
+}
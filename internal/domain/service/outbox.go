@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+)
+
+// Outbox stages queue messages in the database so they are published if and only if
+// the transaction which produced them commits.
+type Outbox interface {
+	// Enqueue stores a message for the relay to publish to queue and returns its idempotency key.
+	Enqueue(ctx context.Context, queue string, headers map[string]interface{}, body []byte) (string, error)
+
+	// Pending returns at most limit messages waiting to be published.
+	Pending(ctx context.Context, limit int) ([]entity.OutboxMessage, error)
+
+	MarkSent(ctx context.Context, id string) error
+
+	MarkFailed(ctx context.Context, id string, reason string) error
+
+	// MarkProcessed records that the message with the given idempotency key was processed and
+	// returns false if it already was, meaning the delivery is a duplicate.
+	MarkProcessed(ctx context.Context, key string) (bool, error)
+
+	// Prune forgets sent messages and idempotency keys older than olderThan and returns how many rows were deleted.
+	Prune(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type outboxService struct {
+	outboxStorage psqldb.OutboxStorage
+}
+
+func NewOutboxService(outboxStorage psqldb.OutboxStorage) *outboxService {
+	return &outboxService{
+		outboxStorage: outboxStorage,
+	}
+}
+
+func (s *outboxService) Enqueue(ctx context.Context, queue string, headers map[string]interface{}, body []byte) (string, error) {
+	msg := &entity.OutboxMessage{
+		ID:      uuid.NewString(),
+		Queue:   queue,
+		Headers: headers,
+		Body:    body,
+	}
+
+	if err := s.outboxStorage.Add(ctx, msg); err != nil {
+		return "", err
+	}
+
+	return msg.ID, nil
+}
+
+func (s *outboxService) Pending(ctx context.Context, limit int) ([]entity.OutboxMessage, error) {
+	return s.outboxStorage.Pending(ctx, limit)
+}
+
+func (s *outboxService) MarkSent(ctx context.Context, id string) error {
+	return s.outboxStorage.MarkSent(ctx, id)
+}
+
+func (s *outboxService) MarkFailed(ctx context.Context, id string, reason string) error {
+	return s.outboxStorage.MarkFailed(ctx, id, reason)
+}
+
+func (s *outboxService) MarkProcessed(ctx context.Context, key string) (bool, error) {
+	return s.outboxStorage.MarkProcessed(ctx, key)
+}
+
+func (s *outboxService) Prune(ctx context.Context, olderThan time.Duration) (int64, error) {
+	before := time.Now().Add(-olderThan)
+
+	sent, err := s.outboxStorage.DeleteSentBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	processed, err := s.outboxStorage.DeleteProcessedBefore(ctx, before)
+	if err != nil {
+		return sent, err
+	}
+
+	return sent + processed, nil
+}
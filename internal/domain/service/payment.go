@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/nordew/UploadApp/pkg/payment"
+	"github.com/sirupsen/logrus"
+)
+
+// Metadata keys attached to every payment created with the provider.
+const (
+	PaymentMetadataID     = "payment_id"
+	PaymentMetadataUserID = "user_id"
+	PaymentMetadataPlanID = "plan_id"
+)
+
+var (
+	ErrPlanNotPurchasable    = errs.New(errs.Validation, "the plan can't be purchased")
+	ErrInvalidWebhook        = errs.New(errs.Validation, "invalid webhook")
+	ErrSimulationUnsupported = errs.New(errs.NotFound, "payment simulation is not available")
+	ErrPaymentCompleted      = errs.New(errs.Conflict, "payment is already completed")
+)
+
+// PaymentOptions configures the payments started with the provider.
+type PaymentOptions struct {
+	// SuccessURL and CancelURL are where checkout sessions send the client back to.
+	SuccessURL string
+	CancelURL  string
+}
+
+// Payments starts payments for plans and keeps their record.
+type Payments interface {
+	// CreateIntent starts a payment for one period of the plan the client completes with the returned client secret.
+	// It returns ErrPlanNotPurchasable for free plans.
+	CreateIntent(ctx context.Context, userID, planID string) (*entity.PaymentStart, error)
+
+	// CreateCheckout works like CreateIntent but starts a checkout session hosted by the provider
+	// and returns the URL the client is redirected to.
+	CreateCheckout(ctx context.Context, userID, planID string) (*entity.PaymentStart, error)
+
+	// History returns the payments of the user, newest first.
+	History(ctx context.Context, userID string) ([]entity.Payment, error)
+
+	// HandleWebhook verifies and applies an event posted by the provider: successful payments and paid invoices
+	// activate or extend the user's subscription, subscription events update or cancel it.
+	// Every event is applied once, redeliveries are acknowledged and ignored.
+	// It returns ErrInvalidWebhook if the payload isn't signed by the provider or can't be decoded.
+	HandleWebhook(ctx context.Context, payload []byte, signature string) error
+
+	// WebhookSignatureHeader is the HTTP header the provider sends the webhook signature in.
+	WebhookSignatureHeader() string
+
+	// Simulate completes a pending payment of the user, successfully or not, and applies the resulting event
+	// like a webhook. It's meant for local development and tests and returns ErrSimulationUnsupported
+	// unless the provider is a payment.Simulator.
+	Simulate(ctx context.Context, userID, paymentID string, succeed bool) error
+}
+
+type paymentService struct {
+	storage       psqldb.PaymentStorage
+	subscriptions Subscription
+	users         Users
+	provider      payment.Provider
+	auditor       Auditor
+	txManager     psqldb.TxManager
+	options       PaymentOptions
+	logger        *logrus.Logger
+}
+
+func NewPaymentService(storage psqldb.PaymentStorage, subscriptions Subscription, users Users, provider payment.Provider, auditor Auditor, txManager psqldb.TxManager, options PaymentOptions, logger *logrus.Logger) *paymentService {
+	return &paymentService{
+		storage:       storage,
+		subscriptions: subscriptions,
+		users:         users,
+		provider:      provider,
+		auditor:       auditor,
+		txManager:     txManager,
+		options:       options,
+		logger:        logger,
+	}
+}
+
+func (s *paymentService) CreateIntent(ctx context.Context, userID, planID string) (*entity.PaymentStart, error) {
+	return s.create(ctx, userID, planID, entity.PaymentKindIntent)
+}
+
+func (s *paymentService) CreateCheckout(ctx context.Context, userID, planID string) (*entity.PaymentStart, error) {
+	return s.create(ctx, userID, planID, entity.PaymentKindCheckout)
+}
+
+func (s *paymentService) History(ctx context.Context, userID string) ([]entity.Payment, error) {
+	return s.storage.ListByUser(ctx, userID)
+}
+
+// create starts a payment of the given kind with the provider and records it.
+// The payment ID is used as idempotency key and sent as metadata, so the provider's events can be matched with it.
+func (s *paymentService) create(ctx context.Context, userID, planID, kind string) (*entity.PaymentStart, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "create")
+
+	plan, err := s.subscriptions.Plan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.PriceCents <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPlanNotPurchasable, planID)
+	}
+
+	customerID, err := s.customer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := entity.Payment{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		PlanID:      plan.ID,
+		Kind:        kind,
+		Provider:    s.provider.Name(),
+		AmountCents: plan.PriceCents,
+		Currency:    plan.Currency,
+		Status:      entity.PaymentPending,
+	}
+
+	params := payment.IntentParams{
+		AmountCents: plan.PriceCents,
+		Currency:    plan.Currency,
+		Description: fmt.Sprintf("%s plan, 1 %s", plan.Name, plan.Interval),
+		CustomerID:  customerID,
+		Metadata: map[string]string{
+			PaymentMetadataID:     p.ID,
+			PaymentMetadataUserID: userID,
+			PaymentMetadataPlanID: plan.ID,
+		},
+		IdempotencyKey: p.ID,
+	}
+
+	start := &entity.PaymentStart{}
+
+	switch kind {
+	case entity.PaymentKindCheckout:
+		checkout, err := s.provider.CreateCheckoutSession(ctx, payment.CheckoutParams{
+			IntentParams: params,
+			ProductName:  plan.Name + " plan",
+			SuccessURL:   s.options.SuccessURL,
+			CancelURL:    s.options.CancelURL,
+		})
+		if err != nil {
+			logger.WithError(err).Error("failed to create checkout session")
+			return nil, fmt.Errorf("failed to create checkout session: %w", err)
+		}
+
+		p.ProviderID = checkout.ID
+		start.CheckoutURL = checkout.URL
+	default:
+		intent, err := s.provider.CreatePaymentIntent(ctx, params)
+		if err != nil {
+			logger.WithError(err).Error("failed to create payment intent")
+			return nil, fmt.Errorf("failed to create payment intent: %w", err)
+		}
+
+		p.ProviderID = intent.ID
+		start.ClientSecret = intent.ClientSecret
+	}
+
+	if err := s.storage.Create(ctx, &p); err != nil {
+		logger.WithError(err).Error("failed to record payment")
+		return nil, err
+	}
+
+	start.Payment = p
+
+	logger.WithFields(logrus.Fields{"payment_id": p.ID, "plan": plan.ID, "kind": kind}).Info("payment started")
+	return start, nil
+}
+
+// customer returns the ID the provider knows the user as, registering the user with the provider the first time.
+func (s *paymentService) customer(ctx context.Context, userID string) (string, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "customer")
+
+	customerID, err := s.storage.GetCustomer(ctx, userID, s.provider.Name())
+	if err == nil {
+		return customerID, nil
+	}
+
+	if !errors.Is(err, psqldb.ErrCustomerNotFound) {
+		return "", err
+	}
+
+	user, err := s.users.GetCredentials(ctx, userID, false)
+	if err != nil {
+		return "", err
+	}
+
+	// Concurrent first payments share the key and so the customer.
+	customer, err := s.provider.CreateCustomer(ctx, payment.CustomerParams{
+		Email:          user.Email,
+		Metadata:       map[string]string{PaymentMetadataUserID: userID},
+		IdempotencyKey: "customer-" + userID,
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to create customer")
+		return "", fmt.Errorf("failed to create customer: %w", err)
+	}
+
+	return s.storage.SaveCustomer(ctx, userID, s.provider.Name(), customer.ID)
+}
+
+func (s *paymentService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.provider.ParseEvent(payload, signature)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Warn("HandleWebhook: rejected event")
+		return errs.Wrap(err, errs.Validation, ErrInvalidWebhook.Message)
+	}
+
+	return s.process(ctx, event)
+}
+
+func (s *paymentService) WebhookSignatureHeader() string {
+	return s.provider.SignatureHeader()
+}
+
+func (s *paymentService) Simulate(ctx context.Context, userID, paymentID string, succeed bool) error {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Simulate")
+
+	simulator, ok := s.provider.(payment.Simulator)
+	if !ok {
+		return ErrSimulationUnsupported
+	}
+
+	p, err := s.storage.Get(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if p.UserID != userID {
+		return fmt.Errorf("%w: %s", psqldb.ErrPaymentNotFound, paymentID)
+	}
+
+	if p.Status != entity.PaymentPending {
+		return fmt.Errorf("%w: %s", ErrPaymentCompleted, paymentID)
+	}
+
+	event, err := simulator.Simulate(ctx, p.ProviderID, succeed)
+	if err != nil {
+		// The fake provider forgets its payments on restart.
+		if errors.Is(err, payment.ErrUnknownPayment) {
+			return errs.Wrap(err, errs.NotFound, psqldb.ErrPaymentNotFound.Message)
+		}
+
+		logger.WithError(err).Error("failed to simulate payment")
+		return err
+	}
+
+	return s.process(ctx, event)
+}
+
+// process applies the event once, see HandleWebhook.
+func (s *paymentService) process(ctx context.Context, event *payment.Event) error {
+	logger := logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"function":   "process",
+		"event_id":   event.ID,
+		"event_type": event.Type,
+	})
+	ctx = logging.WithLogger(ctx, logger)
+
+	var first bool
+
+	// The event is recorded in the same transaction it's applied in: a failed event is rolled back
+	// and applied again when the provider retries it.
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) (err error) {
+		first, err = s.storage.RecordEvent(ctx, event.ID, event.Type)
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			logger.Info("skipping duplicate event")
+			return nil
+		}
+
+		return s.applyEvent(ctx, event)
+	})
+	if err != nil || !first {
+		return err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		ActionType: entity.PaymentEvent,
+		TargetID:   event.Metadata[PaymentMetadataUserID],
+		NewData: auditJSON(map[string]interface{}{
+			"event_id":   event.ID,
+			"type":       event.Type,
+			"object_id":  event.ObjectID,
+			"payment_id": event.Metadata[PaymentMetadataID],
+			"plan_id":    event.Metadata[PaymentMetadataPlanID],
+			"status":     event.Status,
+		}),
+	})
+
+	return nil
+}
+
+// applyEvent updates payments and subscriptions for the event. Events which don't belong to a known
+// payment or user are logged and dropped, retrying them wouldn't help.
+func (s *paymentService) applyEvent(ctx context.Context, event *payment.Event) error {
+	logger := logging.FromContext(ctx, s.logger)
+
+	userID, planID := event.Metadata[PaymentMetadataUserID], event.Metadata[PaymentMetadataPlanID]
+
+	switch event.Type {
+	case payment.EventPaymentSucceeded, payment.EventPaymentFailed:
+		p, err := s.storage.Get(ctx, event.Metadata[PaymentMetadataID])
+		if err != nil {
+			if errors.Is(err, psqldb.ErrPaymentNotFound) {
+				logger.Warn("event of an unknown payment")
+				return nil
+			}
+
+			return err
+		}
+
+		if p.IsPaid() {
+			return nil
+		}
+
+		if event.Type == payment.EventPaymentFailed {
+			return s.storage.SetStatus(ctx, p.ID, entity.PaymentFailed)
+		}
+
+		if err := s.storage.SetStatus(ctx, p.ID, entity.PaymentSucceeded); err != nil {
+			return err
+		}
+
+		return s.activate(ctx, p.UserID, p.PlanID, time.Time{})
+	case payment.EventInvoicePaid:
+		if userID == "" || planID == "" {
+			logger.Warn("invoice without user or plan metadata")
+			return nil
+		}
+
+		return s.activate(ctx, userID, planID, event.PeriodEnd)
+	case payment.EventSubscriptionUpdated:
+		if userID == "" || planID == "" {
+			logger.Warn("subscription without user or plan metadata")
+			return nil
+		}
+
+		switch event.Status {
+		case "active", "trialing":
+			if err := s.activate(ctx, userID, planID, event.PeriodEnd); err != nil {
+				return err
+			}
+
+			if event.CancelAtPeriodEnd {
+				return s.cancel(ctx, userID, true)
+			}
+
+			return nil
+		case "canceled", "unpaid", "incomplete_expired":
+			return s.cancel(ctx, userID, false)
+		default:
+			return nil
+		}
+	case payment.EventSubscriptionDeleted:
+		if userID == "" {
+			logger.Warn("subscription without user metadata")
+			return nil
+		}
+
+		return s.cancel(ctx, userID, false)
+	default:
+		logger.Debug("ignoring event")
+		return nil
+	}
+}
+
+// activate subscribes the user to the plan until periodEnd, or for one more period of the plan if periodEnd is zero.
+// An active subscription to the same plan is extended, any other is replaced.
+func (s *paymentService) activate(ctx context.Context, userID, planID string, periodEnd time.Time) error {
+	if _, err := s.users.GetCredentials(ctx, userID, false); err != nil {
+		if errors.Is(err, psqldb.ErrUserNotFound) {
+			logging.FromContext(ctx, s.logger).Warn("event of an unknown user")
+			return nil
+		}
+
+		return err
+	}
+
+	plan, err := s.subscriptions.Plan(ctx, planID)
+	if err != nil {
+		if errors.Is(err, psqldb.ErrPlanNotFound) {
+			logging.FromContext(ctx, s.logger).Warn("event of an unknown plan")
+			return nil
+		}
+
+		return err
+	}
+
+	current, err := s.subscriptions.Current(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	samePlan := current.ID != "" && current.Plan.ID == planID
+
+	if periodEnd.IsZero() {
+		base := now
+		if samePlan && current.CurrentPeriodEnd.After(base) {
+			base = current.CurrentPeriodEnd
+		}
+
+		periodEnd = addInterval(base, plan.Interval)
+	}
+
+	if samePlan {
+		if !periodEnd.After(current.CurrentPeriodEnd) {
+			return nil
+		}
+
+		return s.subscriptions.Update(ctx, userID, periodEnd)
+	}
+
+	if !periodEnd.After(now) {
+		return nil
+	}
+
+	_, err = s.subscriptions.Create(ctx, userID, planID, now, periodEnd)
+	return err
+}
+
+func (s *paymentService) cancel(ctx context.Context, userID string, atPeriodEnd bool) error {
+	_, err := s.subscriptions.Cancel(ctx, userID, atPeriodEnd)
+	if errors.Is(err, psqldb.ErrSubscriptionNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// addInterval returns t moved forward by one billing interval.
+func addInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case "year":
+		return t.AddDate(1, 0, 0)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "day":
+		return t.AddDate(0, 0, 1)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
@@ -0,0 +1,418 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/nordew/UploadApp/pkg/payment"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+const (
+	testWebhookSecret = "whsec_test"
+	testPaymentID     = "5d1c8a7e-3f2b-4e6a-9c0d-8b7a6f5e4d3c"
+	testPayerID       = "payer"
+	testPlanID        = "pro"
+)
+
+var (
+	testPlan           = &entity.Plan{ID: testPlanID, Name: "Pro", PriceCents: 999, Currency: "usd", Interval: "month"}
+	testPaymentMeta    = map[string]interface{}{"payment_id": testPaymentID, "user_id": testPayerID, "plan_id": testPlanID}
+	testPeriodEnd      = time.Now().AddDate(0, 1, 0).Truncate(time.Second).UTC()
+	testActiveProSince = time.Now().AddDate(0, 0, -20)
+)
+
+type paymentServiceMocks struct {
+	storage       *mocks.PaymentStorage
+	subscriptions *mocks.Subscription
+	users         *mocks.Users
+	auditor       *mocks.Auditor
+	txManager     *mocks.TxManager
+}
+
+func newTestPaymentService(t *testing.T, provider payment.Provider) (service.Payments, paymentServiceMocks) {
+	m := paymentServiceMocks{
+		storage:       mocks.NewPaymentStorage(t),
+		subscriptions: mocks.NewSubscription(t),
+		users:         mocks.NewUsers(t),
+		auditor:       mocks.NewAuditor(t),
+		txManager:     mocks.NewTxManager(t),
+	}
+
+	// Transactions just run their function, the storage mocks stand in for the database.
+	m.txManager.On("WithinTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Maybe()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return service.NewPaymentService(m.storage, m.subscriptions, m.users, provider, m.auditor, m.txManager, service.PaymentOptions{
+		SuccessURL: "http://localhost/success",
+		CancelURL:  "http://localhost/cancel",
+	}, logger), m
+}
+
+// signedStripeEvent returns the payload of a Stripe event about object and its signature header.
+func signedStripeEvent(t *testing.T, id, eventType string, object map[string]interface{}) ([]byte, string) {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"object":      "event",
+		"api_version": "2023-10-16",
+		"type":        eventType,
+		"data":        map[string]interface{}{"object": object},
+	})
+	require.NoError(t, err)
+
+	return payload, webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: testWebhookSecret}).Header
+}
+
+// expectPayer sets up the lookups activating a plan for the payer makes, current is the payer's subscription.
+func expectPayer(m paymentServiceMocks, current *entity.Subscription) {
+	m.users.On("GetCredentials", mock.Anything, testPayerID, false).Return(&entity.User{ID: testPayerID}, nil)
+	m.subscriptions.On("Plan", mock.Anything, testPlanID).Return(testPlan, nil)
+	m.subscriptions.On("Current", mock.Anything, testPayerID).Return(current, nil)
+}
+
+// activePro is an active subscription of the payer to the test plan ending before testPeriodEnd.
+func activePro() *entity.Subscription {
+	return &entity.Subscription{
+		ID:                 "sub",
+		UserID:             testPayerID,
+		Plan:               *testPlan,
+		Status:             entity.SubscriptionActive,
+		CurrentPeriodStart: testActiveProSince,
+		CurrentPeriodEnd:   testActiveProSince.AddDate(0, 1, 0),
+	}
+}
+
+func pendingPayment() *entity.Payment {
+	return &entity.Payment{ID: testPaymentID, UserID: testPayerID, PlanID: testPlanID, Status: entity.PaymentPending}
+}
+
+func expectEventAudited(m paymentServiceMocks, eventID string) {
+	m.auditor.On("Record", mock.Anything, mock.MatchedBy(func(log entity.AuditLog) bool {
+		var data map[string]interface{}
+		return log.ActionType == entity.PaymentEvent && json.Unmarshal(log.NewData, &data) == nil && data["event_id"] == eventID
+	})).Return()
+}
+
+func TestPaymentServiceHandleWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		object    map[string]interface{}
+		// expect sets up the effect the event has past recording it.
+		expect func(m paymentServiceMocks)
+	}{
+		{
+			name:      "payment succeeded subscribes the payer for one period",
+			eventType: "payment_intent.succeeded",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta},
+			expect: func(m paymentServiceMocks) {
+				m.storage.On("Get", mock.Anything, testPaymentID).Return(pendingPayment(), nil)
+				m.storage.On("SetStatus", mock.Anything, testPaymentID, entity.PaymentSucceeded).Return(nil)
+				expectPayer(m, &entity.Subscription{Plan: entity.Plan{ID: "free"}})
+				m.subscriptions.On("Create", mock.Anything, testPayerID, testPlanID, mock.Anything, mock.MatchedBy(func(end time.Time) bool {
+					return end.Sub(time.Now().AddDate(0, 1, 0)).Abs() < time.Minute
+				})).Return(&entity.Subscription{}, nil)
+			},
+		},
+		{
+			name:      "payment succeeded extends the plan the payer is on",
+			eventType: "payment_intent.succeeded",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta},
+			expect: func(m paymentServiceMocks) {
+				m.storage.On("Get", mock.Anything, testPaymentID).Return(pendingPayment(), nil)
+				m.storage.On("SetStatus", mock.Anything, testPaymentID, entity.PaymentSucceeded).Return(nil)
+				expectPayer(m, activePro())
+				m.subscriptions.On("Update", mock.Anything, testPayerID, activePro().CurrentPeriodEnd.AddDate(0, 1, 0)).Return(nil)
+			},
+		},
+		{
+			name:      "payment of a paid payment changes nothing",
+			eventType: "payment_intent.succeeded",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta},
+			expect: func(m paymentServiceMocks) {
+				paid := pendingPayment()
+				paid.Status = entity.PaymentSucceeded
+				m.storage.On("Get", mock.Anything, testPaymentID).Return(paid, nil)
+			},
+		},
+		{
+			name:      "payment failed marks the payment",
+			eventType: "payment_intent.payment_failed",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta},
+			expect: func(m paymentServiceMocks) {
+				m.storage.On("Get", mock.Anything, testPaymentID).Return(pendingPayment(), nil)
+				m.storage.On("SetStatus", mock.Anything, testPaymentID, entity.PaymentFailed).Return(nil)
+			},
+		},
+		{
+			name:      "invoice paid renews until the invoice's period end",
+			eventType: "invoice.paid",
+			object: map[string]interface{}{
+				"id":                   "in_1",
+				"object":               "invoice",
+				"subscription_details": map[string]interface{}{"metadata": testPaymentMeta},
+				"lines": map[string]interface{}{
+					"object": "list",
+					"data": []interface{}{
+						map[string]interface{}{"id": "il_1", "period": map[string]interface{}{"start": time.Now().Unix(), "end": testPeriodEnd.Unix()}},
+					},
+				},
+			},
+			expect: func(m paymentServiceMocks) {
+				expectPayer(m, activePro())
+				m.subscriptions.On("Update", mock.Anything, testPayerID, testPeriodEnd).Return(nil)
+			},
+		},
+		{
+			name:      "subscription updated to cancel at period end",
+			eventType: "customer.subscription.updated",
+			object: map[string]interface{}{
+				"id":                   "sub_1",
+				"object":               "subscription",
+				"metadata":             testPaymentMeta,
+				"status":               "active",
+				"cancel_at_period_end": true,
+				"current_period_end":   testPeriodEnd.Unix(),
+			},
+			expect: func(m paymentServiceMocks) {
+				expectPayer(m, activePro())
+				m.subscriptions.On("Update", mock.Anything, testPayerID, testPeriodEnd).Return(nil)
+				m.subscriptions.On("Cancel", mock.Anything, testPayerID, true).Return(&entity.Subscription{}, nil)
+			},
+		},
+		{
+			name:      "subscription updated to unpaid cancels right away",
+			eventType: "customer.subscription.updated",
+			object:    map[string]interface{}{"id": "sub_1", "object": "subscription", "metadata": testPaymentMeta, "status": "unpaid"},
+			expect: func(m paymentServiceMocks) {
+				m.subscriptions.On("Cancel", mock.Anything, testPayerID, false).Return(&entity.Subscription{}, nil)
+			},
+		},
+		{
+			name:      "subscription deleted cancels right away",
+			eventType: "customer.subscription.deleted",
+			object:    map[string]interface{}{"id": "sub_1", "object": "subscription", "metadata": testPaymentMeta, "status": "canceled"},
+			expect: func(m paymentServiceMocks) {
+				m.subscriptions.On("Cancel", mock.Anything, testPayerID, false).Return(&entity.Subscription{}, nil)
+			},
+		},
+		{
+			name:      "unknown type is recorded without effect",
+			eventType: "charge.refunded",
+			object:    map[string]interface{}{"id": "ch_1", "object": "charge", "metadata": testPaymentMeta},
+			expect:    func(m paymentServiceMocks) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestPaymentService(t, payment.NewStripe("sk_test", testWebhookSecret))
+
+			payload, signature := signedStripeEvent(t, "evt_1", tt.eventType, tt.object)
+
+			m.storage.On("RecordEvent", mock.Anything, "evt_1", mock.Anything).Return(true, nil).Once()
+			tt.expect(m)
+			expectEventAudited(m, "evt_1")
+
+			assert.NoError(t, s.HandleWebhook(context.Background(), payload, signature))
+		})
+	}
+}
+
+func TestPaymentServiceHandleWebhookDuplicate(t *testing.T) {
+	s, m := newTestPaymentService(t, payment.NewStripe("sk_test", testWebhookSecret))
+
+	payload, signature := signedStripeEvent(t, "evt_1", "payment_intent.succeeded",
+		map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta})
+
+	// The event is already recorded: it's acknowledged without touching payments, subscriptions or the audit log.
+	m.storage.On("RecordEvent", mock.Anything, "evt_1", payment.EventPaymentSucceeded).Return(false, nil).Once()
+
+	assert.NoError(t, s.HandleWebhook(context.Background(), payload, signature))
+}
+
+func TestPaymentServiceHandleWebhookRejectsSignature(t *testing.T) {
+	payload, _ := signedStripeEvent(t, "evt_1", "payment_intent.succeeded",
+		map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": testPaymentMeta})
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{
+			name:      "other secret",
+			signature: webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: "whsec_other"}).Header,
+		},
+		{
+			name: "stale",
+			signature: webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+				Payload:   payload,
+				Secret:    testWebhookSecret,
+				Timestamp: time.Now().Add(-webhook.DefaultTolerance - time.Minute),
+			}).Header,
+		},
+		{
+			name: "missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing may be recorded for a rejected event, the mocks fail on unexpected calls.
+			s, _ := newTestPaymentService(t, payment.NewStripe("sk_test", testWebhookSecret))
+
+			err := s.HandleWebhook(context.Background(), payload, tt.signature)
+
+			assert.Equal(t, errs.Validation, errs.CodeOf(err))
+			assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+		})
+	}
+}
+
+// recordPayments makes the storage mock keep the payments the service creates, so a flow can find them again,
+// and lets the payer be registered as a customer of the fake provider on the first payment.
+func recordPayments(m paymentServiceMocks) map[string]*entity.Payment {
+	payments := make(map[string]*entity.Payment)
+
+	m.storage.On("GetCustomer", mock.Anything, testPayerID, "fake").Return("", psqldb.ErrCustomerNotFound).Once()
+	m.users.On("GetCredentials", mock.Anything, testPayerID, false).Return(&entity.User{ID: testPayerID, Email: "payer@example.com"}, nil)
+	m.storage.On("SaveCustomer", mock.Anything, testPayerID, "fake", mock.Anything).Return(func(_ context.Context, _, _, customerID string) (string, error) {
+		return customerID, nil
+	}).Once()
+	m.storage.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		p := *args.Get(1).(*entity.Payment)
+		payments[p.ID] = &p
+	}).Return(nil).Once()
+	m.storage.On("Get", mock.Anything, mock.Anything).Return(func(_ context.Context, id string) (*entity.Payment, error) {
+		p, ok := payments[id]
+		if !ok {
+			return nil, psqldb.ErrPaymentNotFound
+		}
+
+		copied := *p
+		return &copied, nil
+	}).Maybe()
+	m.storage.On("SetStatus", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		payments[args.String(1)].Status = args.String(2)
+	}).Return(nil).Maybe()
+
+	return payments
+}
+
+// expectSubscribed sets up a payer without a subscription who gets subscribed to the test plan for one period.
+func expectSubscribed(m paymentServiceMocks) {
+	m.subscriptions.On("Plan", mock.Anything, testPlanID).Return(testPlan, nil)
+	m.subscriptions.On("Current", mock.Anything, testPayerID).Return(&entity.Subscription{Plan: entity.Plan{ID: "free"}}, nil).Once()
+	m.subscriptions.On("Create", mock.Anything, testPayerID, testPlanID, mock.Anything, mock.MatchedBy(func(end time.Time) bool {
+		return end.Sub(time.Now().AddDate(0, 1, 0)).Abs() < time.Minute
+	})).Return(&entity.Subscription{}, nil).Once()
+}
+
+func TestPaymentServiceCheckoutFlow(t *testing.T) {
+	ctx := context.Background()
+	provider := payment.NewFake()
+	s, m := newTestPaymentService(t, provider)
+
+	payments := recordPayments(m)
+	expectSubscribed(m)
+
+	start, err := s.CreateCheckout(ctx, testPayerID, testPlanID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/success", start.CheckoutURL)
+	assert.Equal(t, entity.PaymentKindCheckout, start.Payment.Kind)
+	assert.Equal(t, testPlan.PriceCents, start.Payment.AmountCents)
+	assert.Equal(t, entity.PaymentPending, payments[start.Payment.ID].Status)
+
+	// The client pays on the checkout page and the provider posts the resulting event to the webhook.
+	event, err := provider.Simulate(ctx, start.Payment.ProviderID, true)
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	m.storage.On("RecordEvent", mock.Anything, event.ID, payment.EventPaymentSucceeded).Return(true, nil).Once()
+	expectEventAudited(m, event.ID)
+
+	require.NoError(t, s.HandleWebhook(ctx, payload, provider.Sign(payload)))
+	assert.Equal(t, entity.PaymentSucceeded, payments[start.Payment.ID].Status)
+
+	// A redelivery is acknowledged without subscribing the payer again.
+	m.storage.On("RecordEvent", mock.Anything, event.ID, payment.EventPaymentSucceeded).Return(false, nil).Once()
+
+	assert.NoError(t, s.HandleWebhook(ctx, payload, provider.Sign(payload)))
+
+	// Events the fake didn't sign are rejected.
+	err = s.HandleWebhook(ctx, payload, "forged")
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
+
+func TestPaymentServiceSimulateFlow(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestPaymentService(t, payment.NewFake())
+
+	payments := recordPayments(m)
+	expectSubscribed(m)
+
+	start, err := s.CreateIntent(ctx, testPayerID, testPlanID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, start.ClientSecret)
+	assert.Equal(t, entity.PaymentKindIntent, start.Payment.Kind)
+
+	// Other users can't complete the payment.
+	err = s.Simulate(ctx, "other", start.Payment.ID, true)
+	assert.ErrorIs(t, err, psqldb.ErrPaymentNotFound)
+
+	m.storage.On("RecordEvent", mock.Anything, mock.Anything, payment.EventPaymentSucceeded).Return(true, nil).Once()
+	m.auditor.On("Record", mock.Anything, mock.MatchedBy(func(log entity.AuditLog) bool {
+		return log.ActionType == entity.PaymentEvent && log.TargetID == testPayerID
+	})).Return().Once()
+
+	require.NoError(t, s.Simulate(ctx, testPayerID, start.Payment.ID, true))
+	assert.Equal(t, entity.PaymentSucceeded, payments[start.Payment.ID].Status)
+
+	// A completed payment can't be simulated again.
+	err = s.Simulate(ctx, testPayerID, start.Payment.ID, true)
+	assert.ErrorIs(t, err, service.ErrPaymentCompleted)
+}
+
+func TestPaymentServiceSimulateFailedPayment(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestPaymentService(t, payment.NewFake())
+
+	payments := recordPayments(m)
+	m.subscriptions.On("Plan", mock.Anything, testPlanID).Return(testPlan, nil)
+
+	start, err := s.CreateIntent(ctx, testPayerID, testPlanID)
+	require.NoError(t, err)
+
+	// A failed payment subscribes nobody, the subscription mock fails on Create.
+	m.storage.On("RecordEvent", mock.Anything, mock.Anything, payment.EventPaymentFailed).Return(true, nil).Once()
+	m.auditor.On("Record", mock.Anything, mock.Anything).Return().Once()
+
+	require.NoError(t, s.Simulate(ctx, testPayerID, start.Payment.ID, false))
+	assert.Equal(t, entity.PaymentFailed, payments[start.Payment.ID].Status)
+}
+
+func TestPaymentServiceSimulateUnsupported(t *testing.T) {
+	s, _ := newTestPaymentService(t, payment.NewStripe("sk_test", testWebhookSecret))
+
+	err := s.Simulate(context.Background(), testPayerID, testPaymentID, true)
+	assert.ErrorIs(t, err, service.ErrSimulationUnsupported)
+}
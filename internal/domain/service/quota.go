@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+)
+
+// Quotas enforces the limits of the plans users are subscribed to.
+type Quotas interface {
+	// Usage returns what the user consumed this month together with the plan it's measured against.
+	Usage(ctx context.Context, userID string) (*entity.Usage, error)
+
+	// ReserveUploads verifies that the user's plan allows uploading all of the described images on top of
+	// the uploads still queued, and then calls enqueue for each of them in one transaction. The quota of an
+	// upload stays reserved under the ID enqueue returns until ReleaseUpload or expiresAt.
+	// Per-file limits are reported with errs.TooLarge, exhausted monthly or storage quotas with
+	// errs.QuotaExceeded and disallowed formats with errs.Validation.
+	ReserveUploads(ctx context.Context, userID string, uploads []entity.UploadInfo, expiresAt time.Time, enqueue func(ctx context.Context, i int) (string, error)) error
+
+	// ReleaseUpload gives back the quota reserved for the upload with the given ID. Processed uploads
+	// are released in the transaction recording their usage.
+	ReleaseUpload(ctx context.Context, id string) error
+}
+
+type quotaService struct {
+	usage         psqldb.UsageStorage
+	subscriptions Subscription
+	txManager     psqldb.TxManager
+}
+
+func NewQuotaService(usage psqldb.UsageStorage, subscriptions Subscription, txManager psqldb.TxManager) *quotaService {
+	return &quotaService{
+		usage:         usage,
+		subscriptions: subscriptions,
+		txManager:     txManager,
+	}
+}
+
+func (s *quotaService) Usage(ctx context.Context, userID string) (*entity.Usage, error) {
+	sub, err := s.subscriptions.Current(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.usage.Get(ctx, userID, entity.MonthStart(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	usage.Plan = sub.Plan
+
+	return usage, nil
+}
+
+func (s *quotaService) ReserveUploads(ctx context.Context, userID string, uploads []entity.UploadInfo, expiresAt time.Time, enqueue func(ctx context.Context, i int) (string, error)) error {
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Concurrent uploads of the user wait here, so each one sees the reservations of the ones before.
+		if err := s.usage.Lock(ctx, userID); err != nil {
+			return err
+		}
+
+		usage, err := s.Usage(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkUploads(usage, uploads); err != nil {
+			return err
+		}
+
+		for i, upload := range uploads {
+			id, err := enqueue(ctx, i)
+			if err != nil {
+				return err
+			}
+
+			err = s.usage.Reserve(ctx, entity.UploadReservation{
+				ID:        id,
+				UserID:    userID,
+				Bytes:     upload.StoredBytes,
+				ExpiresAt: expiresAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *quotaService) ReleaseUpload(ctx context.Context, id string) error {
+	return s.usage.Release(ctx, id)
+}
+
+// checkUploads verifies that every upload fits the plan's per-file limits and that all of them together fit
+// into what's left of the quotas once the pending uploads are counted.
+func checkUploads(usage *entity.Usage, uploads []entity.UploadInfo) error {
+	plan, limits := usage.Plan.ID, usage.Plan.Limits
+
+	var storedBytes int64
+
+	for _, upload := range uploads {
+		if !limits.AllowsFormat(upload.Format) {
+			return errs.NewValidation("unsupported file", errs.FieldError{
+				Field:   "photo",
+				Message: fmt.Sprintf("the %s plan allows %s images only", plan, strings.Join(limits.AllowedFormats, ", ")),
+			})
+		}
+
+		if exceeds(upload.Bytes, limits.MaxFileBytes) {
+			return errs.NewQuota(errs.TooLarge, "the file is larger than your plan allows", errs.Quota{
+				Plan: plan, Limit: entity.LimitFileBytes, Max: limits.MaxFileBytes, Used: upload.Bytes,
+			})
+		}
+
+		if dimension := max(upload.Width, upload.Height); exceeds(int64(dimension), int64(limits.MaxDimension)) {
+			return errs.NewQuota(errs.TooLarge, "the image is larger than your plan allows", errs.Quota{
+				Plan: plan, Limit: entity.LimitDimension, Max: int64(limits.MaxDimension), Used: int64(dimension),
+			})
+		}
+
+		storedBytes += upload.StoredBytes
+	}
+
+	images := int64(usage.ImagesThisMonth + usage.PendingImages)
+	if exceeds(images+int64(len(uploads)), int64(limits.MaxImagesPerMonth)) {
+		return errs.NewQuota(errs.QuotaExceeded, "monthly upload quota exhausted", errs.Quota{
+			Plan: plan, Limit: entity.LimitImagesPerMonth, Max: int64(limits.MaxImagesPerMonth), Used: images,
+		})
+	}
+
+	bytes := usage.BytesStored + usage.PendingBytes
+	if exceeds(bytes+storedBytes, limits.MaxStorageBytes) {
+		return errs.NewQuota(errs.QuotaExceeded, "storage quota exhausted", errs.Quota{
+			Plan: plan, Limit: entity.LimitStorageBytes, Max: limits.MaxStorageBytes, Used: bytes,
+		})
+	}
+
+	return nil
+}
+
+// exceeds reports whether value is over limit, a limit of 0 is unlimited.
+func exceeds(value, limit int64) bool {
+	return limit > 0 && value > limit
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUploads(t *testing.T) {
+	plan := entity.Plan{ID: "free", Limits: entity.PlanLimits{
+		MaxImagesPerMonth: 3,
+		MaxStorageBytes:   1000,
+		MaxFileBytes:      500,
+		MaxDimension:      100,
+		AllowedFormats:    []string{"jpeg"},
+	}}
+
+	upload := func(storedBytes int64) entity.UploadInfo {
+		return entity.UploadInfo{Bytes: 100, Width: 50, Height: 50, Format: "jpeg", StoredBytes: storedBytes}
+	}
+
+	tests := []struct {
+		name    string
+		usage   entity.Usage
+		uploads []entity.UploadInfo
+		code    errs.Code
+		limit   string
+	}{
+		{
+			name:    "fits",
+			usage:   entity.Usage{ImagesThisMonth: 1, BytesStored: 200},
+			uploads: []entity.UploadInfo{upload(300), upload(300)},
+		},
+		{
+			name:    "files of a request count together for images",
+			usage:   entity.Usage{ImagesThisMonth: 2},
+			uploads: []entity.UploadInfo{upload(10), upload(10)},
+			code:    errs.QuotaExceeded,
+			limit:   entity.LimitImagesPerMonth,
+		},
+		{
+			name:    "files of a request count together for bytes",
+			usage:   entity.Usage{BytesStored: 200},
+			uploads: []entity.UploadInfo{upload(500), upload(400)},
+			code:    errs.QuotaExceeded,
+			limit:   entity.LimitStorageBytes,
+		},
+		{
+			name:    "pending uploads count for images",
+			usage:   entity.Usage{ImagesThisMonth: 1, PendingImages: 2},
+			uploads: []entity.UploadInfo{upload(10)},
+			code:    errs.QuotaExceeded,
+			limit:   entity.LimitImagesPerMonth,
+		},
+		{
+			name:    "pending uploads count for bytes",
+			usage:   entity.Usage{BytesStored: 300, PendingBytes: 600},
+			uploads: []entity.UploadInfo{upload(200)},
+			code:    errs.QuotaExceeded,
+			limit:   entity.LimitStorageBytes,
+		},
+		{
+			name:    "per-file limit",
+			uploads: []entity.UploadInfo{upload(10), {Bytes: 600, Format: "jpeg"}},
+			code:    errs.TooLarge,
+			limit:   entity.LimitFileBytes,
+		},
+		{
+			name:    "format",
+			uploads: []entity.UploadInfo{{Bytes: 10, Format: "png"}},
+			code:    errs.Validation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := tt.usage
+			usage.Plan = plan
+
+			err := checkUploads(&usage, tt.uploads)
+
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.code, errs.CodeOf(err))
+
+			if tt.limit != "" {
+				var quotaErr *errs.Error
+				if assert.ErrorAs(t, err, &quotaErr) && assert.NotNil(t, quotaErr.Quota) {
+					assert.Equal(t, tt.limit, quotaErr.Quota.Limit)
+				}
+			}
+		})
+	}
+}
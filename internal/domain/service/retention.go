@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditRetentionOptions configures the AuditRetention.
+type AuditRetentionOptions struct {
+	// Policy tells how long the logs of each action type are kept.
+	Policy entity.RetentionPolicy
+	// Interval is the pause between two prunings.
+	Interval time.Duration
+	// BatchSize is the maximum number of logs archived and deleted at once.
+	BatchSize int
+}
+
+// AuditRetention deletes the audit logs which outlived their retention period. The deletions are recorded as
+// gaps in the hash chain, so the remaining logs still verify, and each batch deleted is logged like a deletion
+// through the dashboard. If an archive is set the logs are stored there before they are deleted.
+type AuditRetention struct {
+	storage   psqldb.DashboardStorage
+	archive   miniodb.ArchiveStorage
+	txManager psqldb.TxManager
+	logger    *logrus.Logger
+	metrics   *metrics.Metrics
+	options   AuditRetentionOptions
+}
+
+// NewAuditRetention returns an AuditRetention, archive may be nil to delete logs without archiving them.
+func NewAuditRetention(storage psqldb.DashboardStorage, archive miniodb.ArchiveStorage, txManager psqldb.TxManager, logger *logrus.Logger, metrics *metrics.Metrics, options AuditRetentionOptions) *AuditRetention {
+	return &AuditRetention{
+		storage:   storage,
+		archive:   archive,
+		txManager: txManager,
+		logger:    logger,
+		metrics:   metrics,
+		options:   options,
+	}
+}
+
+// Run prunes the logs under the configured policy until ctx is cancelled.
+func (r *AuditRetention) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Prune(context.WithoutCancel(ctx), r.options.Policy); err != nil {
+			r.logger.WithError(err).Error("failed to prune audit logs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Policy returns the configured retention policy.
+func (r *AuditRetention) Policy() entity.RetentionPolicy {
+	return r.options.Policy
+}
+
+// Prune deletes the logs expired under policy and returns how many were deleted.
+func (r *AuditRetention) Prune(ctx context.Context, policy entity.RetentionPolicy) (int64, error) {
+	actionTypes, err := r.storage.ActionTypes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	var total int64
+
+	for _, actionType := range actionTypes {
+		retention := policy.For(actionType)
+		if retention <= 0 {
+			continue
+		}
+
+		deleted, err := r.pruneActionType(ctx, actionType, now.Add(-retention))
+		total += deleted
+
+		if err != nil {
+			return total, fmt.Errorf("failed to prune %s logs: %w", actionType, err)
+		}
+	}
+
+	return total, nil
+}
+
+func (r *AuditRetention) pruneActionType(ctx context.Context, actionType string, before time.Time) (int64, error) {
+	logger := r.logger.WithFields(logrus.Fields{"function": "pruneActionType", "action_type": actionType})
+
+	var total int64
+
+	for {
+		logs, err := r.storage.ExpiredLogs(ctx, actionType, before, r.options.BatchSize)
+		if err != nil || len(logs) == 0 {
+			return total, err
+		}
+
+		if err := r.archiveLogs(ctx, logs); err != nil {
+			return total, err
+		}
+
+		deleted, err := r.deleteBatch(ctx, actionType, logs)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		r.metrics.AddAuditPruned(actionType, deleted)
+
+		logger.WithField("deleted", deleted).Info("expired audit logs deleted")
+
+		if len(logs) < r.options.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// deleteBatch deletes the logs, which are ordered by ID, and logs the deletion in the same transaction, so the gap
+// it leaves in the chain is never there without its log.
+func (r *AuditRetention) deleteBatch(ctx context.Context, actionType string, logs []entity.AuditLog) (int64, error) {
+	ids := make([]int64, len(logs))
+	for i, log := range logs {
+		ids[i] = int64(log.LogID)
+	}
+
+	var deleted int64
+
+	err := r.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if deleted, err = r.storage.DeleteLogsByID(ctx, ids); err != nil {
+			return err
+		}
+
+		log := entity.AuditLog{
+			ActionType: entity.DeleteAuditLogs,
+			NewData: auditJSON(map[string]interface{}{
+				"retention":   true,
+				"action_type": actionType,
+				"first_id":    ids[0],
+				"last_id":     ids[len(ids)-1],
+				"deleted":     deleted,
+			}),
+		}
+		fillRequestInfo(ctx, &log)
+
+		return r.storage.CreateLog(ctx, &log)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// archiveLogs stores the logs, which are the oldest expired ones of their action type, as one JSON object per line.
+// The key is derived from the IDs of the logs, archiving them again after a failed delete replaces the object.
+func (r *AuditRetention) archiveLogs(ctx context.Context, logs []entity.AuditLog) error {
+	if r.archive == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	for _, log := range logs {
+		if err := encoder.Encode(archivedLog{
+			ID:         log.LogID,
+			UserID:     log.UserID,
+			ActionType: log.ActionType,
+			TargetID:   log.TargetID,
+			IP:         log.IP,
+			UserAgent:  log.UserAgent,
+			OldData:    log.OldData,
+			NewData:    log.NewData,
+			Timestamp:  log.Timestamp,
+			PrevHash:   log.PrevHash,
+			Hash:       log.Hash,
+		}); err != nil {
+			return err
+		}
+	}
+
+	first, last := logs[0], logs[len(logs)-1]
+	key := fmt.Sprintf("audit-logs/%s/%020d-%020d.ndjson", first.ActionType, first.LogID, last.LogID)
+
+	return r.archive.Put(ctx, key, buf.Bytes(), "application/x-ndjson")
+}
+
+// archivedLog is an archived audit log, it holds every field the hash covers so the chain can be checked offline.
+type archivedLog struct {
+	ID         int             `json:"id"`
+	UserID     string          `json:"user_id,omitempty"`
+	ActionType string          `json:"action_type"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	OldData    json.RawMessage `json:"old_data,omitempty"`
+	NewData    json.RawMessage `json:"new_data,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuditRetention(t *testing.T, batchSize int) (*service.AuditRetention, *mocks.DashboardStorage, *mocks.TxManager) {
+	storage := mocks.NewDashboardStorage(t)
+	txManager := mocks.NewTxManager(t)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return service.NewAuditRetention(storage, nil, txManager, logger, nil, service.AuditRetentionOptions{BatchSize: batchSize}), storage, txManager
+}
+
+func TestAuditRetentionPrune(t *testing.T) {
+	r, storage, txManager := newTestAuditRetention(t, 2)
+	withinTestTx(txManager)
+
+	storage.On("ActionTypes", mock.Anything).Return([]string{entity.SignIn}, nil)
+	storage.On("ExpiredLogs", mock.Anything, entity.SignIn, mock.Anything, 2).
+		Return([]entity.AuditLog{{LogID: 3, ActionType: entity.SignIn}, {LogID: 5, ActionType: entity.SignIn}}, nil).Once()
+	storage.On("ExpiredLogs", mock.Anything, entity.SignIn, mock.Anything, 2).
+		Return([]entity.AuditLog{{LogID: 8, ActionType: entity.SignIn}}, nil).Once()
+	storage.On("DeleteLogsByID", mock.MatchedBy(inTestTx), []int64{3, 5}).Return(int64(2), nil)
+	storage.On("DeleteLogsByID", mock.MatchedBy(inTestTx), []int64{8}).Return(int64(1), nil)
+
+	// Every batch is logged in the transaction deleting it, with the range of IDs it deleted.
+	var logged []map[string]interface{}
+	storage.On("CreateLog", mock.MatchedBy(inTestTx), mock.MatchedBy(func(log *entity.AuditLog) bool {
+		return log.ActionType == entity.DeleteAuditLogs && !log.Timestamp.IsZero()
+	})).Run(func(args mock.Arguments) {
+		var data map[string]interface{}
+		require.NoError(t, json.Unmarshal(args.Get(1).(*entity.AuditLog).NewData, &data))
+		logged = append(logged, data)
+	}).Return(nil)
+
+	deleted, err := r.Prune(context.Background(), entity.RetentionPolicy{Default: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.Equal(t, []map[string]interface{}{
+		{"retention": true, "action_type": entity.SignIn, "first_id": float64(3), "last_id": float64(5), "deleted": float64(2)},
+		{"retention": true, "action_type": entity.SignIn, "first_id": float64(8), "last_id": float64(8), "deleted": float64(1)},
+	}, logged)
+}
+
+func TestAuditRetentionPruneLogFailure(t *testing.T) {
+	r, storage, txManager := newTestAuditRetention(t, 2)
+	withinTestTx(txManager)
+
+	storage.On("ActionTypes", mock.Anything).Return([]string{entity.SignIn}, nil)
+	storage.On("ExpiredLogs", mock.Anything, entity.SignIn, mock.Anything, 2).
+		Return([]entity.AuditLog{{LogID: 3, ActionType: entity.SignIn}}, nil)
+	storage.On("DeleteLogsByID", mock.MatchedBy(inTestTx), []int64{3}).Return(int64(1), nil)
+	// The deletion is rolled back with its log, so it isn't counted.
+	storage.On("CreateLog", mock.MatchedBy(inTestTx), mock.Anything).Return(assert.AnError)
+
+	deleted, err := r.Prune(context.Background(), entity.RetentionPolicy{Default: time.Hour})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Zero(t, deleted)
+}
@@ -2,12 +2,217 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/google/uuid"
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidPeriod = errs.New(errs.Validation, "subscription period must end after it starts")
 )
 
+// Subscription manages the plans users are subscribed to. User IDs are the UUIDs of the users table.
 type Subscription interface {
-	Create(ctx context.Context, userID int, startDate time.Time, endDate time.Time) error
-	Cancel(ctx context.Context, userID int) error
-	Update(ctx context.Context, userID int, newEndDate time.Time) error
-	IsExpired(ctx context.Context, userID int) (bool, time.Time, error)
+	// Plans returns every plan ordered by tier.
+	Plans(ctx context.Context) ([]entity.Plan, error)
+
+	// Plan returns the plan with the given ID.
+	// It returns psqldb.ErrPlanNotFound if there is no such plan.
+	Plan(ctx context.Context, id string) (*entity.Plan, error)
+
+	// Create subscribes the user to the plan for the period from startDate to endDate.
+	// A subscription the user already has is ended and replaced.
+	Create(ctx context.Context, userID, planID string, startDate time.Time, endDate time.Time) (*entity.Subscription, error)
+
+	// Cancel cancels the active subscription of the user. With atPeriodEnd the subscription stays active
+	// until its period ends, otherwise it ends right away. It returns the canceled subscription.
+	// It returns psqldb.ErrSubscriptionNotFound if the user has no active subscription.
+	Cancel(ctx context.Context, userID string, atPeriodEnd bool) (*entity.Subscription, error)
+
+	// Update moves the end of the period of the active subscription of the user, e.g. on renewal.
+	Update(ctx context.Context, userID string, newEndDate time.Time) error
+
+	// IsExpired reports whether the user has no active subscription and when the latest one ended.
+	// The time is zero if the user never subscribed.
+	IsExpired(ctx context.Context, userID string) (bool, time.Time, error)
+
+	// Current returns the active subscription of the user. Users without one get a subscription
+	// to the free plan which has no period and no ID.
+	Current(ctx context.Context, userID string) (*entity.Subscription, error)
+}
+
+type subscriptionService struct {
+	storage   psqldb.SubscriptionStorage
+	txManager psqldb.TxManager
+	logger    *logrus.Logger
+}
+
+func NewSubscriptionService(storage psqldb.SubscriptionStorage, txManager psqldb.TxManager, logger *logrus.Logger) *subscriptionService {
+	return &subscriptionService{
+		storage:   storage,
+		txManager: txManager,
+		logger:    logger,
+	}
+}
+
+func (s *subscriptionService) Plans(ctx context.Context) ([]entity.Plan, error) {
+	return s.storage.ListPlans(ctx)
+}
+
+func (s *subscriptionService) Plan(ctx context.Context, id string) (*entity.Plan, error) {
+	return s.storage.GetPlan(ctx, id)
+}
+
+func (s *subscriptionService) Create(ctx context.Context, userID, planID string, startDate time.Time, endDate time.Time) (*entity.Subscription, error) {
+	logger := logging.FromContext(ctx, s.logger).WithField("function", "Create")
+
+	if !endDate.After(startDate) {
+		return nil, ErrInvalidPeriod
+	}
+
+	var sub *entity.Subscription
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		plan, err := s.storage.GetPlan(ctx, planID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.end(ctx, userID, entity.SubscriptionCanceled); err != nil {
+			return err
+		}
+
+		sub = &entity.Subscription{
+			ID:                 uuid.NewString(),
+			UserID:             userID,
+			Plan:               *plan,
+			Status:             entity.SubscriptionActive,
+			CurrentPeriodStart: startDate,
+			CurrentPeriodEnd:   endDate,
+		}
+
+		return s.storage.Create(ctx, sub)
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to create subscription")
+		return nil, err
+	}
+
+	logger.WithField("plan", planID).Info("Create: subscription created")
+	return sub, nil
+}
+
+func (s *subscriptionService) Cancel(ctx context.Context, userID string, atPeriodEnd bool) (*entity.Subscription, error) {
+	sub, err := s.active(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if atPeriodEnd {
+		sub.CancelAtPeriodEnd = true
+	} else {
+		sub.Status = entity.SubscriptionCanceled
+	}
+
+	if err := s.storage.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (s *subscriptionService) Update(ctx context.Context, userID string, newEndDate time.Time) error {
+	sub, err := s.active(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !newEndDate.After(sub.CurrentPeriodStart) {
+		return ErrInvalidPeriod
+	}
+
+	sub.CurrentPeriodEnd = newEndDate
+
+	return s.storage.Update(ctx, sub)
+}
+
+func (s *subscriptionService) IsExpired(ctx context.Context, userID string) (bool, time.Time, error) {
+	sub, err := s.storage.GetLatest(ctx, userID)
+	if err != nil {
+		if errors.Is(err, psqldb.ErrSubscriptionNotFound) {
+			return true, time.Time{}, nil
+		}
+
+		return false, time.Time{}, err
+	}
+
+	return !sub.IsActive(time.Now()), sub.CurrentPeriodEnd, nil
+}
+
+func (s *subscriptionService) Current(ctx context.Context, userID string) (*entity.Subscription, error) {
+	sub, err := s.active(ctx, userID)
+	if err == nil {
+		return sub, nil
+	}
+
+	if !errors.Is(err, psqldb.ErrSubscriptionNotFound) {
+		return nil, err
+	}
+
+	free, err := s.storage.GetPlan(ctx, entity.PlanFree)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.Subscription{
+		UserID: userID,
+		Plan:   *free,
+		Status: entity.SubscriptionActive,
+	}, nil
+}
+
+// active returns the active subscription of the user. A subscription whose period ended is marked expired
+// on the way, so it doesn't block the user from subscribing again.
+func (s *subscriptionService) active(ctx context.Context, userID string) (*entity.Subscription, error) {
+	sub, err := s.storage.GetActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.IsActive(time.Now()) {
+		return sub, nil
+	}
+
+	sub.Status = entity.SubscriptionExpired
+	if err := s.storage.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return nil, psqldb.ErrSubscriptionNotFound
+}
+
+// end moves the subscription of the user in the active state, if any, to status.
+func (s *subscriptionService) end(ctx context.Context, userID, status string) error {
+	sub, err := s.storage.GetActive(ctx, userID)
+	if err != nil {
+		if errors.Is(err, psqldb.ErrSubscriptionNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !sub.IsActive(time.Now()) {
+		status = entity.SubscriptionExpired
+	}
+
+	sub.Status = status
+
+	return s.storage.Update(ctx, sub)
 }
@@ -3,17 +3,24 @@ package service
 import (
 	"context"
 	"fmt"
+	"github.com/google/uuid"
 	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
 	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
 	"github.com/nordew/UploadApp/pkg/auth"
 	"github.com/nordew/UploadApp/pkg/hasher"
+	"github.com/nordew/UploadApp/pkg/logging"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"sync"
+	"time"
 )
 
 var (
-	ErrValidationFailed = errors.New("invalid input")
+	ErrInvalidCredentials = errs.New(errs.Unauthorized, "invalid email or password")
+	ErrUserDisabled       = errs.New(errs.Forbidden, "user account is disabled")
+	ErrInvalidRole        = errs.New(errs.Validation, "unknown role")
+	ErrPasswordReset      = errs.New(errs.Forbidden, "password reset required, change the password to sign in again")
+	ErrSessionRevoked     = errs.New(errs.Unauthorized, "session was revoked, sign in again")
 )
 
 // Users is the interface that defines methods for user-related operations, such as sign-up and sign-in.
@@ -26,9 +33,17 @@ type Users interface {
 	// It returns the generated access token and an error if the operation fails or the user is not found.
 	SignIn(ctx context.Context, input entity.SignInInput) (string, string, error)
 
-	// Refresh generates new access and refresh tokens for an existing user session based on the provided ID and role.
-	// It returns the new access and refresh tokens, and an error if the operation fails.
-	Refresh(ctx context.Context, id, role string) (string, string, error)
+	// Refresh generates new access and refresh tokens for the session of the user with the given ID whose
+	// refresh token, issued at issuedAt, is refreshToken. The new refresh token replaces the stored one, so
+	// every refresh token can be used once. The tokens carry the user's current role.
+	// It returns the new access and refresh tokens, psqldb.ErrNoSuchRefreshToken if refreshToken isn't the
+	// user's current one, and the errors of CheckSession.
+	Refresh(ctx context.Context, id, refreshToken string, issuedAt time.Time) (string, string, error)
+
+	// CheckSession returns the user a token issued at issuedAt belongs to if the session is still valid.
+	// It returns ErrUserDisabled if the user is suspended and ErrSessionRevoked if the user was deleted
+	// or their sessions were revoked after the token was issued.
+	CheckSession(ctx context.Context, id string, issuedAt time.Time) (*entity.User, error)
 
 	GetCredentials(ctx context.Context, identifier string, byEmail bool) (*entity.User, error)
 
@@ -39,40 +54,48 @@ type Users interface {
 	ChangePassword(ctx context.Context, id, old, new string) error
 
 	IncrementPhotosUploaded(ctx context.Context, id string) error
+
+	// SetRole changes the role of the user with the given ID.
+	// It returns ErrInvalidRole if the role is unknown.
+	SetRole(ctx context.Context, id, role string) error
+
+	// SetDisabled disables or re-enables the account of the user with the given ID.
+	// Disabled users can't sign in.
+	SetDisabled(ctx context.Context, id string, disabled bool) error
 }
 
 type UserService struct {
 	storage psqldb.UserStorage
 	hasher  hasher.PasswordHasher
 	auth    auth.Authenticator
+	auditor Auditor
 	logger  *logrus.Logger
-
-	hmacSecret string
 }
 
-func NewUserService(storage psqldb.UserStorage, hasher hasher.PasswordHasher, auth auth.Authenticator, logger *logrus.Logger, hmacSecret string) *UserService {
+func NewUserService(storage psqldb.UserStorage, hasher hasher.PasswordHasher, auth auth.Authenticator, auditor Auditor, logger *logrus.Logger) *UserService {
 	return &UserService{
-		storage:    storage,
-		hasher:     hasher,
-		auth:       auth,
-		logger:     logger,
-		hmacSecret: hmacSecret,
+		storage: storage,
+		hasher:  hasher,
+		auth:    auth,
+		auditor: auditor,
+		logger:  logger,
 	}
 }
 
 func (s *UserService) SignUp(ctx context.Context, input entity.SignUpInput) error {
 	if err := input.Validate(); err != nil {
-		s.logger.WithError(err).Error("SignUp: validation failed")
-		return ErrValidationFailed
+		logging.FromContext(ctx, s.logger).WithError(err).Error("SignUp: validation failed")
+		return err
 	}
 
 	hashedPassword, err := s.hasher.Hash(input.Password)
 	if err != nil {
-		s.logger.WithError(err).Error("SignUp: failed to hash password")
+		logging.FromContext(ctx, s.logger).WithError(err).Error("SignUp: failed to hash password")
 		return errors.Wrap(err, "failed to hash password")
 	}
 
 	user := entity.User{
+		ID:       uuid.NewString(),
 		Name:     input.Name,
 		Email:    input.Email,
 		Password: hashedPassword,
@@ -81,33 +104,40 @@ func (s *UserService) SignUp(ctx context.Context, input entity.SignUpInput) erro
 	if err := s.storage.Create(ctx, user); err != nil {
 		switch {
 		case errors.Is(err, psqldb.ErrDuplicateKey):
-			s.logger.WithError(err).Error("SignUp: email already exists")
+			logging.FromContext(ctx, s.logger).WithError(err).Error("SignUp: email already exists")
 			return fmt.Errorf("SignUp: email already exists: %w", err)
 		case errors.Is(err, psqldb.ErrFailedToInsert):
-			s.logger.WithError(err).Error("SignUp: failed to create user")
+			logging.FromContext(ctx, s.logger).WithError(err).Error("SignUp: failed to create user")
 			return fmt.Errorf("SignUp: failed to create user: %w", err)
 		default:
-			s.logger.WithError(err).Error("SignUp: failed to create user")
+			logging.FromContext(ctx, s.logger).WithError(err).Error("SignUp: failed to create user")
 			return fmt.Errorf("SignUp: failed to create user: %w", err)
 		}
 	}
 
-	s.logger.Info("SignUp: user created successfully")
+	s.auditor.Record(ctx, entity.AuditLog{
+		UserID:     user.ID,
+		ActionType: entity.SignUp,
+		TargetID:   user.ID,
+		NewData:    auditJSON(map[string]interface{}{"name": user.Name, "email": user.Email}),
+	})
+
+	logging.FromContext(ctx, s.logger).Info("SignUp: user created successfully")
 	return nil
 }
 
 func (s *UserService) SignIn(ctx context.Context, input entity.SignInInput) (string, string, error) {
 	if err := input.Validate(); err != nil {
-		return "", "", fmt.Errorf("invalid input: %w", err)
+		return "", "", err
 	}
 
 	hashedPasswordCh := make(chan string, 1)
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
 
 	go func() {
 		hashedPassword, err := s.hasher.Hash(input.Password)
 		if err != nil {
-			s.logger.WithError(err).Error("failed to hash password")
+			logging.FromContext(ctx, s.logger).WithError(err).Error("failed to hash password")
 			errCh <- err
 			return
 		}
@@ -129,6 +159,11 @@ func (s *UserService) SignIn(ctx context.Context, input entity.SignInInput) (str
 	for i := 0; i < 2; i++ {
 		select {
 		case err := <-errCh:
+			if errors.Is(err, psqldb.ErrUserNotFound) {
+				s.recordSignInFailure(ctx, input.Email, "", "unknown email")
+				return "", "", ErrInvalidCredentials
+			}
+
 			return "", "", fmt.Errorf("authentication failed: %w", err)
 		case hashedPassword = <-hashedPasswordCh:
 		case user = <-userCh:
@@ -136,7 +171,18 @@ func (s *UserService) SignIn(ctx context.Context, input entity.SignInInput) (str
 	}
 
 	if hashedPassword != user.Password {
-		return "", "", fmt.Errorf("wrong password")
+		s.recordSignInFailure(ctx, input.Email, user.ID, "wrong password")
+		return "", "", ErrInvalidCredentials
+	}
+
+	if user.Disabled {
+		s.recordSignInFailure(ctx, input.Email, user.ID, "disabled")
+		return "", "", ErrUserDisabled
+	}
+
+	if user.PasswordResetRequired {
+		s.recordSignInFailure(ctx, input.Email, user.ID, "password reset required")
+		return "", "", ErrPasswordReset
 	}
 
 	accessToken, refreshToken, err := s.auth.GenerateTokens(&auth.GenerateTokenClaimsOptions{
@@ -147,48 +193,68 @@ func (s *UserService) SignIn(ctx context.Context, input entity.SignInInput) (str
 		return "", "", err
 	}
 
-	if err := s.storage.CreateRefreshToken(context.Background(), refreshToken, user.ID); err != nil {
+	if err := s.storage.CreateRefreshToken(ctx, refreshToken, user.ID); err != nil {
 		return "", "", err
 	}
 
+	s.auditor.Record(ctx, entity.AuditLog{UserID: user.ID, ActionType: entity.SignIn, TargetID: user.ID})
+
 	return accessToken, refreshToken, nil
 }
 
-func (s *UserService) Refresh(ctx context.Context, id, role string) (string, string, error) {
-	var accessToken, refreshToken string
-	var genErr, refreshErr error
+// recordSignInFailure audits a rejected sign-in. userID is empty if no user has the email.
+func (s *UserService) recordSignInFailure(ctx context.Context, email, userID, reason string) {
+	s.auditor.Record(ctx, entity.AuditLog{
+		ActionType: entity.SignInFailed,
+		TargetID:   userID,
+		NewData:    auditJSON(map[string]interface{}{"email": email, "reason": reason}),
+	})
+}
+
+func (s *UserService) Refresh(ctx context.Context, id, refreshToken string, issuedAt time.Time) (string, string, error) {
+	user, err := s.CheckSession(ctx, id, issuedAt)
+	if err != nil {
+		return "", "", err
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	// The presented refresh token is replaced with the new one, so the tokens have to be generated first.
+	accessToken, newRefreshToken, err := s.auth.GenerateTokens(&auth.GenerateTokenClaimsOptions{
+		UserId: id,
+		Role:   user.Role,
+	})
+	if err != nil {
+		return "", "", err
+	}
 
-	go func() {
-		defer wg.Done()
-		var err error
-		accessToken, refreshToken, err = s.auth.GenerateTokens(&auth.GenerateTokenClaimsOptions{
-			UserId: id,
-			Role:   role,
-		})
-		if err != nil {
-			genErr = err
-		}
-	}()
+	if err := s.storage.RefreshSession(ctx, refreshToken, newRefreshToken); err != nil {
+		return "", "", err
+	}
 
-	go func() {
-		defer wg.Done()
-		refreshErr = s.storage.RefreshSession(ctx, refreshToken, id)
-	}()
+	s.auditor.Record(ctx, entity.AuditLog{UserID: id, ActionType: entity.RefreshToken, TargetID: id})
 
-	wg.Wait()
+	return accessToken, newRefreshToken, nil
+}
 
-	if genErr != nil {
-		return "", "", genErr
+func (s *UserService) CheckSession(ctx context.Context, id string, issuedAt time.Time) (*entity.User, error) {
+	user, err := s.storage.GetByCredentials(ctx, id, false)
+	if err != nil {
+		if errors.Is(err, psqldb.ErrUserNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrSessionRevoked, err)
+		}
+
+		return nil, err
 	}
 
-	if refreshErr != nil {
-		return "", "", refreshErr
+	if user.Disabled {
+		return nil, ErrUserDisabled
 	}
 
-	return accessToken, refreshToken, nil
+	// Tokens carry whole seconds, one issued in the second the sessions were revoked in is still accepted.
+	if issuedAt.Before(user.SessionsRevokedAt.Truncate(time.Second)) {
+		return nil, ErrSessionRevoked
+	}
+
+	return user, nil
 }
 
 func (s *UserService) GetCredentials(ctx context.Context, identifier string, byEmail bool) (*entity.User, error) {
@@ -206,9 +272,65 @@ func (s *UserService) ChangePassword(ctx context.Context, email, old, new string
 		return fmt.Errorf("failed to hash new paasword")
 	}
 
-	return s.storage.ChangePassword(ctx, email, hashedOldPassword, hashedNewPassword)
+	if err := s.storage.ChangePassword(ctx, email, hashedOldPassword, hashedNewPassword); err != nil {
+		return err
+	}
+
+	user, err := s.storage.GetByCredentials(ctx, email, true)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("ChangePassword: failed to get user for the audit log")
+		return nil
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{UserID: user.ID, ActionType: entity.ChangePassword, TargetID: user.ID})
+
+	return nil
 }
 
 func (s *UserService) IncrementPhotosUploaded(ctx context.Context, id string) error {
 	return s.storage.IncrementPhotosUploaded(ctx, id)
 }
+
+func (s *UserService) SetRole(ctx context.Context, id, role string) error {
+	if !entity.IsValidRole(role) {
+		return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	old, err := s.storage.GetByCredentials(ctx, id, false)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.SetRole(ctx, id, role); err != nil {
+		return err
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		ActionType: entity.ChangeRole,
+		TargetID:   id,
+		OldData:    auditJSON(map[string]interface{}{"role": old.Role}),
+		NewData:    auditJSON(map[string]interface{}{"role": role}),
+	})
+
+	return nil
+}
+
+func (s *UserService) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	if err := s.storage.SetDisabled(ctx, id, disabled); err != nil {
+		return err
+	}
+
+	action := entity.EnableUser
+	if disabled {
+		action = entity.DisableUser
+	}
+
+	s.auditor.Record(ctx, entity.AuditLog{
+		ActionType: action,
+		TargetID:   id,
+		OldData:    auditJSON(map[string]interface{}{"disabled": !disabled}),
+		NewData:    auditJSON(map[string]interface{}{"disabled": disabled}),
+	})
+
+	return nil
+}
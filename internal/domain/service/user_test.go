@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	psqldb "github.com/nordew/UploadApp/internal/adapters/db/postgres"
+	"github.com/nordew/UploadApp/internal/domain/entity"
+	"github.com/nordew/UploadApp/internal/domain/errs"
+	"github.com/nordew/UploadApp/internal/domain/service"
+	"github.com/nordew/UploadApp/internal/mocks"
+	"github.com/nordew/UploadApp/pkg/auth"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testUserID = "user"
+
+type userServiceMocks struct {
+	storage       *mocks.UserStorage
+	authenticator *mocks.Authenticator
+	auditor       *mocks.Auditor
+}
+
+func newTestUserService(t *testing.T) (*service.UserService, userServiceMocks) {
+	m := userServiceMocks{
+		storage:       mocks.NewUserStorage(t),
+		authenticator: mocks.NewAuthenticator(t),
+		auditor:       mocks.NewAuditor(t),
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return service.NewUserService(m.storage, mocks.NewPasswordHasher(t), m.authenticator, m.auditor, logger), m
+}
+
+// expectRefreshTokens sets up the user's session and the tokens generated for it.
+func expectRefreshTokens(m userServiceMocks) {
+	m.storage.On("GetByCredentials", mock.Anything, testUserID, false).Return(&entity.User{ID: testUserID, Role: entity.RoleAdmin}, nil)
+	m.authenticator.On("GenerateTokens", &auth.GenerateTokenClaimsOptions{UserId: testUserID, Role: entity.RoleAdmin}).Return("access", "new-refresh", nil)
+}
+
+func TestUserServiceRefresh(t *testing.T) {
+	s, m := newTestUserService(t)
+
+	expectRefreshTokens(m)
+	// The presented refresh token is replaced with the one just generated.
+	m.storage.On("RefreshSession", mock.Anything, "old-refresh", "new-refresh").Return(nil)
+	m.auditor.On("Record", mock.Anything, mock.MatchedBy(func(log entity.AuditLog) bool {
+		return log.ActionType == entity.RefreshToken && log.UserID == testUserID
+	})).Return()
+
+	accessToken, refreshToken, err := s.Refresh(context.Background(), testUserID, "old-refresh", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "access", accessToken)
+	assert.Equal(t, "new-refresh", refreshToken)
+}
+
+func TestUserServiceRefreshReplacedToken(t *testing.T) {
+	s, m := newTestUserService(t)
+
+	expectRefreshTokens(m)
+	// The presented token isn't stored anymore, e.g. it was used before: no tokens are handed out or audited.
+	m.storage.On("RefreshSession", mock.Anything, "old-refresh", "new-refresh").Return(psqldb.ErrNoSuchRefreshToken)
+
+	accessToken, refreshToken, err := s.Refresh(context.Background(), testUserID, "old-refresh", time.Now())
+	assert.ErrorIs(t, err, psqldb.ErrNoSuchRefreshToken)
+	assert.Equal(t, errs.Unauthorized, errs.CodeOf(err))
+	assert.Empty(t, accessToken)
+	assert.Empty(t, refreshToken)
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "uploadapp"
+
+const (
+	StageDecode = "decode"
+	StageResize = "resize"
+	StageEncode = "encode"
+	StageStore  = "store"
+)
+
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeExpired   = "expired"
+)
+
+const (
+	DriverMinio    = "minio"
+	DriverPostgres = "postgres"
+)
+
+// Metrics holds the application collectors.
+// A nil *Metrics is valid and records nothing, which is handy for tools that don't expose metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	uploadBytes         prometheus.Counter
+	publishFailures     prometheus.Counter
+	jobStageDuration    *prometheus.HistogramVec
+	jobs                *prometheus.CounterVec
+	storageDuration     *prometheus.HistogramVec
+	auditDropped        prometheus.Counter
+	auditPruned         *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of handled HTTP requests.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent serving HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		uploadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upload_bytes_total",
+			Help:      "Size of the accepted uploaded files.",
+		}),
+		publishFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queue_publish_failures_total",
+			Help:      "Number of image jobs which couldn't be published to the queue.",
+		}),
+		jobStageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_stage_duration_seconds",
+			Help:      "Time spent in each stage of an image job, per variant where it applies.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"stage", "variant"}),
+		jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_total",
+			Help:      "Number of consumed image jobs by outcome.",
+		}, []string{"outcome"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Latency of storage operations by driver.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"driver", "operation"}),
+		auditDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_logs_dropped_total",
+			Help:      "Number of audit logs dropped because the writer's queue was full or writing failed.",
+		}),
+		auditPruned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_logs_pruned_total",
+			Help:      "Number of audit logs deleted by the retention policy by action type.",
+		}, []string{"action_type"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.uploadBytes,
+		m.publishFailures,
+		m.jobStageDuration,
+		m.jobs,
+		m.storageDuration,
+		m.auditDropped,
+		m.auditPruned,
+	)
+
+	return m
+}
+
+// RegisterDB exposes the connection pool statistics of db under the given name.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	if m == nil {
+		return
+	}
+
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	code := strconv.Itoa(status)
+
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpRequestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+func (m *Metrics) AddUploadBytes(n int64) {
+	if m == nil {
+		return
+	}
+
+	m.uploadBytes.Add(float64(n))
+}
+
+func (m *Metrics) IncPublishFailures() {
+	if m == nil {
+		return
+	}
+
+	m.publishFailures.Inc()
+}
+
+// ObserveJobStage records the duration of a job stage, variant is empty for stages done once per job.
+func (m *Metrics) ObserveJobStage(stage, variant string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.jobStageDuration.WithLabelValues(stage, variant).Observe(duration.Seconds())
+}
+
+func (m *Metrics) IncJobs(outcome string) {
+	if m == nil {
+		return
+	}
+
+	m.jobs.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) ObserveStorageOperation(driver, operation string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.storageDuration.WithLabelValues(driver, operation).Observe(duration.Seconds())
+}
+
+func (m *Metrics) IncAuditDropped() {
+	if m == nil {
+		return
+	}
+
+	m.auditDropped.Inc()
+}
+
+func (m *Metrics) AddAuditPruned(actionType string, n int64) {
+	if m == nil {
+		return
+	}
+
+	m.auditPruned.WithLabelValues(actionType).Add(float64(n))
+}
@@ -12,17 +12,46 @@ type Authenticator struct {
 	mock.Mock
 }
 
-// GenerateToken provides a mock function with given fields: options
-func (_m *Authenticator) GenerateToken(options *auth.GenerateTokenClaimsOptions) (string, error) {
-	ret := _m.Called(options)
+// GenerateRefreshToken provides a mock function with given fields: id, role
+func (_m *Authenticator) GenerateRefreshToken(id string, role string) (string, error) {
+	ret := _m.Called(id, role)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GenerateToken")
+		panic("no return value specified for GenerateRefreshToken")
 	}
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(*auth.GenerateTokenClaimsOptions) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(string, string) (string, error)); ok {
+		return rf(id, role)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(id, role)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(id, role)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GenerateTokens provides a mock function with given fields: options
+func (_m *Authenticator) GenerateTokens(options *auth.GenerateTokenClaimsOptions) (string, string, error) {
+	ret := _m.Called(options)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GenerateTokens")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(*auth.GenerateTokenClaimsOptions) (string, string, error)); ok {
 		return rf(options)
 	}
 	if rf, ok := ret.Get(0).(func(*auth.GenerateTokenClaimsOptions) string); ok {
@@ -31,13 +60,19 @@ func (_m *Authenticator) GenerateToken(options *auth.GenerateTokenClaimsOptions)
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(*auth.GenerateTokenClaimsOptions) error); ok {
+	if rf, ok := ret.Get(1).(func(*auth.GenerateTokenClaimsOptions) string); ok {
 		r1 = rf(options)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(*auth.GenerateTokenClaimsOptions) error); ok {
+		r2 = rf(options)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
 // ParseToken provides a mock function with given fields: accessToken
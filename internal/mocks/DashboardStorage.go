@@ -0,0 +1,279 @@
+// Code generated by mockery v2.38.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "github.com/nordew/UploadApp/internal/domain/entity"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// DashboardStorage is an autogenerated mock type for the DashboardStorage type
+type DashboardStorage struct {
+	mock.Mock
+}
+
+// ActionTypes provides a mock function with given fields: ctx
+func (_m *DashboardStorage) ActionTypes(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ActionTypes")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChainAnchors provides a mock function with given fields: ctx
+func (_m *DashboardStorage) ChainAnchors(ctx context.Context) (*entity.ChainAnchors, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChainAnchors")
+	}
+
+	var r0 *entity.ChainAnchors
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*entity.ChainAnchors, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *entity.ChainAnchors); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ChainAnchors)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateLog provides a mock function with given fields: ctx, log
+func (_m *DashboardStorage) CreateLog(ctx context.Context, log *entity.AuditLog) error {
+	ret := _m.Called(ctx, log)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateLog")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.AuditLog) error); ok {
+		r0 = rf(ctx, log)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteLog provides a mock function with given fields: ctx, id
+func (_m *DashboardStorage) DeleteLog(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLog")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteLogs provides a mock function with given fields: ctx, query
+func (_m *DashboardStorage) DeleteLogs(ctx context.Context, query entity.AuditLogQuery) (int64, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLogs")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.AuditLogQuery) (int64, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.AuditLogQuery) int64); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.AuditLogQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteLogsByID provides a mock function with given fields: ctx, ids
+func (_m *DashboardStorage) DeleteLogsByID(ctx context.Context, ids []int64) (int64, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLogsByID")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) (int64, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) int64); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExpiredLogs provides a mock function with given fields: ctx, actionType, before, limit
+func (_m *DashboardStorage) ExpiredLogs(ctx context.Context, actionType string, before time.Time, limit int) ([]entity.AuditLog, error) {
+	ret := _m.Called(ctx, actionType, before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpiredLogs")
+	}
+
+	var r0 []entity.AuditLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) ([]entity.AuditLog, error)); ok {
+		return rf(ctx, actionType, before, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) []entity.AuditLog); ok {
+		r0 = rf(ctx, actionType, before, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.AuditLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, int) error); ok {
+		r1 = rf(ctx, actionType, before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLogs provides a mock function with given fields: ctx, filter
+func (_m *DashboardStorage) ListLogs(ctx context.Context, filter entity.AuditLogFilter) ([]entity.AuditLog, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLogs")
+	}
+
+	var r0 []entity.AuditLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.AuditLogFilter) ([]entity.AuditLog, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.AuditLogFilter) []entity.AuditLog); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.AuditLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.AuditLogFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StreamChain provides a mock function with given fields: ctx, fn
+func (_m *DashboardStorage) StreamChain(ctx context.Context, fn func(*entity.AuditLog) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StreamChain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(*entity.AuditLog) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StreamLogs provides a mock function with given fields: ctx, filter, fn
+func (_m *DashboardStorage) StreamLogs(ctx context.Context, filter entity.AuditLogFilter, fn func(*entity.AuditLog) error) error {
+	ret := _m.Called(ctx, filter, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StreamLogs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.AuditLogFilter, func(*entity.AuditLog) error) error); ok {
+		r0 = rf(ctx, filter, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewDashboardStorage creates a new instance of DashboardStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewDashboardStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *DashboardStorage {
+	mock := &DashboardStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
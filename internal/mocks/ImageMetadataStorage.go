@@ -0,0 +1,161 @@
+// Code generated by mockery v2.38.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "github.com/nordew/UploadApp/internal/domain/entity"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ImageMetadataStorage is an autogenerated mock type for the ImageStorage type
+type ImageMetadataStorage struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, image
+func (_m *ImageMetadataStorage) Create(ctx context.Context, image *entity.ImageMeta) error {
+	ret := _m.Called(ctx, image)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.ImageMeta) error); ok {
+		r0 = rf(ctx, image)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *ImageMetadataStorage) Delete(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, id
+func (_m *ImageMetadataStorage) Get(ctx context.Context, id string) (*entity.ImageMeta, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *entity.ImageMeta
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.ImageMeta, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.ImageMeta); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ImageMeta)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, filter
+func (_m *ImageMetadataStorage) List(ctx context.Context, filter entity.ImageFilter) ([]entity.ImageMeta, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []entity.ImageMeta
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.ImageFilter) ([]entity.ImageMeta, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.ImageFilter) []entity.ImageMeta); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.ImageMeta)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.ImageFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetVariants provides a mock function with given fields: ctx, id, variants
+func (_m *ImageMetadataStorage) SetVariants(ctx context.Context, id string, variants []entity.ImageVariant) error {
+	ret := _m.Called(ctx, id, variants)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetVariants")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []entity.ImageVariant) error); ok {
+		r0 = rf(ctx, id, variants)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: ctx, id, update
+func (_m *ImageMetadataStorage) Update(ctx context.Context, id string, update entity.ImageUpdate) error {
+	ret := _m.Called(ctx, id, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, entity.ImageUpdate) error); ok {
+		r0 = rf(ctx, id, update)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewImageMetadataStorage creates a new instance of ImageMetadataStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewImageMetadataStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ImageMetadataStorage {
+	mock := &ImageMetadataStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -5,9 +5,12 @@ package mocks
 import (
 	context "context"
 
+	miniodb "github.com/nordew/UploadApp/internal/adapters/db/minio"
 	entity "github.com/nordew/UploadApp/internal/domain/entity"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // ImageStorage is an autogenerated mock type for the ImageStorage type
@@ -15,6 +18,54 @@ type ImageStorage struct {
 	mock.Mock
 }
 
+// DeleteAllImages provides a mock function with given fields: ctx, id
+func (_m *ImageStorage) DeleteAllImages(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAllImages")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, name
+func (_m *ImageStorage) Get(ctx context.Context, name string) (*entity.Image, error) {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *entity.Image
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Image, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Image); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetAll provides a mock function with given fields: ctx, id
 func (_m *ImageStorage) GetAll(ctx context.Context, id string) ([]entity.Image, error) {
 	ret := _m.Called(ctx, id)
@@ -45,29 +96,75 @@ func (_m *ImageStorage) GetAll(ctx context.Context, id string) ([]entity.Image,
 	return r0, r1
 }
 
-// GetBySize provides a mock function with given fields: ctx, id, size
-func (_m *ImageStorage) GetBySize(ctx context.Context, id string, size int) (*entity.Image, error) {
-	ret := _m.Called(ctx, id, size)
+// ListObjects provides a mock function with given fields: ctx
+func (_m *ImageStorage) ListObjects(ctx context.Context) ([]miniodb.ObjectInfo, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetBySize")
+		panic("no return value specified for ListObjects")
 	}
 
-	var r0 *entity.Image
+	var r0 []miniodb.ObjectInfo
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) (*entity.Image, error)); ok {
-		return rf(ctx, id, size)
+	if rf, ok := ret.Get(0).(func(context.Context) ([]miniodb.ObjectInfo, error)); ok {
+		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) *entity.Image); ok {
-		r0 = rf(ctx, id, size)
+	if rf, ok := ret.Get(0).(func(context.Context) []miniodb.ObjectInfo); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*entity.Image)
+			r0 = ret.Get(0).([]miniodb.ObjectInfo)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
-		r1 = rf(ctx, id, size)
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *ImageStorage) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PresignedURL provides a mock function with given fields: ctx, name, expiry
+func (_m *ImageStorage) PresignedURL(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	ret := _m.Called(ctx, name, expiry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PresignedURL")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (string, error)); ok {
+		return rf(ctx, name, expiry)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) string); ok {
+		r0 = rf(ctx, name, expiry)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, name, expiry)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -9,6 +9,8 @@ import (
 	entity "github.com/nordew/UploadApp/internal/domain/entity"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Images is an autogenerated mock type for the Images type
@@ -16,29 +18,29 @@ type Images struct {
 	mock.Mock
 }
 
-// GetAll provides a mock function with given fields: ctx, id
-func (_m *Images) GetAll(ctx context.Context, id string) ([]entity.Image, error) {
-	ret := _m.Called(ctx, id)
+// CollectGarbage provides a mock function with given fields: ctx, olderThan, dryRun
+func (_m *Images) CollectGarbage(ctx context.Context, olderThan time.Duration, dryRun bool) ([]string, error) {
+	ret := _m.Called(ctx, olderThan, dryRun)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetAll")
+		panic("no return value specified for CollectGarbage")
 	}
 
-	var r0 []entity.Image
+	var r0 []string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]entity.Image, error)); ok {
-		return rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, bool) ([]string, error)); ok {
+		return rf(ctx, olderThan, dryRun)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []entity.Image); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, bool) []string); ok {
+		r0 = rf(ctx, olderThan, dryRun)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]entity.Image)
+			r0 = ret.Get(0).([]string)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration, bool) error); ok {
+		r1 = rf(ctx, olderThan, dryRun)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -46,9 +48,103 @@ func (_m *Images) GetAll(ctx context.Context, id string) ([]entity.Image, error)
 	return r0, r1
 }
 
-// GetBySize provides a mock function with given fields: ctx, id, size
-func (_m *Images) GetBySize(ctx context.Context, id string, size int) (*entity.Image, error) {
-	ret := _m.Called(ctx, id, size)
+// Delete provides a mock function with given fields: ctx, actor, id
+func (_m *Images) Delete(ctx context.Context, actor entity.Actor, id string) error {
+	ret := _m.Called(ctx, actor, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) error); ok {
+		r0 = rf(ctx, actor, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteAllImages provides a mock function with given fields: ctx, actor, name
+func (_m *Images) DeleteAllImages(ctx context.Context, actor entity.Actor, name string) error {
+	ret := _m.Called(ctx, actor, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAllImages")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) error); ok {
+		r0 = rf(ctx, actor, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteByUser provides a mock function with given fields: ctx, actor, userID
+func (_m *Images) DeleteByUser(ctx context.Context, actor entity.Actor, userID string) (int, error) {
+	ret := _m.Called(ctx, actor, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteByUser")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) (int, error)); ok {
+		return rf(ctx, actor, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) int); ok {
+		r0 = rf(ctx, actor, userID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.Actor, string) error); ok {
+		r1 = rf(ctx, actor, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Get provides a mock function with given fields: ctx, actor, id
+func (_m *Images) Get(ctx context.Context, actor entity.Actor, id string) (*entity.ImageMeta, error) {
+	ret := _m.Called(ctx, actor, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *entity.ImageMeta
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) (*entity.ImageMeta, error)); ok {
+		return rf(ctx, actor, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string) *entity.ImageMeta); ok {
+		r0 = rf(ctx, actor, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ImageMeta)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.Actor, string) error); ok {
+		r1 = rf(ctx, actor, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBySize provides a mock function with given fields: ctx, actor, name, size
+func (_m *Images) GetBySize(ctx context.Context, actor entity.Actor, name string, size int) (*entity.Image, error) {
+	ret := _m.Called(ctx, actor, name, size)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetBySize")
@@ -56,19 +152,19 @@ func (_m *Images) GetBySize(ctx context.Context, id string, size int) (*entity.I
 
 	var r0 *entity.Image
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) (*entity.Image, error)); ok {
-		return rf(ctx, id, size)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, int) (*entity.Image, error)); ok {
+		return rf(ctx, actor, name, size)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) *entity.Image); ok {
-		r0 = rf(ctx, id, size)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, int) *entity.Image); ok {
+		r0 = rf(ctx, actor, name, size)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*entity.Image)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
-		r1 = rf(ctx, id, size)
+	if rf, ok := ret.Get(1).(func(context.Context, entity.Actor, string, int) error); ok {
+		r1 = rf(ctx, actor, name, size)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -76,27 +172,107 @@ func (_m *Images) GetBySize(ctx context.Context, id string, size int) (*entity.I
 	return r0, r1
 }
 
-// Upload provides a mock function with given fields: ctx, _a1
-func (_m *Images) Upload(ctx context.Context, _a1 image.Image) (string, error) {
-	ret := _m.Called(ctx, _a1)
+// GetVariant provides a mock function with given fields: ctx, actor, id, size
+func (_m *Images) GetVariant(ctx context.Context, actor entity.Actor, id string, size int) (*entity.Image, error) {
+	ret := _m.Called(ctx, actor, id, size)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Upload")
+		panic("no return value specified for GetVariant")
 	}
 
-	var r0 string
+	var r0 *entity.Image
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, image.Image) (string, error)); ok {
-		return rf(ctx, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, int) (*entity.Image, error)); ok {
+		return rf(ctx, actor, id, size)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, image.Image) string); ok {
-		r0 = rf(ctx, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, int) *entity.Image); ok {
+		r0 = rf(ctx, actor, id, size)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.Actor, string, int) error); ok {
+		r1 = rf(ctx, actor, id, size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, params
+func (_m *Images) List(ctx context.Context, params entity.ImageListParams) (*entity.ImagePage, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *entity.ImagePage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.ImageListParams) (*entity.ImagePage, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.ImageListParams) *entity.ImagePage); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ImagePage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.ImageListParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reprocess provides a mock function with given fields: ctx, id
+func (_m *Images) Reprocess(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reprocess")
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, image.Image) error); ok {
-		r1 = rf(ctx, _a1)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: ctx, actor, id, update
+func (_m *Images) Update(ctx context.Context, actor entity.Actor, id string, update entity.ImageUpdate) (*entity.ImageMeta, error) {
+	ret := _m.Called(ctx, actor, id, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 *entity.ImageMeta
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, entity.ImageUpdate) (*entity.ImageMeta, error)); ok {
+		return rf(ctx, actor, id, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.Actor, string, entity.ImageUpdate) *entity.ImageMeta); ok {
+		r0 = rf(ctx, actor, id, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ImageMeta)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.Actor, string, entity.ImageUpdate) error); ok {
+		r1 = rf(ctx, actor, id, update)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -104,6 +280,24 @@ func (_m *Images) Upload(ctx context.Context, _a1 image.Image) (string, error) {
 	return r0, r1
 }
 
+// Upload provides a mock function with given fields: ctx, _a1, userId
+func (_m *Images) Upload(ctx context.Context, _a1 image.Image, userId string) error {
+	ret := _m.Called(ctx, _a1, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, image.Image, string) error); ok {
+		r0 = rf(ctx, _a1, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewImages creates a new instance of Images. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewImages(t interface {
@@ -0,0 +1,329 @@
+// Code generated by mockery v2.38.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "github.com/nordew/UploadApp/internal/domain/entity"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PaymentStorage is an autogenerated mock type for the PaymentStorage type
+type PaymentStorage struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, payment
+func (_m *PaymentStorage) Create(ctx context.Context, payment *entity.Payment) error {
+	ret := _m.Called(ctx, payment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Payment) error); ok {
+		r0 = rf(ctx, payment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, id
+func (_m *PaymentStorage) Get(ctx context.Context, id string) (*entity.Payment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *entity.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Payment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Payment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCustomer provides a mock function with given fields: ctx, userID, provider
+func (_m *PaymentStorage) GetCustomer(ctx context.Context, userID string, provider string) (string, error) {
+	ret := _m.Called(ctx, userID, provider)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCustomer")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
+		return rf(ctx, userID, provider)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, userID, provider)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, provider)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetForUpdate provides a mock function with given fields: ctx, id
+func (_m *PaymentStorage) GetForUpdate(ctx context.Context, id string) (*entity.Payment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetForUpdate")
+	}
+
+	var r0 *entity.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Payment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Payment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, filter
+func (_m *PaymentStorage) List(ctx context.Context, filter entity.PaymentFilter) ([]entity.Payment, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []entity.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.PaymentFilter) ([]entity.Payment, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.PaymentFilter) []entity.Payment); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.PaymentFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListByUser provides a mock function with given fields: ctx, userID
+func (_m *PaymentStorage) ListByUser(ctx context.Context, userID string) ([]entity.Payment, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByUser")
+	}
+
+	var r0 []entity.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]entity.Payment, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []entity.Payment); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListRefundsByUser provides a mock function with given fields: ctx, userID
+func (_m *PaymentStorage) ListRefundsByUser(ctx context.Context, userID string) ([]entity.Refund, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRefundsByUser")
+	}
+
+	var r0 []entity.Refund
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]entity.Refund, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []entity.Refund); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.Refund)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordEvent provides a mock function with given fields: ctx, id, eventType
+func (_m *PaymentStorage) RecordEvent(ctx context.Context, id string, eventType string) (bool, error) {
+	ret := _m.Called(ctx, id, eventType)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordEvent")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, id, eventType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, id, eventType)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, eventType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordRefund provides a mock function with given fields: ctx, refund
+func (_m *PaymentStorage) RecordRefund(ctx context.Context, refund *entity.Refund) (*entity.Payment, error) {
+	ret := _m.Called(ctx, refund)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordRefund")
+	}
+
+	var r0 *entity.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Refund) (*entity.Payment, error)); ok {
+		return rf(ctx, refund)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Refund) *entity.Payment); ok {
+		r0 = rf(ctx, refund)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *entity.Refund) error); ok {
+		r1 = rf(ctx, refund)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveCustomer provides a mock function with given fields: ctx, userID, provider, customerID
+func (_m *PaymentStorage) SaveCustomer(ctx context.Context, userID string, provider string, customerID string) (string, error) {
+	ret := _m.Called(ctx, userID, provider, customerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveCustomer")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (string, error)); ok {
+		return rf(ctx, userID, provider, customerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) string); ok {
+		r0 = rf(ctx, userID, provider, customerID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, userID, provider, customerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetStatus provides a mock function with given fields: ctx, id, status
+func (_m *PaymentStorage) SetStatus(ctx context.Context, id string, status string) error {
+	ret := _m.Called(ctx, id, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewPaymentStorage creates a new instance of PaymentStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPaymentStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PaymentStorage {
+	mock := &PaymentStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,234 @@
+// Code generated by mockery v2.38.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "github.com/nordew/UploadApp/internal/domain/entity"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// Subscription is an autogenerated mock type for the Subscription type
+type Subscription struct {
+	mock.Mock
+}
+
+// Cancel provides a mock function with given fields: ctx, userID, atPeriodEnd
+func (_m *Subscription) Cancel(ctx context.Context, userID string, atPeriodEnd bool) (*entity.Subscription, error) {
+	ret := _m.Called(ctx, userID, atPeriodEnd)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Cancel")
+	}
+
+	var r0 *entity.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) (*entity.Subscription, error)); ok {
+		return rf(ctx, userID, atPeriodEnd)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *entity.Subscription); ok {
+		r0 = rf(ctx, userID, atPeriodEnd)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, userID, atPeriodEnd)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, userID, planID, startDate, endDate
+func (_m *Subscription) Create(ctx context.Context, userID string, planID string, startDate time.Time, endDate time.Time) (*entity.Subscription, error) {
+	ret := _m.Called(ctx, userID, planID, startDate, endDate)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 *entity.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) (*entity.Subscription, error)); ok {
+		return rf(ctx, userID, planID, startDate, endDate)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) *entity.Subscription); ok {
+		r0 = rf(ctx, userID, planID, startDate, endDate)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, userID, planID, startDate, endDate)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Current provides a mock function with given fields: ctx, userID
+func (_m *Subscription) Current(ctx context.Context, userID string) (*entity.Subscription, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Current")
+	}
+
+	var r0 *entity.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Subscription, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Subscription); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsExpired provides a mock function with given fields: ctx, userID
+func (_m *Subscription) IsExpired(ctx context.Context, userID string) (bool, time.Time, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsExpired")
+	}
+
+	var r0 bool
+	var r1 time.Time
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, time.Time, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) time.Time); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Get(1).(time.Time)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, userID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Plan provides a mock function with given fields: ctx, id
+func (_m *Subscription) Plan(ctx context.Context, id string) (*entity.Plan, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Plan")
+	}
+
+	var r0 *entity.Plan
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Plan, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Plan); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Plan)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Plans provides a mock function with given fields: ctx
+func (_m *Subscription) Plans(ctx context.Context) ([]entity.Plan, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Plans")
+	}
+
+	var r0 []entity.Plan
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.Plan, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.Plan); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.Plan)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, userID, newEndDate
+func (_m *Subscription) Update(ctx context.Context, userID string, newEndDate time.Time) error {
+	ret := _m.Called(ctx, userID, newEndDate)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, userID, newEndDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSubscription creates a new instance of Subscription. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSubscription(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Subscription {
+	mock := &Subscription{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,151 @@
+// Code generated by mockery v2.38.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "github.com/nordew/UploadApp/internal/domain/entity"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// UsageStorage is an autogenerated mock type for the UsageStorage type
+type UsageStorage struct {
+	mock.Mock
+}
+
+// AddBytes provides a mock function with given fields: ctx, userID, delta
+func (_m *UsageStorage) AddBytes(ctx context.Context, userID string, delta int64) error {
+	ret := _m.Called(ctx, userID, delta)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddBytes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, userID, delta)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, userID, month
+func (_m *UsageStorage) Get(ctx context.Context, userID string, month time.Time) (*entity.Usage, error) {
+	ret := _m.Called(ctx, userID, month)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *entity.Usage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (*entity.Usage, error)); ok {
+		return rf(ctx, userID, month)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) *entity.Usage); ok {
+		r0 = rf(ctx, userID, month)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, userID, month)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Lock provides a mock function with given fields: ctx, userID
+func (_m *UsageStorage) Lock(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Lock")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordUpload provides a mock function with given fields: ctx, userID, bytes, month
+func (_m *UsageStorage) RecordUpload(ctx context.Context, userID string, bytes int64, month time.Time) error {
+	ret := _m.Called(ctx, userID, bytes, month)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, time.Time) error); ok {
+		r0 = rf(ctx, userID, bytes, month)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Release provides a mock function with given fields: ctx, id
+func (_m *UsageStorage) Release(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Release")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Reserve provides a mock function with given fields: ctx, reservation
+func (_m *UsageStorage) Reserve(ctx context.Context, reservation entity.UploadReservation) error {
+	ret := _m.Called(ctx, reservation)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reserve")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UploadReservation) error); ok {
+		r0 = rf(ctx, reservation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewUsageStorage creates a new instance of UsageStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUsageStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UsageStorage {
+	mock := &UsageStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
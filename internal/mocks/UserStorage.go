@@ -14,6 +14,24 @@ type UserStorage struct {
 	mock.Mock
 }
 
+// ChangePassword provides a mock function with given fields: ctx, email, old, new
+func (_m *UserStorage) ChangePassword(ctx context.Context, email string, old string, new string) error {
+	ret := _m.Called(ctx, email, old, new)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, email, old, new)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: ctx, user
 func (_m *UserStorage) Create(ctx context.Context, user entity.User) error {
 	ret := _m.Called(ctx, user)
@@ -32,9 +50,45 @@ func (_m *UserStorage) Create(ctx context.Context, user entity.User) error {
 	return r0
 }
 
-// GetByCredentials provides a mock function with given fields: ctx, email, password
-func (_m *UserStorage) GetByCredentials(ctx context.Context, email string, password string) (*entity.User, error) {
-	ret := _m.Called(ctx, email, password)
+// CreateRefreshToken provides a mock function with given fields: ctx, token, id
+func (_m *UserStorage) CreateRefreshToken(ctx context.Context, token string, id string) error {
+	ret := _m.Called(ctx, token, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UserStorage) Delete(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetByCredentials provides a mock function with given fields: ctx, identifier, byEmail
+func (_m *UserStorage) GetByCredentials(ctx context.Context, identifier string, byEmail bool) (*entity.User, error) {
+	ret := _m.Called(ctx, identifier, byEmail)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByCredentials")
@@ -42,19 +96,121 @@ func (_m *UserStorage) GetByCredentials(ctx context.Context, email string, passw
 
 	var r0 *entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*entity.User, error)); ok {
-		return rf(ctx, email, password)
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) (*entity.User, error)); ok {
+		return rf(ctx, identifier, byEmail)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) *entity.User); ok {
-		r0 = rf(ctx, email, password)
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *entity.User); ok {
+		r0 = rf(ctx, identifier, byEmail)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*entity.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, email, password)
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, identifier, byEmail)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementPhotosUploaded provides a mock function with given fields: ctx, userId
+func (_m *UserStorage) IncrementPhotosUploaded(ctx context.Context, userId string) error {
+	ret := _m.Called(ctx, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementPhotosUploaded")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RefreshSession provides a mock function with given fields: ctx, oldToken, newToken
+func (_m *UserStorage) RefreshSession(ctx context.Context, oldToken string, newToken string) error {
+	ret := _m.Called(ctx, oldToken, newToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshSession")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, oldToken, newToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RequirePasswordReset provides a mock function with given fields: ctx, id
+func (_m *UserStorage) RequirePasswordReset(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequirePasswordReset")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeSessions provides a mock function with given fields: ctx, id
+func (_m *UserStorage) RevokeSessions(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeSessions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Search provides a mock function with given fields: ctx, filter
+func (_m *UserStorage) Search(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 []entity.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) ([]entity.User, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) []entity.User); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -62,6 +218,42 @@ func (_m *UserStorage) GetByCredentials(ctx context.Context, email string, passw
 	return r0, r1
 }
 
+// SetDisabled provides a mock function with given fields: ctx, id, disabled
+func (_m *UserStorage) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	ret := _m.Called(ctx, id, disabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetDisabled")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) error); ok {
+		r0 = rf(ctx, id, disabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetRole provides a mock function with given fields: ctx, id, role
+func (_m *UserStorage) SetRole(ctx context.Context, id string, role string) error {
+	ret := _m.Called(ctx, id, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserStorage creates a new instance of UserStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserStorage(t interface {
@@ -7,6 +7,8 @@ import (
 
 	entity "github.com/nordew/UploadApp/internal/domain/entity"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Users is an autogenerated mock type for the Users type
@@ -14,8 +16,175 @@ type Users struct {
 	mock.Mock
 }
 
+// ChangePassword provides a mock function with given fields: ctx, id, old, new
+func (_m *Users) ChangePassword(ctx context.Context, id string, old string, new string) error {
+	ret := _m.Called(ctx, id, old, new)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, old, new)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckSession provides a mock function with given fields: ctx, id, issuedAt
+func (_m *Users) CheckSession(ctx context.Context, id string, issuedAt time.Time) (*entity.User, error) {
+	ret := _m.Called(ctx, id, issuedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckSession")
+	}
+
+	var r0 *entity.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (*entity.User, error)); ok {
+		return rf(ctx, id, issuedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) *entity.User); ok {
+		r0 = rf(ctx, id, issuedAt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, issuedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCredentials provides a mock function with given fields: ctx, identifier, byEmail
+func (_m *Users) GetCredentials(ctx context.Context, identifier string, byEmail bool) (*entity.User, error) {
+	ret := _m.Called(ctx, identifier, byEmail)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCredentials")
+	}
+
+	var r0 *entity.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) (*entity.User, error)); ok {
+		return rf(ctx, identifier, byEmail)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *entity.User); ok {
+		r0 = rf(ctx, identifier, byEmail)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, identifier, byEmail)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementPhotosUploaded provides a mock function with given fields: ctx, id
+func (_m *Users) IncrementPhotosUploaded(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementPhotosUploaded")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Refresh provides a mock function with given fields: ctx, id, refreshToken, issuedAt
+func (_m *Users) Refresh(ctx context.Context, id string, refreshToken string, issuedAt time.Time) (string, string, error) {
+	ret := _m.Called(ctx, id, refreshToken, issuedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Refresh")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) (string, string, error)); ok {
+		return rf(ctx, id, refreshToken, issuedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) string); ok {
+		r0 = rf(ctx, id, refreshToken, issuedAt)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) string); ok {
+		r1 = rf(ctx, id, refreshToken, issuedAt)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, time.Time) error); ok {
+		r2 = rf(ctx, id, refreshToken, issuedAt)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetDisabled provides a mock function with given fields: ctx, id, disabled
+func (_m *Users) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	ret := _m.Called(ctx, id, disabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetDisabled")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) error); ok {
+		r0 = rf(ctx, id, disabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetRole provides a mock function with given fields: ctx, id, role
+func (_m *Users) SetRole(ctx context.Context, id string, role string) error {
+	ret := _m.Called(ctx, id, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SignIn provides a mock function with given fields: ctx, input
-func (_m *Users) SignIn(ctx context.Context, input entity.SignInInput) (string, error) {
+func (_m *Users) SignIn(ctx context.Context, input entity.SignInInput) (string, string, error) {
 	ret := _m.Called(ctx, input)
 
 	if len(ret) == 0 {
@@ -23,8 +192,9 @@ func (_m *Users) SignIn(ctx context.Context, input entity.SignInInput) (string,
 	}
 
 	var r0 string
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, entity.SignInInput) (string, error)); ok {
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.SignInInput) (string, string, error)); ok {
 		return rf(ctx, input)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, entity.SignInInput) string); ok {
@@ -33,13 +203,19 @@ func (_m *Users) SignIn(ctx context.Context, input entity.SignInInput) (string,
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, entity.SignInInput) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, entity.SignInInput) string); ok {
 		r1 = rf(ctx, input)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(context.Context, entity.SignInInput) error); ok {
+		r2 = rf(ctx, input)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
 // SignUp provides a mock function with given fields: ctx, input
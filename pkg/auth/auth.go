@@ -1,5 +1,7 @@
 package auth
 
+import "time"
+
 type Authenticator interface {
 	// GenerateTokens provides opportunity to encrypt access & refresh token.
 	GenerateTokens(options *GenerateTokenClaimsOptions) (string, string, error)
@@ -13,10 +15,12 @@ type Authenticator interface {
 
 type GenerateTokenClaimsOptions struct {
 	UserId string `json:"sub"`
-	Role   string `json:"role""`
+	Role   string `json:"role"`
 }
 
 type ParseTokenClaimsOutput struct {
 	Sub  string
 	Role string
+	// IssuedAt is when the token was issued, in whole seconds. It's zero if the token doesn't tell.
+	IssuedAt time.Time
 }
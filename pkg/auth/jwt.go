@@ -14,15 +14,17 @@ type jwtAuthenticator struct {
 	logger  *logrus.Logger
 }
 
-func NewAuth(logger *logrus.Logger) Authenticator {
+// NewAuth returns an Authenticator signing and verifying HS256 tokens with secret.
+func NewAuth(secret string, logger *logrus.Logger) Authenticator {
 	return &jwtAuthenticator{
-		logger: logger,
+		signKey: secret,
+		logger:  logger,
 	}
 }
 
 type TokenClaims struct {
 	UserId string `json:"sub"`
-	Role   string `json:"role""`
+	Role   string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -121,5 +123,11 @@ func (s *jwtAuthenticator) ParseToken(accessToken string) (*ParseTokenClaimsOutp
 		return nil, fmt.Errorf("token is not valid")
 	}
 
-	return &ParseTokenClaimsOutput{Sub: fmt.Sprint(sub), Role: fmt.Sprint(role)}, nil
+	output := &ParseTokenClaimsOutput{Sub: fmt.Sprint(sub), Role: fmt.Sprint(role)}
+
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		output.IssuedAt = issuedAt.Time
+	}
+
+	return output, nil
 }
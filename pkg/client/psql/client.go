@@ -3,6 +3,7 @@ package psql
 import (
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 type ConnectionInfo struct {
@@ -12,6 +13,15 @@ type ConnectionInfo struct {
 	DBName   string
 	SSLMode  string
 	Password string
+
+	// MaxOpenConns limits the open connections, zero means no limit.
+	MaxOpenConns int
+	// MaxIdleConns limits the idle connections kept in the pool.
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections older than this, zero keeps them forever.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime closes connections idle for longer than this, zero keeps them forever.
+	ConnMaxIdleTime time.Duration
 }
 
 func NewPostgres(connInfo *ConnectionInfo) (*sql.DB, error) {
@@ -23,5 +33,15 @@ func NewPostgres(connInfo *ConnectionInfo) (*sql.DB, error) {
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(connInfo.MaxOpenConns)
+	db.SetMaxIdleConns(connInfo.MaxIdleConns)
+	db.SetConnMaxLifetime(connInfo.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(connInfo.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp           = "up"
+	StatusDown         = "down"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker reports whether a dependency is usable.
+type Checker interface {
+	// Name identifies the dependency in reports.
+	Name() string
+
+	// Check returns an error if the dependency can't be used right now.
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// NewChecker returns a Checker with the given name backed by check.
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, check: check}
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// CheckResult is the outcome of a single checker.
+type CheckResult struct {
+	Name    string
+	Status  string
+	Latency time.Duration
+	Error   string
+}
+
+// Report is the outcome of a readiness check.
+type Report struct {
+	Status string
+	Checks []CheckResult
+}
+
+// Health runs the registered checkers and tracks whether the instance accepts traffic.
+type Health struct {
+	checkers []Checker
+	timeout  time.Duration
+	ready    atomic.Bool
+}
+
+// New returns a ready Health running every checker with the given timeout.
+func New(timeout time.Duration, checkers ...Checker) *Health {
+	h := &Health{
+		checkers: checkers,
+		timeout:  timeout,
+	}
+	h.ready.Store(true)
+
+	return h
+}
+
+// SetReady marks the instance as (not) accepting traffic, e.g. while it's shutting down.
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Check runs every checker concurrently and reports the instance as up only if all of them pass.
+func (h *Health) Check(ctx context.Context) Report {
+	if !h.ready.Load() {
+		return Report{Status: StatusShuttingDown, Checks: []CheckResult{}}
+	}
+
+	results := make([]CheckResult, len(h.checkers))
+
+	var wg sync.WaitGroup
+	for i, checker := range h.checkers {
+		wg.Add(1)
+
+		go func(i int, checker Checker) {
+			defer wg.Done()
+
+			results[i] = h.run(ctx, checker)
+		}(i, checker)
+	}
+
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func (h *Health) run(ctx context.Context, checker Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+
+	result := CheckResult{
+		Name:    checker.Name(),
+		Status:  StatusUp,
+		Latency: time.Since(start),
+	}
+
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -1,10 +1,13 @@
 package logging
 
 import (
+	"context"
 	"github.com/sirupsen/logrus"
 	"os"
 )
 
+type loggerKey struct{}
+
 func NewLogger() *logrus.Logger {
 	logger := logrus.New()
 
@@ -14,3 +17,23 @@ func NewLogger() *logrus.Logger {
 
 	return logger
 }
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx.
+// If ctx carries none, an entry of fallback is returned so callers can always log.
+func FromContext(ctx context.Context, fallback *logrus.Logger) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+
+	return logrus.NewEntry(fallback)
+}
+
+// WithFields returns a copy of ctx whose logger is enriched with fields.
+func WithFields(ctx context.Context, fallback *logrus.Logger, fields logrus.Fields) context.Context {
+	return WithLogger(ctx, FromContext(ctx, fallback).WithFields(fields))
+}
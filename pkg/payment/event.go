@@ -0,0 +1,45 @@
+package payment
+
+import (
+	"errors"
+	"time"
+)
+
+// Provider independent types of the events the application reacts to.
+const (
+	EventPaymentSucceeded    = "payment.succeeded"
+	EventPaymentFailed       = "payment.failed"
+	EventInvoicePaid         = "invoice.paid"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+)
+
+// ErrInvalidSignature is returned for webhook payloads which weren't signed by the provider.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is a notification sent by a payment provider.
+type Event struct {
+	// ID is unique per event, redeliveries of an event keep it.
+	ID string `json:"id"`
+	// Type is one of the Event* constants, or the provider's own type for events the application ignores.
+	Type string `json:"type"`
+	// ObjectID is the provider's ID of the payment, invoice or subscription the event is about.
+	ObjectID string `json:"object_id,omitempty"`
+	// Metadata is the metadata attached to the object when it was created.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// PeriodEnd is the end of the paid period for invoice and subscription events.
+	PeriodEnd time.Time `json:"period_end,omitempty"`
+	// CancelAtPeriodEnd is set for subscription events of subscriptions which won't be renewed.
+	CancelAtPeriodEnd bool `json:"cancel_at_period_end,omitempty"`
+	// Status is the provider's status of a subscription.
+	Status string `json:"status,omitempty"`
+}
+
+// Webhook verifies and decodes the events a payment provider posts.
+type Webhook interface {
+	// SignatureHeader is the HTTP header the provider sends the payload signature in.
+	SignatureHeader() string
+
+	// ParseEvent returns the event in payload. It returns ErrInvalidSignature if signature doesn't match the payload.
+	ParseEvent(payload []byte, signature string) (*Event, error)
+}
@@ -0,0 +1,262 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// fakeSignatureHeader is the header the fake provider signs event payloads in.
+const fakeSignatureHeader = "Fake-Signature"
+
+// ErrUnknownPayment is returned by the fake provider for payments it didn't create.
+var ErrUnknownPayment = errors.New("unknown payment")
+
+// Simulator is implemented by providers which let payments be completed without a client,
+// for local development and tests.
+type Simulator interface {
+	// Simulate completes the payment intent or checkout session with the given ID, successfully or not,
+	// and returns the event the provider sends about it.
+	Simulate(ctx context.Context, paymentID string, succeed bool) (*Event, error)
+}
+
+type fakePayment struct {
+	amountCents   int64
+	refundedCents int64
+	status        string
+	metadata      map[string]string
+}
+
+// Fake is an in-memory Provider which never talks to a real provider and forgets everything on restart.
+// Payments it creates stay in the requires_payment_method status until they are completed with Simulate,
+// checkout sessions lead straight to the success URL.
+type Fake struct {
+	mu        sync.Mutex
+	customers map[string]*Customer
+	intents   map[string]*Intent
+	checkouts map[string]*Checkout
+	payments  map[string]*fakePayment
+	// byKey remembers the object created for every idempotency key.
+	byKey map[string]string
+	// signingKey signs the events the fake creates, so ParseEvent accepts nothing else.
+	signingKey []byte
+}
+
+func NewFake() *Fake {
+	signingKey := make([]byte, 32)
+	if _, err := rand.Read(signingKey); err != nil {
+		panic(fmt.Sprintf("failed to generate signing key: %v", err))
+	}
+
+	return &Fake{
+		customers:  make(map[string]*Customer),
+		intents:    make(map[string]*Intent),
+		checkouts:  make(map[string]*Checkout),
+		payments:   make(map[string]*fakePayment),
+		byKey:      make(map[string]string),
+		signingKey: signingKey,
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateCustomer(ctx context.Context, params CustomerParams) (*Customer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.lookupKey("customer", params.IdempotencyKey); ok {
+		copied := *f.customers[id]
+		return &copied, nil
+	}
+
+	customer := &Customer{ID: "cus_fake_" + uuid.NewString()}
+
+	f.customers[customer.ID] = customer
+	f.rememberKey("customer", params.IdempotencyKey, customer.ID)
+
+	copied := *customer
+	return &copied, nil
+}
+
+func (f *Fake) CreatePaymentIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.lookupKey("intent", params.IdempotencyKey); ok {
+		copied := *f.intents[id]
+		return &copied, nil
+	}
+
+	id := "pi_fake_" + uuid.NewString()
+	intent := &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Status:       "requires_payment_method",
+	}
+
+	f.intents[id] = intent
+	f.payments[id] = newFakePayment(params)
+	f.rememberKey("intent", params.IdempotencyKey, id)
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (f *Fake) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Checkout, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.lookupKey("checkout", params.IdempotencyKey); ok {
+		copied := *f.checkouts[id]
+		return &copied, nil
+	}
+
+	id := "cs_fake_" + uuid.NewString()
+	checkout := &Checkout{
+		ID:  id,
+		URL: params.SuccessURL,
+	}
+
+	f.checkouts[id] = checkout
+	f.payments[id] = newFakePayment(params.IntentParams)
+	f.rememberKey("checkout", params.IdempotencyKey, id)
+
+	copied := *checkout
+	return &copied, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, params RefundParams) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[params.PaymentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPayment, params.PaymentID)
+	}
+
+	amount := params.AmountCents
+	if amount == 0 {
+		amount = p.amountCents - p.refundedCents
+	}
+
+	if p.status != "succeeded" || amount <= 0 || p.refundedCents+amount > p.amountCents {
+		return nil, ErrNotRefundable
+	}
+
+	// A retried refund isn't applied twice.
+	if id, ok := f.lookupKey("refund", params.IdempotencyKey); ok {
+		return &Refund{ID: id, AmountCents: amount, Status: "succeeded"}, nil
+	}
+
+	p.refundedCents += amount
+
+	refund := &Refund{
+		ID:          "re_fake_" + uuid.NewString(),
+		AmountCents: amount,
+		Status:      "succeeded",
+	}
+
+	f.rememberKey("refund", params.IdempotencyKey, refund.ID)
+
+	return refund, nil
+}
+
+// Simulate completes a payment as if the client paid, or failed to pay, and returns the signed event about it.
+func (f *Fake) Simulate(ctx context.Context, paymentID string, succeed bool) (*Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPayment, paymentID)
+	}
+
+	if p.status == "succeeded" {
+		return nil, fmt.Errorf("payment %s already succeeded", paymentID)
+	}
+
+	event := &Event{
+		ID:       "evt_fake_" + uuid.NewString(),
+		Type:     EventPaymentFailed,
+		ObjectID: paymentID,
+		Metadata: copyMetadata(p.metadata),
+	}
+
+	if succeed {
+		p.status = "succeeded"
+		event.Type = EventPaymentSucceeded
+	}
+
+	if intent, ok := f.intents[paymentID]; ok {
+		intent.Status = p.status
+	}
+
+	return event, nil
+}
+
+func (f *Fake) SignatureHeader() string {
+	return fakeSignatureHeader
+}
+
+// Sign returns the signature ParseEvent expects for payload, so tests can post events to the webhook.
+func (f *Fake) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, f.signingKey)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseEvent decodes an Event encoded as JSON and signed with Sign.
+func (f *Fake) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if !hmac.Equal([]byte(f.Sign(payload)), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	return &event, nil
+}
+
+func (f *Fake) lookupKey(kind, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	id, ok := f.byKey[kind+":"+key]
+	return id, ok
+}
+
+func (f *Fake) rememberKey(kind, key, id string) {
+	if key != "" {
+		f.byKey[kind+":"+key] = id
+	}
+}
+
+func newFakePayment(params IntentParams) *fakePayment {
+	return &fakePayment{
+		amountCents: params.AmountCents,
+		status:      "requires_payment_method",
+		metadata:    copyMetadata(params.Metadata),
+	}
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	copied := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+
+	return copied
+}
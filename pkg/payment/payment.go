@@ -1,7 +1,102 @@
 package payment
 
-import "github.com/stripe/stripe-go/v76"
+import (
+	"context"
+	"errors"
+)
 
-type Payment interface {
-	NewProduct(params *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error)
+// ErrNotRefundable is returned for refunds of payments which weren't completed or are refunded already.
+var ErrNotRefundable = errors.New("payment is not refundable")
+
+// Provider is a payment provider. The types it works with are provider independent, adapters
+// translate them to and from the provider's API.
+type Provider interface {
+	// Name identifies the provider in payment records, e.g. "stripe".
+	Name() string
+
+	// CreateCustomer registers a customer payments can be attached to.
+	CreateCustomer(ctx context.Context, params CustomerParams) (*Customer, error)
+
+	// CreatePaymentIntent starts a payment the client completes with the returned client secret.
+	CreatePaymentIntent(ctx context.Context, params IntentParams) (*Intent, error)
+
+	// CreateCheckoutSession starts a payment on a page hosted by the provider the client is redirected to.
+	CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Checkout, error)
+
+	// Refund returns the whole or a part of a completed payment.
+	Refund(ctx context.Context, params RefundParams) (*Refund, error)
+
+	Webhook
+}
+
+// CustomerParams describes a customer to be created.
+type CustomerParams struct {
+	Email    string
+	Metadata map[string]string
+	// IdempotencyKey makes retried calls return the customer created by the first one.
+	IdempotencyKey string
+}
+
+type Customer struct {
+	ID string
+}
+
+// IntentParams describes a payment to be created.
+type IntentParams struct {
+	AmountCents int64
+	Currency    string
+	Description string
+	// CustomerID attaches the payment to a customer created with CreateCustomer.
+	CustomerID string
+	// Email receives the receipt. It's ignored if CustomerID is set, the customer's email is used instead.
+	Email string
+	// Metadata is attached to the payment and sent back with every event about it.
+	Metadata map[string]string
+	// IdempotencyKey makes retried calls return the payment created by the first one.
+	IdempotencyKey string
+}
+
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Status       string
+}
+
+// CheckoutParams describes a hosted checkout to be created.
+type CheckoutParams struct {
+	IntentParams
+	ProductName string
+	SuccessURL  string
+	CancelURL   string
+}
+
+type Checkout struct {
+	ID  string
+	URL string
+}
+
+// Refund reasons understood by every provider.
+const (
+	RefundReasonRequested  = "requested_by_customer"
+	RefundReasonDuplicate  = "duplicate"
+	RefundReasonFraudulent = "fraudulent"
+)
+
+// RefundParams describes a refund to be created.
+type RefundParams struct {
+	// PaymentID is the ID of the payment intent or checkout session to refund.
+	PaymentID string
+	// AmountCents is the amount to refund, 0 refunds what's left of the payment.
+	AmountCents int64
+	// Reason is one of the RefundReason* constants or empty.
+	Reason   string
+	Metadata map[string]string
+	// IdempotencyKey makes retried calls return the refund created by the first one.
+	IdempotencyKey string
+}
+
+type Refund struct {
+	ID          string
+	AmountCents int64
+	Status      string
 }
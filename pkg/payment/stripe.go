@@ -1,22 +1,177 @@
 package payment
 
 import (
+	"context"
+	"strings"
+
 	"github.com/stripe/stripe-go/v76"
-	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/client"
 )
 
-type stripePayment struct {
+type stripeProvider struct {
+	api *client.API
+	// webhookSecret verifies the signature of webhook events.
+	webhookSecret string
+}
+
+// NewStripe returns a Provider backed by Stripe, authenticated with the secret key.
+// Webhook events are verified with the signing secret of the webhook endpoint.
+func NewStripe(secretKey, webhookSecret string) Provider {
+	return &stripeProvider{
+		api:           client.New(secretKey, nil),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (s *stripeProvider) Name() string {
+	return "stripe"
+}
+
+func (s *stripeProvider) CreateCustomer(ctx context.Context, params CustomerParams) (*Customer, error) {
+	customerParams := &stripe.CustomerParams{
+		Metadata: params.Metadata,
+	}
+
+	if params.Email != "" {
+		customerParams.Email = stripe.String(params.Email)
+	}
+
+	setRequestParams(&customerParams.Params, ctx, params.IdempotencyKey)
+
+	customer, err := s.api.Customers.New(customerParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Customer{ID: customer.ID}, nil
+}
+
+func (s *stripeProvider) CreatePaymentIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	intentParams := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(params.AmountCents),
+		Currency: stripe.String(params.Currency),
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+		Metadata: params.Metadata,
+	}
+
+	if params.Description != "" {
+		intentParams.Description = stripe.String(params.Description)
+	}
+
+	if params.CustomerID != "" {
+		intentParams.Customer = stripe.String(params.CustomerID)
+	} else if params.Email != "" {
+		intentParams.ReceiptEmail = stripe.String(params.Email)
+	}
+
+	setRequestParams(&intentParams.Params, ctx, params.IdempotencyKey)
+
+	pi, err := s.api.PaymentIntents.New(intentParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Intent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Status:       string(pi.Status),
+	}, nil
 }
 
-func NewPayement() Payment {
-	return &stripePayment{}
+func (s *stripeProvider) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Checkout, error) {
+	sessionParams := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(params.SuccessURL),
+		CancelURL:  stripe.String(params.CancelURL),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{{
+			Quantity: stripe.Int64(1),
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:   stripe.String(params.Currency),
+				UnitAmount: stripe.Int64(params.AmountCents),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(params.ProductName),
+				},
+			},
+		}},
+		// The metadata is copied to the payment intent so its events can be matched as well.
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			Metadata: params.Metadata,
+		},
+		Metadata: params.Metadata,
+	}
+
+	if params.CustomerID != "" {
+		sessionParams.Customer = stripe.String(params.CustomerID)
+	} else if params.Email != "" {
+		sessionParams.CustomerEmail = stripe.String(params.Email)
+	}
+
+	setRequestParams(&sessionParams.Params, ctx, params.IdempotencyKey)
+
+	session, err := s.api.CheckoutSessions.New(sessionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checkout{
+		ID:  session.ID,
+		URL: session.URL,
+	}, nil
 }
 
-func (s *stripePayment) NewProduct(params *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error) {
-	pi, err := paymentintent.New(params)
+func (s *stripeProvider) Refund(ctx context.Context, params RefundParams) (*Refund, error) {
+	intentID := params.PaymentID
+
+	// Checkout sessions aren't refundable themselves, the payment intent they completed is.
+	if strings.HasPrefix(intentID, "cs_") {
+		sessionParams := &stripe.CheckoutSessionParams{}
+		setRequestParams(&sessionParams.Params, ctx, "")
+
+		session, err := s.api.CheckoutSessions.Get(intentID, sessionParams)
+		if err != nil {
+			return nil, err
+		}
+
+		if session.PaymentIntent == nil {
+			return nil, ErrNotRefundable
+		}
+
+		intentID = session.PaymentIntent.ID
+	}
+
+	refundParams := &stripe.RefundParams{
+		PaymentIntent: stripe.String(intentID),
+		Metadata:      params.Metadata,
+	}
+
+	if params.AmountCents > 0 {
+		refundParams.Amount = stripe.Int64(params.AmountCents)
+	}
+
+	if params.Reason != "" {
+		refundParams.Reason = stripe.String(params.Reason)
+	}
+
+	setRequestParams(&refundParams.Params, ctx, params.IdempotencyKey)
+
+	refund, err := s.api.Refunds.New(refundParams)
 	if err != nil {
 		return nil, err
 	}
 
-	return pi, nil
+	return &Refund{
+		ID:          refund.ID,
+		AmountCents: refund.Amount,
+		Status:      string(refund.Status),
+	}, nil
+}
+
+func setRequestParams(p *stripe.Params, ctx context.Context, idempotencyKey string) {
+	p.Context = ctx
+
+	if idempotencyKey != "" {
+		p.SetIdempotencyKey(idempotencyKey)
+	}
 }
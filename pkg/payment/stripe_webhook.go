@@ -0,0 +1,95 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// stripeSignatureHeader is the header Stripe signs webhook payloads in.
+const stripeSignatureHeader = "Stripe-Signature"
+
+func (s *stripeProvider) SignatureHeader() string {
+	return stripeSignatureHeader
+}
+
+func (s *stripeProvider) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if s.webhookSecret == "" {
+		return nil, fmt.Errorf("%w: no signing secret configured", ErrInvalidSignature)
+	}
+
+	// Events are decoded field by field below, so an account API version newer than the library's is fine.
+	stripeEvent, err := webhook.ConstructEventWithOptions(payload, signature, s.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	event := &Event{
+		ID:   stripeEvent.ID,
+		Type: string(stripeEvent.Type),
+	}
+
+	switch stripeEvent.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &pi); err != nil {
+			return nil, fmt.Errorf("failed to decode payment intent: %w", err)
+		}
+
+		event.Type = EventPaymentFailed
+		if stripeEvent.Type == "payment_intent.succeeded" {
+			event.Type = EventPaymentSucceeded
+		}
+
+		event.ObjectID = pi.ID
+		event.Metadata = pi.Metadata
+	case "invoice.paid":
+		var inv stripe.Invoice
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &inv); err != nil {
+			return nil, fmt.Errorf("failed to decode invoice: %w", err)
+		}
+
+		event.Type = EventInvoicePaid
+		event.ObjectID = inv.ID
+		event.Metadata = inv.Metadata
+
+		if inv.SubscriptionDetails != nil && len(inv.SubscriptionDetails.Metadata) > 0 {
+			event.Metadata = inv.SubscriptionDetails.Metadata
+		}
+
+		// The billed period is the one of the invoice lines, the invoice's own period is the one before it.
+		if inv.Lines != nil {
+			for _, line := range inv.Lines.Data {
+				if line.Period != nil && line.Period.End > event.PeriodEnd.Unix() {
+					event.PeriodEnd = time.Unix(line.Period.End, 0).UTC()
+				}
+			}
+		}
+	case "customer.subscription.updated", "customer.subscription.deleted":
+		var sub stripe.Subscription
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &sub); err != nil {
+			return nil, fmt.Errorf("failed to decode subscription: %w", err)
+		}
+
+		event.Type = EventSubscriptionDeleted
+		if stripeEvent.Type == "customer.subscription.updated" {
+			event.Type = EventSubscriptionUpdated
+		}
+
+		event.ObjectID = sub.ID
+		event.Metadata = sub.Metadata
+		event.Status = string(sub.Status)
+		event.CancelAtPeriodEnd = sub.CancelAtPeriodEnd
+
+		if sub.CurrentPeriodEnd > 0 {
+			event.PeriodEnd = time.Unix(sub.CurrentPeriodEnd, 0).UTC()
+		}
+	}
+
+	return event, nil
+}
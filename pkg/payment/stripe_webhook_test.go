@@ -0,0 +1,166 @@
+package payment
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// stripeEventPayload returns the JSON of a Stripe event of the given type about object.
+func stripeEventPayload(t *testing.T, id, eventType string, object map[string]interface{}) []byte {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"object":      "event",
+		"api_version": "2023-10-16",
+		"type":        eventType,
+		"data":        map[string]interface{}{"object": object},
+	})
+	require.NoError(t, err)
+
+	return payload
+}
+
+// signStripe signs payload like Stripe does at the given time, a zero time meaning now.
+func signStripe(payload []byte, secret string, at time.Time) string {
+	return webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    secret,
+		Timestamp: at,
+	}).Header
+}
+
+func TestStripeParseEvent(t *testing.T) {
+	metadata := map[string]string{"payment_id": "p1", "user_id": "u1", "plan_id": "pro"}
+	periodEnd := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		eventType string
+		object    map[string]interface{}
+		want      Event
+	}{
+		{
+			name:      "payment intent succeeded",
+			eventType: "payment_intent.succeeded",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": metadata},
+			want:      Event{Type: EventPaymentSucceeded, ObjectID: "pi_1", Metadata: metadata},
+		},
+		{
+			name:      "payment intent failed",
+			eventType: "payment_intent.payment_failed",
+			object:    map[string]interface{}{"id": "pi_1", "object": "payment_intent", "metadata": metadata},
+			want:      Event{Type: EventPaymentFailed, ObjectID: "pi_1", Metadata: metadata},
+		},
+		{
+			name:      "invoice paid takes the subscription metadata and the latest line period",
+			eventType: "invoice.paid",
+			object: map[string]interface{}{
+				"id":                   "in_1",
+				"object":               "invoice",
+				"subscription_details": map[string]interface{}{"metadata": metadata},
+				"lines": map[string]interface{}{
+					"object": "list",
+					"data": []interface{}{
+						map[string]interface{}{"id": "il_1", "period": map[string]interface{}{"start": periodEnd.AddDate(0, -2, 0).Unix(), "end": periodEnd.AddDate(0, -1, 0).Unix()}},
+						map[string]interface{}{"id": "il_2", "period": map[string]interface{}{"start": periodEnd.AddDate(0, -1, 0).Unix(), "end": periodEnd.Unix()}},
+					},
+				},
+			},
+			want: Event{Type: EventInvoicePaid, ObjectID: "in_1", Metadata: metadata, PeriodEnd: periodEnd},
+		},
+		{
+			name:      "subscription updated",
+			eventType: "customer.subscription.updated",
+			object: map[string]interface{}{
+				"id":                   "sub_1",
+				"object":               "subscription",
+				"metadata":             metadata,
+				"status":               "active",
+				"cancel_at_period_end": true,
+				"current_period_end":   periodEnd.Unix(),
+			},
+			want: Event{Type: EventSubscriptionUpdated, ObjectID: "sub_1", Metadata: metadata, Status: "active", CancelAtPeriodEnd: true, PeriodEnd: periodEnd},
+		},
+		{
+			name:      "subscription deleted",
+			eventType: "customer.subscription.deleted",
+			object:    map[string]interface{}{"id": "sub_1", "object": "subscription", "metadata": metadata, "status": "canceled"},
+			want:      Event{Type: EventSubscriptionDeleted, ObjectID: "sub_1", Metadata: metadata, Status: "canceled"},
+		},
+		{
+			name:      "unknown type keeps the stripe type",
+			eventType: "charge.refunded",
+			object:    map[string]interface{}{"id": "ch_1", "object": "charge"},
+			want:      Event{Type: "charge.refunded"},
+		},
+	}
+
+	provider := NewStripe("sk_test", testWebhookSecret)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := stripeEventPayload(t, "evt_1", tt.eventType, tt.object)
+
+			event, err := provider.ParseEvent(payload, signStripe(payload, testWebhookSecret, time.Time{}))
+			require.NoError(t, err)
+
+			tt.want.ID = "evt_1"
+			assert.Equal(t, tt.want, *event)
+		})
+	}
+}
+
+func TestStripeParseEventRejectsSignature(t *testing.T) {
+	payload := stripeEventPayload(t, "evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_1", "object": "payment_intent"})
+
+	tests := []struct {
+		name      string
+		secret    string
+		payload   []byte
+		signature string
+	}{
+		{
+			name:      "other secret",
+			secret:    testWebhookSecret,
+			payload:   payload,
+			signature: signStripe(payload, "whsec_other", time.Time{}),
+		},
+		{
+			name:      "stale timestamp",
+			secret:    testWebhookSecret,
+			payload:   payload,
+			signature: signStripe(payload, testWebhookSecret, time.Now().Add(-webhook.DefaultTolerance-time.Minute)),
+		},
+		{
+			name:      "tampered payload",
+			secret:    testWebhookSecret,
+			payload:   stripeEventPayload(t, "evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_2", "object": "payment_intent"}),
+			signature: signStripe(payload, testWebhookSecret, time.Time{}),
+		},
+		{
+			name:    "missing signature",
+			secret:  testWebhookSecret,
+			payload: payload,
+		},
+		{
+			name:      "no secret configured",
+			payload:   payload,
+			signature: signStripe(payload, "", time.Time{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStripe("sk_test", tt.secret).ParseEvent(tt.payload, tt.signature)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+}